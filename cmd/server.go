@@ -2,25 +2,37 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	zerologr "github.com/go-logr/zerologr"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+	"github.com/vanelin/k8s-controller/pkg/certs"
+	"github.com/vanelin/k8s-controller/pkg/common/config"
 	"github.com/vanelin/k8s-controller/pkg/common/utils"
 	"github.com/vanelin/k8s-controller/pkg/ctrl"
 	"github.com/vanelin/k8s-controller/pkg/handlers"
+	"github.com/vanelin/k8s-controller/pkg/health"
 	"github.com/vanelin/k8s-controller/pkg/informer"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrlruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
 
@@ -31,6 +43,10 @@ var serverNamespace string
 var serverMetricPort string
 var serverEnableLeaderElection bool
 var serverLeaderElectionNamespace string
+var serverHealthProbePort string
+var serverTLSCertFile string
+var serverTLSKeyFile string
+var serverTLSClientCAFile string
 
 var serverCmd = &cobra.Command{
 	Use:   "server",
@@ -56,6 +72,21 @@ var serverCmd = &cobra.Command{
 		if serverMetricPort != "" {
 			cfg.MetricPort = serverMetricPort
 		}
+		if serverHealthProbePort != "" {
+			cfg.HealthProbePort = serverHealthProbePort
+		}
+		if cfg.HealthProbePort == "" {
+			cfg.HealthProbePort = "8082" // fallback default
+		}
+		if serverTLSCertFile != "" {
+			cfg.TLSCertFile = serverTLSCertFile
+		}
+		if serverTLSKeyFile != "" {
+			cfg.TLSKeyFile = serverTLSKeyFile
+		}
+		if serverTLSClientCAFile != "" {
+			cfg.TLSClientCAFile = serverTLSClientCAFile
+		}
 		// Handle leader election flag - CLI flag takes precedence over config
 		if cmd.Flags().Changed("enable-leader-election") {
 			cfg.EnableLeaderElection = serverEnableLeaderElection
@@ -91,9 +122,19 @@ var serverCmd = &cobra.Command{
 		// Print additional controller-specific configuration
 		log.Info().
 			Str("metrics_port", cfg.MetricPort).
+			Str("health_probe_port", cfg.HealthProbePort).
 			Bool("leader_election", cfg.EnableLeaderElection).
 			Msg("Controller configuration")
 
+		drainDelay, err := shutdownDelay(cfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Invalid shutdown configuration")
+			os.Exit(1)
+		}
+
+		// Health state reported by /healthz, /readyz and /livez
+		healthState := health.NewState()
+
 		// Start Deployment informer if Kubernetes flags are provided
 		// Priority: CLI flags > env vars > .env file > defaults
 		kubeconfig := serverKubeconfig
@@ -121,6 +162,7 @@ var serverCmd = &cobra.Command{
 
 			// Create informer manager
 			informerManager = informer.NewDeploymentInformerManager(clientset)
+			healthState.SetInformerStatus(informerManager.SyncStatus)
 
 			// Start informers for each namespace
 			for _, namespace := range namespacesToWatch {
@@ -136,7 +178,12 @@ var serverCmd = &cobra.Command{
 			}
 
 			// Create handler manager
-			handlerManager = handlers.NewHandlerManager(informerManager, appVersion)
+			opts, err := handlerOptions(ctx, cfg, clientset, healthState)
+			if err != nil {
+				log.Error().Err(err).Msg("Invalid HTTP handler configuration")
+				os.Exit(1)
+			}
+			handlerManager = handlers.NewHandlerManagerWithOptions(informerManager, appVersion, opts)
 
 			log.Info().Strs("namespaces", namespacesToWatch).Msg("Started informers for namespaces")
 
@@ -155,6 +202,7 @@ var serverCmd = &cobra.Command{
 				Metrics: metricsserver.Options{
 					BindAddress: ":" + metricPort,
 				},
+				HealthProbeBindAddress: ":" + cfg.HealthProbePort,
 			}
 
 			// Configure leader election if enabled
@@ -169,6 +217,7 @@ var serverCmd = &cobra.Command{
 				managerOpts.LeaderElectionNamespace = leaderElectionNamespace
 				managerOpts.LeaderElectionID = "k8s-controller-leader-election"
 				managerOpts.LeaderElectionResourceLock = "leases"
+				healthState.EnableLeaderElection()
 
 				log.Info().
 					Str("namespace", leaderElectionNamespace).
@@ -184,10 +233,43 @@ var serverCmd = &cobra.Command{
 				log.Error().Err(err).Msg("Failed to create controller-runtime manager")
 				os.Exit(1)
 			}
-			if err := ctrl.AddDeploymentControllerWithNameAndNamespaces(mgr, "deployment", namespacesToWatch); err != nil {
+			// The controller reconciles the namespaces with informers, also when they are changed through the API
+			reconciler, err := ctrl.AddDeploymentReconciler(mgr, "deployment", informerManager.GetAvailableNamespaces())
+			if err != nil {
 				log.Error().Err(err).Msg("Failed to add deployment controller")
 				os.Exit(1)
 			}
+			informerManager.OnNamespacesChanged(reconciler.SetNamespaces)
+			if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+				log.Error().Err(err).Msg("Failed to add manager health check")
+				os.Exit(1)
+			}
+			if err := mgr.AddReadyzCheck("controller", func(_ *http.Request) error {
+				return health.FirstError(healthState.Ready())
+			}); err != nil {
+				log.Error().Err(err).Msg("Failed to add manager readiness check")
+				os.Exit(1)
+			}
+
+			// Track manager cache sync and leader election for the probes
+			healthState.ExpectManagerCache()
+			go func() {
+				if mgr.GetCache().WaitForCacheSync(ctx) {
+					log.Info().Msg("Controller-runtime manager cache synced")
+					healthState.SetManagerCacheSynced()
+				}
+			}()
+			if cfg.EnableLeaderElection {
+				go func() {
+					select {
+					case <-mgr.Elected():
+						log.Info().Msg("Elected as leader")
+						healthState.SetLeader()
+					case <-ctx.Done():
+					}
+				}()
+			}
+
 			go func() {
 				log.Info().Str("metrics_port", metricPort).Msg("Starting controller-runtime manager...")
 				if err := mgr.Start(cmd.Context()); err != nil {
@@ -199,7 +281,12 @@ var serverCmd = &cobra.Command{
 			log.Info().Msg("Skipping Deployment informer - no Kubernetes configuration provided")
 			// Create empty informer manager for handlers
 			informerManager = informer.NewDeploymentInformerManager(nil)
-			handlerManager = handlers.NewHandlerManager(informerManager, appVersion)
+			opts, err := handlerOptions(ctx, cfg, nil, healthState)
+			if err != nil {
+				log.Error().Err(err).Msg("Invalid HTTP handler configuration")
+				os.Exit(1)
+			}
+			handlerManager = handlers.NewHandlerManagerWithOptions(informerManager, appVersion, opts)
 		}
 
 		// Determine port with proper formatting - add colon for FastHTTP
@@ -213,12 +300,35 @@ var serverCmd = &cobra.Command{
 			Handler: handlerManager.CreateHandler(),
 		}
 
+		// Load the serving certificate before listening, so a bad one fails fast
+		var certReloader *certs.Reloader
+		if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+			reloader, err := certs.NewReloader(certs.Options{
+				CertFile:     cfg.TLSCertFile,
+				KeyFile:      cfg.TLSKeyFile,
+				ClientCAFile: cfg.TLSClientCAFile,
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load TLS certificate")
+				os.Exit(1)
+			}
+			certReloader = reloader
+			go certReloader.Start(ctx, certs.DefaultReloadInterval)
+		}
+
 		// Start HTTP server in background
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			log.Info().Msgf("Starting FastHTTP server on %s (version: %s)", port, appVersion)
-			if err := server.ListenAndServe(port); err != nil {
+			var err error
+			if certReloader != nil {
+				log.Info().Bool("client_ca", cfg.TLSClientCAFile != "").Msgf("Starting FastHTTP server with TLS on %s (version: %s)", port, appVersion)
+				err = serveTLS(server, port, certReloader)
+			} else {
+				log.Info().Msgf("Starting FastHTTP server on %s (version: %s)", port, appVersion)
+				err = server.ListenAndServe(port)
+			}
+			if err != nil {
 				log.Error().Err(err).Msg("Error starting FastHTTP server")
 				cancel() // Signal other goroutines to stop
 			}
@@ -231,12 +341,27 @@ var serverCmd = &cobra.Command{
 		// Wait for shutdown signal
 		select {
 		case sig := <-sigChan:
+			// Fail readiness right away, so the endpoints controller stops routing new traffic here
+			healthState.SetShuttingDown()
 			log.Info().Str("signal", sig.String()).Msg("Received shutdown signal, starting graceful shutdown")
+
+			// Keep serving until the kubelet has seen /readyz fail and the pod left the endpoints,
+			// otherwise requests are still routed to the closed listener. A second signal skips the wait.
+			log.Info().Dur("delay", drainDelay).Msg("Waiting for the endpoints to drain before shutting down")
+			select {
+			case <-time.After(drainDelay):
+			case <-sigChan:
+				log.Info().Msg("Received second shutdown signal, shutting down now")
+			}
 		case <-ctx.Done():
+			healthState.SetShuttingDown()
 			log.Info().Msg("Context cancelled, starting graceful shutdown")
 		}
 
 		// Graceful shutdown
+		// End event streams first, otherwise Shutdown waits for their connections forever
+		informerManager.Events().Close()
+
 		log.Info().Msg("Shutting down HTTP server...")
 		if err := server.Shutdown(); err != nil {
 			log.Error().Err(err).Msg("Error shutting down HTTP server")
@@ -253,6 +378,183 @@ var serverCmd = &cobra.Command{
 	},
 }
 
+// defaultShutdownDelay is how long the server keeps serving after readiness fails, two periods of
+// the chart's readiness probe
+const defaultShutdownDelay = 10 * time.Second
+
+// shutdownDelay returns SHUTDOWN_DELAY, or defaultShutdownDelay when it is unset
+func shutdownDelay(cfg config.Config) (time.Duration, error) {
+	if cfg.ShutdownDelay == "" {
+		return defaultShutdownDelay, nil
+	}
+	delay, err := time.ParseDuration(cfg.ShutdownDelay)
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("invalid SHUTDOWN_DELAY %q: must be a non-negative duration such as 10s", cfg.ShutdownDelay)
+	}
+	return delay, nil
+}
+
+// serveTLS serves HTTPS with certificates from the reloader, so rotated certificates are used without a restart
+func serveTLS(server *fasthttp.Server, addr string, reloader *certs.Reloader) error {
+	// Same network as fasthttp's ListenAndServe
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		return err
+	}
+	return server.Serve(tls.NewListener(ln, reloader.TLSConfig()))
+}
+
+// handlerOptions builds the HTTP handler options from the resolved configuration. Files it loads,
+// such as API keys, are reloaded and informers started through the API run until the context is done.
+func handlerOptions(ctx context.Context, cfg config.Config, clientset kubernetes.Interface, healthState *health.State) (handlers.Options, error) {
+	opts := handlers.DefaultOptions()
+	opts.RedactEnvValues = !cfg.ExposeEnvValues
+	opts.RedactSecretRefs = !cfg.ExposeSecretRefs
+	opts.EnableMutations = cfg.EnableMutations
+	opts.Clientset = clientset
+	opts.Health = healthState
+	opts.ReadRateLimit = cfg.RateLimitRead
+	opts.ReadRateBurst = cfg.RateLimitReadBurst
+	opts.WriteRateLimit = cfg.RateLimitWrite
+	opts.WriteRateBurst = cfg.RateLimitWriteBurst
+	opts.MaxInFlight = cfg.MaxInFlightRequests
+	opts.InformerContext = ctx
+
+	if cfg.AccessLog != "" {
+		sampleRate := cfg.AccessLogSampleRate
+		if sampleRate < 0 || sampleRate > 1 {
+			return opts, fmt.Errorf("ACCESS_LOG_SAMPLE_RATE must be between 0 and 1, got %g", sampleRate)
+		}
+		if sampleRate == 0 {
+			sampleRate = 1 // fallback default
+		}
+		w, err := openAccessLog(cfg.AccessLog)
+		if err != nil {
+			return opts, err
+		}
+		opts.AccessLog = handlers.NewAccessLog(w, sampleRate)
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		if cfg.TLSCertFile == "" {
+			return opts, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		opts.ClientCertAuth = true
+	}
+
+	// API keys and JWTs are checked first, they are verified locally without a call to the API server
+	var authenticators []auth.Authenticator
+	if cfg.AuthAPIKeysFile != "" {
+		apiKeys, err := auth.NewAPIKeyStore(cfg.AuthAPIKeysFile)
+		if err != nil {
+			return opts, err
+		}
+		// Requests per key are served with the HTTP API metrics
+		if err := metrics.Registry.Register(apiKeys); err != nil {
+			return opts, fmt.Errorf("failed to register API key metrics: %w", err)
+		}
+		go apiKeys.Start(ctx, auth.DefaultAPIKeyReloadInterval)
+		authenticators = append(authenticators, apiKeys)
+	}
+	if cfg.AuthJWTIssuer != "" {
+		if len(splitList(cfg.AuthJWTAudience)) == 0 {
+			return opts, fmt.Errorf("AUTH_JWT_ISSUER requires AUTH_JWT_AUDIENCE")
+		}
+		authenticator, err := jwtAuthenticator(cfg)
+		if err != nil {
+			return opts, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+	if cfg.AuthTokenReview {
+		if clientset == nil {
+			return opts, fmt.Errorf("AUTH_TOKEN_REVIEW requires a Kubernetes client, set --kubeconfig or --in-cluster")
+		}
+		reviewOpts := auth.TokenReviewOptions{}
+		if cfg.AuthCacheTTL != "" {
+			ttl, err := time.ParseDuration(cfg.AuthCacheTTL)
+			if err != nil {
+				return opts, fmt.Errorf("invalid AUTH_CACHE_TTL: %w", err)
+			}
+			reviewOpts.TTL = ttl
+		}
+		authenticators = append(authenticators, auth.NewTokenReviewAuthenticator(clientset, reviewOpts))
+	}
+	if len(authenticators) > 0 {
+		opts.Authenticator = auth.Union(authenticators...)
+	}
+	if cfg.AuthSubjectAccessReview {
+		if opts.Authenticator == nil {
+			return opts, fmt.Errorf("AUTH_SUBJECT_ACCESS_REVIEW requires AUTH_TOKEN_REVIEW or AUTH_JWT_ISSUER")
+		}
+		if clientset == nil {
+			return opts, fmt.Errorf("AUTH_SUBJECT_ACCESS_REVIEW requires a Kubernetes client, set --kubeconfig or --in-cluster")
+		}
+		opts.Authorizer = auth.NewSubjectAccessReviewAuthorizer(clientset, auth.SubjectAccessReviewOptions{})
+	}
+
+	opts.AdminUsers = splitList(cfg.AuthAdminUsers)
+	opts.AdminGroups = splitList(cfg.AuthAdminGroups)
+
+	publicPaths := cfg.AuthPublicPaths
+	if publicPaths == "" {
+		publicPaths = strings.Join(handlers.DefaultPublicPaths, ",") // fallback default
+	}
+	opts.PublicPaths = splitList(publicPaths)
+	return opts, nil
+}
+
+// splitList splits a comma-separated setting, dropping blanks; the result is empty but not nil
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// openAccessLog returns the writer of ACCESS_LOG: stdout, stderr or a file that lines are appended to.
+// The file stays open until the process exits, requests are logged until the server has stopped.
+func openAccessLog(target string) (io.Writer, error) {
+	switch target {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ACCESS_LOG: %w", err)
+	}
+	return f, nil
+}
+
+// jwtAuthenticator builds the JWT authenticator. AUTH_JWT_JWKS is an http(s) URL or a file path;
+// without it the key set is found through the issuer's OpenID Connect discovery document.
+func jwtAuthenticator(cfg config.Config) (auth.Authenticator, error) {
+	var keys *auth.JWKS
+	switch jwks := cfg.AuthJWTJWKS; {
+	case jwks == "":
+		keys = auth.NewJWKSFromIssuer(cfg.AuthJWTIssuer, nil)
+	case strings.HasPrefix(jwks, "https://") || strings.HasPrefix(jwks, "http://"):
+		keys = auth.NewJWKSFromURL(jwks, nil)
+	default:
+		keys = auth.NewJWKSFromFile(jwks)
+	}
+
+	return auth.NewJWTAuthenticator(auth.JWTOptions{
+		Issuer:         cfg.AuthJWTIssuer,
+		Audiences:      splitList(cfg.AuthJWTAudience),
+		UsernameClaim:  cfg.AuthJWTUsernameClaim,
+		GroupsClaim:    cfg.AuthJWTGroupsClaim,
+		UsernamePrefix: cfg.AuthJWTUsernamePrefix,
+		GroupsPrefix:   cfg.AuthJWTGroupsPrefix,
+		Keys:           keys,
+	})
+}
+
 func getServerKubeClient(kubeconfigPath string, inCluster bool) (*kubernetes.Clientset, error) {
 	var config *rest.Config
 	var err error
@@ -274,6 +576,10 @@ func init() {
 	serverCmd.Flags().BoolVar(&serverInCluster, "in-cluster", false, "Use in-cluster Kubernetes config (default: false)")
 	serverCmd.Flags().StringVarP(&serverNamespace, "namespace", "n", "", "Namespace(s) to watch for Deployments (comma-separated, default: default)")
 	serverCmd.Flags().StringVar(&serverMetricPort, "metric-port", "", "Port to run the controller-runtime metrics server on (overrides env vars and config, default: 8081)")
+	serverCmd.Flags().StringVar(&serverHealthProbePort, "health-probe-port", "", "Port to run the controller-runtime health probe server on (overrides env vars and config, default: 8082)")
+	serverCmd.Flags().StringVar(&serverTLSCertFile, "tls-cert-file", "", "PEM certificate to serve HTTPS with, reloaded when it changes (overrides env vars and config)")
+	serverCmd.Flags().StringVar(&serverTLSKeyFile, "tls-key-file", "", "PEM private key of --tls-cert-file (overrides env vars and config)")
+	serverCmd.Flags().StringVar(&serverTLSClientCAFile, "tls-client-ca-file", "", "PEM CA bundle to verify client certificates with; enables client certificate authentication (overrides env vars and config)")
 	serverCmd.Flags().BoolVar(&serverEnableLeaderElection, "enable-leader-election", true, "Enable leader election for controller manager")
 	serverCmd.Flags().StringVar(&serverLeaderElectionNamespace, "leader-election-namespace", "", "Namespace for leader election (overrides env vars and config, default: default)")
 }
@@ -2,9 +2,15 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+	"github.com/vanelin/k8s-controller/pkg/common/config"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 func TestServerCommandDefined(t *testing.T) {
@@ -61,7 +67,7 @@ func TestGetServerKubeClient_InClusterPriority(t *testing.T) {
 
 func TestServerCommandFlags(t *testing.T) {
 	// Test that all expected flags are defined
-	expectedFlags := []string{"port", "kubeconfig", "in-cluster", "namespace"}
+	expectedFlags := []string{"port", "kubeconfig", "in-cluster", "namespace", "tls-cert-file", "tls-key-file", "tls-client-ca-file"}
 
 	for _, flagName := range expectedFlags {
 		flag := serverCmd.Flags().Lookup(flagName)
@@ -226,3 +232,137 @@ func TestServerCmd_LeaderElectionConfig(t *testing.T) {
 	require.Equal(t, "Enable leader election for controller manager", flag.Usage)
 	require.Equal(t, "true", flag.DefValue, "Default value should be true")
 }
+
+func TestHandlerOptions_Auth(t *testing.T) {
+	opts, err := handlerOptions(t.Context(), config.Config{}, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, opts.Authenticator)
+	require.Equal(t, []string{"/healthz", "/readyz", "/livez"}, opts.PublicPaths)
+
+	_, err = handlerOptions(t.Context(), config.Config{AuthTokenReview: true}, nil, nil)
+	require.Error(t, err)
+
+	clientset := fake.NewSimpleClientset()
+	_, err = handlerOptions(t.Context(), config.Config{AuthTokenReview: true, AuthCacheTTL: "soon"}, clientset, nil)
+	require.Error(t, err)
+
+	opts, err = handlerOptions(t.Context(), config.Config{AuthTokenReview: true, AuthCacheTTL: "30s", AuthPublicPaths: " /livez, /openapi.json ,"}, clientset, nil)
+	require.NoError(t, err)
+	require.NotNil(t, opts.Authenticator)
+	require.Equal(t, []string{"/livez", "/openapi.json"}, opts.PublicPaths)
+	require.Nil(t, opts.Authorizer)
+	require.Empty(t, opts.AdminUsers)
+
+	opts, err = handlerOptions(t.Context(), config.Config{AuthTokenReview: true, AuthAdminUsers: "alice, bob", AuthAdminGroups: "ops,"}, clientset, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "bob"}, opts.AdminUsers)
+	require.Equal(t, []string{"ops"}, opts.AdminGroups)
+
+	_, err = handlerOptions(t.Context(), config.Config{AuthSubjectAccessReview: true}, clientset, nil)
+	require.Error(t, err)
+
+	opts, err = handlerOptions(t.Context(), config.Config{AuthTokenReview: true, AuthSubjectAccessReview: true}, clientset, nil)
+	require.NoError(t, err)
+	require.NotNil(t, opts.Authorizer)
+}
+
+func TestHandlerOptions_JWT(t *testing.T) {
+	cfg := config.Config{AuthJWTIssuer: "https://issuer.example.com", AuthJWTAudience: "k8s-controller", AuthJWTJWKS: "jwks.json"}
+	opts, err := handlerOptions(t.Context(), cfg, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, opts.Authenticator)
+
+	// Tokens the issuer signed for other clients must not be accepted
+	_, err = handlerOptions(t.Context(), config.Config{AuthJWTIssuer: cfg.AuthJWTIssuer, AuthJWTAudience: " , ", AuthJWTJWKS: cfg.AuthJWTJWKS}, nil, nil)
+	require.ErrorContains(t, err, "AUTH_JWT_AUDIENCE")
+
+	// Authorization needs the API server even when tokens are verified locally
+	cfg.AuthSubjectAccessReview = true
+	_, err = handlerOptions(t.Context(), cfg, nil, nil)
+	require.Error(t, err)
+
+	opts, err = handlerOptions(t.Context(), cfg, fake.NewSimpleClientset(), nil)
+	require.NoError(t, err)
+	require.NotNil(t, opts.Authorizer)
+}
+
+func TestHandlerOptions_APIKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-keys.yaml")
+	_, err := handlerOptions(t.Context(), config.Config{AuthAPIKeysFile: keyFile}, nil, nil)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte(`keys: [{name: ci, hash: "`+auth.HashAPIKey("key")+`", namespaces: [default], permissions: [read]}]`), 0o600))
+	opts, err := handlerOptions(t.Context(), config.Config{AuthAPIKeysFile: keyFile}, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { metrics.Registry.Unregister(opts.Authenticator.(*auth.APIKeyStore)) })
+	for range 2 {
+		user, ok, err := opts.Authenticator.AuthenticateToken(t.Context(), "key")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "apikey:ci", user.Name)
+	}
+
+	// The requests of each key are counted on the metrics endpoint
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	var requests float64
+	for _, family := range families {
+		if family.GetName() == "k8s_controller_api_key_requests_total" {
+			for _, metric := range family.GetMetric() {
+				require.Equal(t, "ci", metric.GetLabel()[0].GetValue())
+				requests = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, 2.0, requests)
+}
+
+func TestHandlerOptions_ClientCertAuth(t *testing.T) {
+	_, err := handlerOptions(t.Context(), config.Config{TLSClientCAFile: "ca.crt"}, nil, nil)
+	require.Error(t, err)
+
+	opts, err := handlerOptions(t.Context(), config.Config{TLSCertFile: "tls.crt", TLSKeyFile: "tls.key", TLSClientCAFile: "ca.crt"}, nil, nil)
+	require.NoError(t, err)
+	require.True(t, opts.ClientCertAuth)
+
+	opts, err = handlerOptions(t.Context(), config.Config{TLSCertFile: "tls.crt", TLSKeyFile: "tls.key"}, nil, nil)
+	require.NoError(t, err)
+	require.False(t, opts.ClientCertAuth)
+}
+
+func TestHandlerOptions_AccessLog(t *testing.T) {
+	opts, err := handlerOptions(t.Context(), config.Config{}, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, opts.AccessLog)
+
+	_, err = handlerOptions(t.Context(), config.Config{AccessLog: "stdout", AccessLogSampleRate: 1.5}, nil, nil)
+	require.Error(t, err)
+
+	_, err = handlerOptions(t.Context(), config.Config{AccessLog: filepath.Join(t.TempDir(), "missing", "access.log")}, nil, nil)
+	require.Error(t, err)
+
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	opts, err = handlerOptions(t.Context(), config.Config{AccessLog: logFile, AccessLogSampleRate: 0.1}, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, opts.AccessLog)
+	require.FileExists(t, logFile)
+}
+
+func TestShutdownDelay(t *testing.T) {
+	delay, err := shutdownDelay(config.Config{})
+	require.NoError(t, err)
+	require.Equal(t, defaultShutdownDelay, delay)
+
+	delay, err = shutdownDelay(config.Config{ShutdownDelay: "0s"})
+	require.NoError(t, err)
+	require.Zero(t, delay)
+
+	delay, err = shutdownDelay(config.Config{ShutdownDelay: "15s"})
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Second, delay)
+
+	for _, value := range []string{"soon", "-1s"} {
+		_, err = shutdownDelay(config.Config{ShutdownDelay: value})
+		require.Error(t, err, value)
+	}
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultAPIKeyReloadInterval is how often the API key file is checked for changes
+const DefaultAPIKeyReloadInterval = 10 * time.Second
+
+// apiKeyHashPrefix marks the hash algorithm of a stored key
+const apiKeyHashPrefix = "sha256:"
+
+// Usage metrics of an APIKeyStore, labelled by key name. The keys and their hashes are never exported.
+var (
+	apiKeyRequestsDesc = prometheus.NewDesc("k8s_controller_api_key_requests_total",
+		"Number of requests authenticated with each API key since the server started.", []string{"key"}, nil)
+	apiKeyLastUsedDesc = prometheus.NewDesc("k8s_controller_api_key_last_used_timestamp_seconds",
+		"Unix time of the last request authenticated with each API key.", []string{"key"}, nil)
+)
+
+// Permissions an API key can be granted. Mutating endpoints need the permission named after their action.
+const (
+	PermissionRead     = "read"
+	PermissionScale    = "scale"
+	PermissionRestart  = "restart"
+	PermissionPause    = "pause"
+	PermissionResume   = "resume"
+	PermissionImage    = "image"
+	PermissionRollback = "rollback"
+	// PermissionAdmin allows starting and stopping the watch of a namespace
+	PermissionAdmin = "admin"
+)
+
+var knownPermissions = []string{PermissionRead, PermissionScale, PermissionRestart, PermissionPause, PermissionResume, PermissionImage, PermissionRollback, PermissionAdmin}
+
+// Scope limits a user to namespaces and permissions
+type Scope struct {
+	// Namespaces the user may access; "*" allows every namespace
+	Namespaces []string
+	// Permissions granted in those namespaces
+	Permissions []string
+}
+
+// Allows reports whether the scope grants the permission in the namespace
+func (s *Scope) Allows(permission, namespace string) bool {
+	if !contains(s.Permissions, permission) {
+		return false
+	}
+	return contains(s.Namespaces, "*") || contains(s.Namespaces, namespace)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is an entry of the API key file. Only the hash of the key is stored.
+type APIKey struct {
+	// Name identifies the key in logs and usage counters; the user name is "apikey:<name>"
+	Name string `json:"name"`
+	// Hash is "sha256:" followed by the hex encoded SHA-256 of the key, see HashAPIKey
+	Hash        string   `json:"hash"`
+	Namespaces  []string `json:"namespaces"`
+	Permissions []string `json:"permissions"`
+}
+
+// APIKeyUsage counts the requests authenticated with a key since the server started
+type APIKeyUsage struct {
+	Name     string
+	Requests uint64
+	LastUsed time.Time
+}
+
+// apiKeyEntry is a loaded key with the user it authenticates
+type apiKeyEntry struct {
+	name string
+	user *User
+}
+
+// apiKeyCounter is kept per key name, so counts survive key rotation and file reloads
+type apiKeyCounter struct {
+	requests atomic.Uint64
+	lastUsed atomic.Int64
+}
+
+// HashAPIKey returns the value to store in the hash field of an API key file for the key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// APIKeyStore authenticates static, namespace-scoped API keys loaded from a YAML or JSON file, such as
+// a mounted Secret. The file is reloaded when it changes, so keys can be rotated without a restart.
+type APIKeyStore struct {
+	path string
+	now  func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]apiKeyEntry // by hash
+	data []byte
+
+	countersMu sync.Mutex
+	counters   map[string]*apiKeyCounter
+}
+
+// NewAPIKeyStore loads the API key file and fails if it is missing or invalid
+func NewAPIKeyStore(path string) (*APIKeyStore, error) {
+	s := &APIKeyStore{path: path, now: time.Now, counters: make(map[string]*apiKeyCounter)}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the file again and swaps in the new keys if it changed. It reports whether the keys
+// changed; on error the previous keys stay in use.
+func (s *APIKeyStore) Reload() (bool, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	s.mu.RLock()
+	unchanged := s.keys != nil && bytes.Equal(data, s.data)
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	keys, err := parseAPIKeys(data)
+	if err != nil {
+		return false, fmt.Errorf("invalid API key file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	s.keys, s.data = keys, data
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Start checks the file for changes every interval until the context is done
+func (s *APIKeyStore) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultAPIKeyReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Reload()
+			if err != nil {
+				log.Error().Err(err).Str("file", s.path).Msg("Failed to reload API keys, keeping the current ones")
+				continue
+			}
+			if changed {
+				log.Info().Str("file", s.path).Msg("Reloaded API keys")
+			}
+		}
+	}
+}
+
+// AuthenticateToken implements Authenticator. Unknown tokens are rejected without an error, so
+// other authenticators can be tried next. Keys are looked up by their SHA-256, which reveals
+// nothing about stored keys through timing.
+func (s *APIKeyStore) AuthenticateToken(_ context.Context, token string) (*User, bool, error) {
+	s.mu.RLock()
+	entry, ok := s.keys[HashAPIKey(token)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	counter := s.counter(entry.name)
+	counter.requests.Add(1)
+	counter.lastUsed.Store(s.now().UnixNano())
+	return entry.user, true, nil
+}
+
+// Usage returns the request count of every key that was used, by name
+func (s *APIKeyStore) Usage() []APIKeyUsage {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	usage := make([]APIKeyUsage, 0, len(s.counters))
+	for name, counter := range s.counters {
+		usage = append(usage, APIKeyUsage{
+			Name:     name,
+			Requests: counter.requests.Load(),
+			LastUsed: time.Unix(0, counter.lastUsed.Load()),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+// Describe implements prometheus.Collector
+func (s *APIKeyStore) Describe(ch chan<- *prometheus.Desc) {
+	ch <- apiKeyRequestsDesc
+	ch <- apiKeyLastUsedDesc
+}
+
+// Collect implements prometheus.Collector, exporting Usage
+func (s *APIKeyStore) Collect(ch chan<- prometheus.Metric) {
+	for _, usage := range s.Usage() {
+		ch <- prometheus.MustNewConstMetric(apiKeyRequestsDesc, prometheus.CounterValue, float64(usage.Requests), usage.Name)
+		ch <- prometheus.MustNewConstMetric(apiKeyLastUsedDesc, prometheus.GaugeValue, float64(usage.LastUsed.UnixNano())/1e9, usage.Name)
+	}
+}
+
+func (s *APIKeyStore) counter(name string) *apiKeyCounter {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	counter, ok := s.counters[name]
+	if !ok {
+		counter = &apiKeyCounter{}
+		s.counters[name] = counter
+	}
+	return counter
+}
+
+// parseAPIKeys validates the key file and returns its keys by hash
+func parseAPIKeys(data []byte) (map[string]apiKeyEntry, error) {
+	var file struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(file.Keys))
+	keys := make(map[string]apiKeyEntry, len(file.Keys))
+	for i, key := range file.Keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("key %d has no name", i)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("duplicate key name %q", key.Name)
+		}
+		names[key.Name] = true
+
+		hash := strings.ToLower(key.Hash)
+		digest, ok := strings.CutPrefix(hash, apiKeyHashPrefix)
+		if raw, err := hex.DecodeString(digest); !ok || err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("key %q: hash must be %q followed by 64 hex digits", key.Name, apiKeyHashPrefix)
+		}
+		if _, exists := keys[hash]; exists {
+			return nil, fmt.Errorf("key %q: hash is used by another key", key.Name)
+		}
+		if len(key.Namespaces) == 0 {
+			return nil, fmt.Errorf("key %q: at least one namespace is required", key.Name)
+		}
+		for _, permission := range key.Permissions {
+			if !contains(knownPermissions, permission) {
+				return nil, fmt.Errorf("key %q: unknown permission %q, expected one of %s", key.Name, permission, strings.Join(knownPermissions, ", "))
+			}
+		}
+
+		keys[hash] = apiKeyEntry{name: key.Name, user: &User{
+			Name:  "apikey:" + key.Name,
+			Scope: &Scope{Namespaces: key.Namespaces, Permissions: key.Permissions},
+		}}
+	}
+	return keys, nil
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAPIKeyFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestScope_Allows(t *testing.T) {
+	scope := &Scope{Namespaces: []string{"team-a"}, Permissions: []string{PermissionRead, PermissionScale}}
+	assert.True(t, scope.Allows(PermissionRead, "team-a"))
+	assert.True(t, scope.Allows(PermissionScale, "team-a"))
+	assert.False(t, scope.Allows(PermissionRestart, "team-a"))
+	assert.False(t, scope.Allows(PermissionRead, "team-b"))
+
+	all := &Scope{Namespaces: []string{"*"}, Permissions: []string{PermissionRead}}
+	assert.True(t, all.Allows(PermissionRead, "team-b"))
+	assert.False(t, all.Allows(PermissionScale, "team-b"))
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.yaml")
+	writeAPIKeyFile(t, path, `
+keys:
+  - name: team-a
+    hash: `+HashAPIKey("key-a")+`
+    namespaces: [team-a, team-a-staging]
+    permissions: [read, scale]
+  - name: auditor
+    hash: `+HashAPIKey("key-audit")+`
+    namespaces: ["*"]
+    permissions: [read]
+`)
+	store, err := NewAPIKeyStore(path)
+	require.NoError(t, err)
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	user, ok, err := store.AuthenticateToken(context.Background(), "key-a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, &User{
+		Name:  "apikey:team-a",
+		Scope: &Scope{Namespaces: []string{"team-a", "team-a-staging"}, Permissions: []string{"read", "scale"}},
+	}, user)
+
+	_, ok, err = store.AuthenticateToken(context.Background(), "key-b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, _ = store.AuthenticateToken(context.Background(), "key-a")
+	usage := store.Usage()
+	require.Len(t, usage, 1)
+	assert.Equal(t, "team-a", usage[0].Name)
+	assert.Equal(t, uint64(2), usage[0].Requests)
+	assert.True(t, now.Equal(usage[0].LastUsed))
+
+	// Rotation: the old key stops working, the new one counts towards the same name
+	writeAPIKeyFile(t, path, `{"keys":[{"name":"team-a","hash":"`+HashAPIKey("key-a2")+`","namespaces":["team-a"],"permissions":["read"]}]}`)
+	changed, err := store.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	_, ok, _ = store.AuthenticateToken(context.Background(), "key-a")
+	assert.False(t, ok)
+	_, ok, _ = store.AuthenticateToken(context.Background(), "key-audit")
+	assert.False(t, ok)
+	_, ok, _ = store.AuthenticateToken(context.Background(), "key-a2")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), store.Usage()[0].Requests)
+
+	// Usage is exported by key name
+	require.NoError(t, testutil.CollectAndCompare(store, strings.NewReader(`
+# HELP k8s_controller_api_key_requests_total Number of requests authenticated with each API key since the server started.
+# TYPE k8s_controller_api_key_requests_total counter
+k8s_controller_api_key_requests_total{key="team-a"} 3
+# HELP k8s_controller_api_key_last_used_timestamp_seconds Unix time of the last request authenticated with each API key.
+# TYPE k8s_controller_api_key_last_used_timestamp_seconds gauge
+k8s_controller_api_key_last_used_timestamp_seconds{key="team-a"} 1.735787045e+09
+`)))
+
+	// An invalid file keeps the current keys
+	writeAPIKeyFile(t, path, "keys: [{name: broken}]")
+	_, err = store.Reload()
+	assert.Error(t, err)
+	_, ok, _ = store.AuthenticateToken(context.Background(), "key-a2")
+	assert.True(t, ok)
+}
+
+func TestAPIKeyStore_Start(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.yaml")
+	writeAPIKeyFile(t, path, `keys: [{name: ci, hash: "`+HashAPIKey("old")+`", namespaces: [ci], permissions: [read]}]`)
+	store, err := NewAPIKeyStore(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Start(ctx, 10*time.Millisecond)
+
+	writeAPIKeyFile(t, path, `keys: [{name: ci, hash: "`+HashAPIKey("new")+`", namespaces: [ci], permissions: [read]}]`)
+	assert.Eventually(t, func() bool {
+		_, ok, _ := store.AuthenticateToken(context.Background(), "new")
+		return ok
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestNewAPIKeyStore_Errors(t *testing.T) {
+	dir := t.TempDir()
+	hash := HashAPIKey("key")
+	tests := map[string]string{
+		"unknown field":      `keys: [{name: a, hash: "` + hash + `", namespaces: [a], permissions: [read], key: plain}]`,
+		"missing name":       `keys: [{hash: "` + hash + `", namespaces: [a], permissions: [read]}]`,
+		"duplicate name":     `keys: [{name: a, hash: "` + hash + `", namespaces: [a]}, {name: a, hash: "` + HashAPIKey("other") + `", namespaces: [a]}]`,
+		"duplicate hash":     `keys: [{name: a, hash: "` + hash + `", namespaces: [a]}, {name: b, hash: "` + hash + `", namespaces: [a]}]`,
+		"plain key":          `keys: [{name: a, hash: key, namespaces: [a], permissions: [read]}]`,
+		"short hash":         `keys: [{name: a, hash: "sha256:abcd", namespaces: [a], permissions: [read]}]`,
+		"no namespaces":      `keys: [{name: a, hash: "` + hash + `", permissions: [read]}]`,
+		"unknown permission": `keys: [{name: a, hash: "` + hash + `", namespaces: [a], permissions: [delete]}]`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".yaml")
+			writeAPIKeyFile(t, path, content)
+			_, err := NewAPIKeyStore(path)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewAPIKeyStore(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
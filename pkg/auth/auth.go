@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+// ErrMissingToken is returned by BearerToken when the request has no bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// User is the identity of an authenticated caller
+type User struct {
+	Name   string
+	UID    string
+	Groups []string
+	Extra  map[string][]string
+	// Scope restricts the user to namespaces and permissions instead of the configured Authorizer;
+	// nil means the user is not restricted by scope
+	Scope *Scope
+}
+
+// Authenticator validates bearer tokens. It returns false without an error when the token is not valid,
+// and an error only when the token could not be checked.
+type Authenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (*User, bool, error)
+}
+
+// BearerToken extracts the token from an Authorization header value of the form "Bearer <token>"
+func BearerToken(header string) (string, error) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrMissingToken
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
+
+// UserFromCertificate returns the identity of a verified client certificate, using the same mapping as
+// the Kubernetes API server: the common name is the user name and the organizations are the groups
+func UserFromCertificate(cert *x509.Certificate) *User {
+	return &User{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization}
+}
+
+// Union returns an authenticator that tries each authenticator in order and accepts the first
+// identity found. It returns an error only when no authenticator accepted the token and one failed.
+func Union(authenticators ...Authenticator) Authenticator {
+	if len(authenticators) == 1 {
+		return authenticators[0]
+	}
+	return unionAuthenticator(authenticators)
+}
+
+type unionAuthenticator []Authenticator
+
+func (u unionAuthenticator) AuthenticateToken(ctx context.Context, token string) (*User, bool, error) {
+	var errs []error
+	for _, authenticator := range u {
+		user, ok, err := authenticator.AuthenticateToken(ctx, token)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			return user, true, nil
+		}
+	}
+	return nil, false, errors.Join(errs...)
+}
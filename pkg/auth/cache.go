@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// cacheKey identifies a cached decision. Keys are hashes, so tokens are never kept in memory.
+type cacheKey [sha256.Size]byte
+
+// decision is a cached authentication or authorization outcome
+type decision struct {
+	user    *User
+	allowed bool
+	expires time.Time
+}
+
+// decisionCache is a size-bounded cache of decisions that expire individually
+type decisionCache struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]decision
+}
+
+func newDecisionCache(size int) *decisionCache {
+	return &decisionCache{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[cacheKey]decision),
+	}
+}
+
+// get returns the decision for the key if it has not expired
+func (c *decisionCache) get(key cacheKey) (decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, found := c.entries[key]
+	if !found {
+		return decision{}, false
+	}
+	if !c.now().Before(d.expires) {
+		delete(c.entries, key)
+		return decision{}, false
+	}
+	return d, true
+}
+
+// put stores a decision for ttl
+func (c *decisionCache) put(key cacheKey, d decision, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	d.expires = now.Add(ttl)
+	if len(c.entries) >= c.size {
+		// Drop expired entries first; if the cache is still full, drop arbitrary ones
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = d
+}
+
+// len returns the number of cached decisions, including expired ones not yet dropped
+func (c *decisionCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksMinRefreshInterval limits how often unknown key IDs make the key set reload
+	jwksMinRefreshInterval = 30 * time.Second
+	// jwksMaxAge is how long a key set is used before it is reloaded
+	jwksMaxAge = time.Hour
+	// jwksMaxSize bounds the JWKS and discovery documents read over HTTP
+	jwksMaxSize = 1 << 20
+	// jwksFetchTimeout bounds requests made with the default HTTP client
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// errUnknownKey is returned by JWKS.Keys when no key has the requested ID
+var errUnknownKey = errors.New("no key with this ID in the key set")
+
+// JWKS is a JSON Web Key Set loaded from a file, a URL or an OpenID Connect issuer. It is reloaded
+// when it gets old or a token names a key it does not have, so rotated signing keys are picked up.
+type JWKS struct {
+	load func(ctx context.Context) ([]byte, error)
+	now  func() time.Time
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	// attemptAt is the last load attempt, successful or not, and loadErr its error
+	attemptAt time.Time
+	loadErr   error
+	// loading is closed when the load in progress finishes, nil when none is
+	loading chan struct{}
+}
+
+// NewJWKSFromFile creates a key set read from a local JWKS file
+func NewJWKSFromFile(path string) *JWKS {
+	return newJWKS(func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	})
+}
+
+// NewJWKSFromURL creates a key set fetched from a JWKS URL. A nil client uses a default one with a timeout.
+func NewJWKSFromURL(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+	return newJWKS(func(ctx context.Context) ([]byte, error) {
+		return httpGet(ctx, client, url)
+	})
+}
+
+// NewJWKSFromIssuer creates a key set fetched from the jwks_uri of an OpenID Connect issuer's discovery document
+func NewJWKSFromIssuer(issuer string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+	return newJWKS(func(ctx context.Context) ([]byte, error) {
+		body, err := httpGet(ctx, client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+		if err != nil {
+			return nil, err
+		}
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := json.Unmarshal(body, &discovery); err != nil {
+			return nil, fmt.Errorf("invalid discovery document: %w", err)
+		}
+		if discovery.Issuer != issuer {
+			return nil, fmt.Errorf("discovery document is for issuer %q, not %q", discovery.Issuer, issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("discovery document has no jwks_uri")
+		}
+		return httpGet(ctx, client, discovery.JWKSURI)
+	})
+}
+
+func newJWKS(load func(ctx context.Context) ([]byte, error)) *JWKS {
+	return &JWKS{load: load, now: time.Now}
+}
+
+// Keys returns the key with the ID, or every key when kid is empty. Unknown IDs reload the set,
+// at most every 30 seconds. An error other than errUnknownKey means the keys could not be loaded.
+// Loads run in the background and are shared by the callers waiting for them, so a slow issuer
+// only holds up tokens that need the new keys and a cancelled ctx only stops this caller waiting.
+func (s *JWKS) Keys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.keys == nil {
+		if s.loadErr != nil && s.loading == nil && now.Sub(s.attemptAt) < jwksMinRefreshInterval {
+			// The issuer is down and nothing was ever loaded, do not ask it again on every request
+			return nil, s.loadErr
+		}
+		if err := s.waitLocked(ctx, s.startLoadLocked(now)); err != nil {
+			return nil, err
+		}
+		if s.keys == nil {
+			return nil, s.loadErr
+		}
+	} else if now.Sub(s.loadedAt) > jwksMaxAge {
+		// The old keys are served until the new set arrives
+		s.startLoadLocked(now)
+	}
+
+	if keys := s.lookup(kid); len(keys) > 0 {
+		return keys, nil
+	}
+	if s.loading != nil || now.Sub(s.attemptAt) >= jwksMinRefreshInterval {
+		if err := s.waitLocked(ctx, s.startLoadLocked(now)); err != nil {
+			return nil, err
+		}
+		if keys := s.lookup(kid); len(keys) > 0 {
+			return keys, nil
+		}
+		if s.loadErr != nil {
+			return nil, s.loadErr
+		}
+	}
+	return nil, errUnknownKey
+}
+
+func (s *JWKS) lookup(kid string) []crypto.PublicKey {
+	if kid != "" {
+		if key, ok := s.keys[kid]; ok {
+			return []crypto.PublicKey{key}
+		}
+		return nil
+	}
+	keys := make([]crypto.PublicKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startLoadLocked starts loading the key set unless a load is in progress, and returns the channel
+// closed when it finishes. The load is not bound to a request, it runs until jwksFetchTimeout.
+func (s *JWKS) startLoadLocked(now time.Time) chan struct{} {
+	if s.loading != nil {
+		return s.loading
+	}
+	done := make(chan struct{})
+	s.loading, s.attemptAt = done, now
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+		defer cancel()
+		keys, err := s.fetch(ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.loading, s.loadErr = nil, err
+		// On error the previous keys stay in use
+		if err == nil {
+			s.keys, s.loadedAt = keys, now
+		}
+	}()
+	return done
+}
+
+// waitLocked releases s.mu until done is closed or ctx is done
+func (s *JWKS) waitLocked(ctx context.Context, done chan struct{}) error {
+	s.mu.Unlock()
+	defer s.mu.Lock()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch loads and parses the key set
+func (s *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	data, err := s.load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// jsonWebKey holds the members of a JWK (RFC 7517) needed for RSA, EC and Ed25519 public keys
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses the signing keys of a JSON Web Key Set by key ID. Encryption keys and
+// unsupported key types are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key, or returns nil for unsupported key types
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Keys come from a remote document, reject points that are not on the curve
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// httpGet fetches a JSON document
+func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, jwksMaxSize))
+}
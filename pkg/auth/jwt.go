@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultJWTUsernameClaim is the claim used as the user name
+	DefaultJWTUsernameClaim = "sub"
+	// DefaultJWTGroupsClaim is the claim listing the user's groups
+	DefaultJWTGroupsClaim = "groups"
+	// DefaultJWTLeeway is the clock skew allowed when checking exp, nbf and iat
+	DefaultJWTLeeway = time.Minute
+)
+
+// jwtSigningMethods are the accepted signature algorithms; symmetric and "none" are never accepted
+var jwtSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWTOptions configures a JWTAuthenticator
+type JWTOptions struct {
+	// Issuer is the required iss claim
+	Issuer string
+	// Audiences accepted in the aud claim, at least one is required; the token must name one of them
+	Audiences []string
+	// UsernameClaim is the claim used as the user name (default "sub")
+	UsernameClaim string
+	// GroupsClaim is the claim listing groups, a string or an array of strings (default "groups")
+	GroupsClaim string
+	// UsernamePrefix and GroupsPrefix are prepended to the mapped names, e.g. "oidc:", so they
+	// cannot collide with Kubernetes users and groups
+	UsernamePrefix string
+	GroupsPrefix   string
+	// Leeway is the clock skew allowed when checking exp, nbf and iat (default 1m)
+	Leeway time.Duration
+	// Keys verifies token signatures
+	Keys *JWKS
+}
+
+// JWTAuthenticator validates signed JWTs, such as OIDC ID tokens, against a JSON Web Key Set
+type JWTAuthenticator struct {
+	options JWTOptions
+	now     func() time.Time
+}
+
+// NewJWTAuthenticator creates an authenticator for tokens from the issuer, signed by keys in the key set
+func NewJWTAuthenticator(options JWTOptions) (*JWTAuthenticator, error) {
+	if options.Issuer == "" {
+		return nil, fmt.Errorf("a JWT issuer is required")
+	}
+	// Without an audience check any token of the issuer would do, including those of other clients
+	if len(options.Audiences) == 0 {
+		return nil, fmt.Errorf("a JWT audience is required")
+	}
+	if options.Keys == nil {
+		return nil, fmt.Errorf("a JWKS is required")
+	}
+	if options.UsernameClaim == "" {
+		options.UsernameClaim = DefaultJWTUsernameClaim
+	}
+	if options.GroupsClaim == "" {
+		options.GroupsClaim = DefaultJWTGroupsClaim
+	}
+	if options.Leeway <= 0 {
+		options.Leeway = DefaultJWTLeeway
+	}
+	return &JWTAuthenticator{options: options, now: time.Now}, nil
+}
+
+// AuthenticateToken implements Authenticator. Tokens from other issuers are rejected before any
+// key lookup, so they do not make the key set reload; an error means the keys could not be loaded.
+func (a *JWTAuthenticator) AuthenticateToken(ctx context.Context, token string) (*User, bool, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return nil, false, nil
+	}
+	if issuer, _ := unverified.Claims.GetIssuer(); issuer != a.options.Issuer {
+		return nil, false, nil
+	}
+
+	var keysErr error
+	keyfunc := func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		keys, err := a.options.Keys.Keys(ctx, kid)
+		if err != nil {
+			if !errors.Is(err, errUnknownKey) {
+				keysErr = err
+			}
+			return nil, err
+		}
+		set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, 0, len(keys))}
+		for _, key := range keys {
+			set.Keys = append(set.Keys, key)
+		}
+		return set, nil
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(jwtSigningMethods),
+		jwt.WithIssuer(a.options.Issuer),
+		jwt.WithAudience(a.options.Audiences...),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(a.options.Leeway),
+		jwt.WithTimeFunc(a.now),
+	)
+
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(token, claims, keyfunc); err != nil {
+		if keysErr != nil {
+			return nil, false, keysErr
+		}
+		return nil, false, nil
+	}
+
+	user, ok := a.userFromClaims(claims)
+	if !ok {
+		return nil, false, nil
+	}
+	return user, true, nil
+}
+
+// userFromClaims maps the configured claims to a user; tokens without a user name are rejected
+func (a *JWTAuthenticator) userFromClaims(claims jwt.MapClaims) (*User, bool) {
+	name, _ := claims[a.options.UsernameClaim].(string)
+	if name == "" {
+		return nil, false
+	}
+	user := &User{Name: a.options.UsernamePrefix + name}
+	user.UID, _ = claims["sub"].(string)
+
+	switch groups := claims[a.options.GroupsClaim].(type) {
+	case string:
+		user.Groups = []string{a.options.GroupsPrefix + groups}
+	case []any:
+		for _, group := range groups {
+			if g, ok := group.(string); ok && g != "" {
+				user.Groups = append(user.Groups, a.options.GroupsPrefix+g)
+			}
+		}
+	}
+	return user, true
+}
@@ -0,0 +1,418 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+// testSigningKey is a locally generated key published in a test JWKS
+type testSigningKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func newRSASigningKey(t *testing.T, kid string) testSigningKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return testSigningKey{kid: kid, method: jwt.SigningMethodRS256, key: key}
+}
+
+func newECSigningKey(t *testing.T, kid string) testSigningKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return testSigningKey{kid: kid, method: jwt.SigningMethodES256, key: key}
+}
+
+func newEd25519SigningKey(t *testing.T, kid string) testSigningKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return testSigningKey{kid: kid, method: jwt.SigningMethodEdDSA, key: key}
+}
+
+// jwk returns the public JWK of the key
+func (k testSigningKey) jwk() map[string]string {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	switch pub := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "use": "sig", "n": encode(pub.N.Bytes()), "e": encode(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256", "x": encode(pub.X.FillBytes(make([]byte, 32))), "y": encode(pub.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": k.kid, "crv": "Ed25519", "x": encode(pub)}
+	}
+	panic("unsupported key type")
+}
+
+// sign returns a token with the claims; the kid header is omitted when the key has no ID
+func (k testSigningKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	signed, err := token.SignedString(k.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func jwksDocument(t *testing.T, keys ...testSigningKey) []byte {
+	t.Helper()
+	set := map[string][]map[string]string{"keys": {}}
+	for _, k := range keys {
+		set["keys"] = append(set["keys"], k.jwk())
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+func writeJWKSFile(t *testing.T, path string, keys ...testSigningKey) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, keys...), 0o600))
+}
+
+// validClaims returns claims accepted by an authenticator for testIssuer and audience "k8s-controller"
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":    testIssuer,
+		"aud":    "k8s-controller",
+		"sub":    "user-1234",
+		"email":  "alice@example.com",
+		"groups": []string{"developers", "oncall"},
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+	}
+}
+
+func newTestJWTAuthenticator(t *testing.T, keys *JWKS, options JWTOptions) *JWTAuthenticator {
+	t.Helper()
+	options.Issuer = testIssuer
+	options.Audiences = []string{"k8s-controller"}
+	options.Keys = keys
+	authenticator, err := NewJWTAuthenticator(options)
+	require.NoError(t, err)
+	return authenticator
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, ecKey, edKey := newRSASigningKey(t, "rsa"), newECSigningKey(t, "ec"), newEd25519SigningKey(t, "ed")
+	keys, err := ParseJWKS(jwksDocument(t, rsaKey, ecKey, edKey))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.True(t, rsaKey.key.Public().(*rsa.PublicKey).Equal(keys["rsa"]))
+	assert.True(t, ecKey.key.Public().(*ecdsa.PublicKey).Equal(keys["ec"]))
+	assert.True(t, edKey.key.Public().(ed25519.PublicKey).Equal(keys["ed"]))
+
+	// Encryption keys and unknown key types are skipped
+	keys, err = ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},{"kty":"oct","kid":"hmac","k":"c2VjcmV0"},` +
+		string(mustJSON(t, ecKey.jwk())) + `]}`))
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Contains(t, keys, "ec")
+
+	invalid := []string{
+		`not json`,
+		`{"keys":[]}`,
+		`{"keys":[{"kty":"EC","kid":"bad","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		`{"keys":[{"kty":"EC","kid":"bad","crv":"secp256k1","x":"AQ","y":"AQ"}]}`,
+		`{"keys":[{"kty":"RSA","kid":"bad","n":"!!","e":"AQAB"}]}`,
+		`{"keys":[{"kty":"OKP","kid":"bad","crv":"Ed25519","x":"AQ"}]}`,
+	}
+	for _, doc := range invalid {
+		_, err := ParseJWKS([]byte(doc))
+		assert.Error(t, err, doc)
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+	jwksFile := filepath.Join(dir, "jwks.json")
+	rsaKey, ecKey := newRSASigningKey(t, "rsa"), newECSigningKey(t, "ec")
+	writeJWKSFile(t, jwksFile, rsaKey, ecKey)
+	authenticator := newTestJWTAuthenticator(t, NewJWKSFromFile(jwksFile), JWTOptions{})
+
+	user, ok, err := authenticator.AuthenticateToken(context.Background(), rsaKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, &User{Name: "user-1234", UID: "user-1234", Groups: []string{"developers", "oncall"}}, user)
+
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), ecKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	otherKey := newRSASigningKey(t, "rsa")
+	noKid := ecKey
+	noKid.kid = ""
+	// Signed with the public modulus as an HMAC secret, the classic algorithm confusion attack
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte(rsaKey.jwk()["n"]))
+	require.NoError(t, err)
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "token without key ID tries every key", token: noKid.sign(t, validClaims()), want: true},
+		{name: "audience in a list", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { c["aud"] = []string{"other", "k8s-controller"} })), want: true},
+		{name: "not a JWT", token: "good"},
+		{name: "signed by another key", token: otherKey.sign(t, validClaims())},
+		{name: "symmetric algorithm", token: hmacToken},
+		{name: "other issuer", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }))},
+		{name: "other audience", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { c["aud"] = "kubernetes" }))},
+		{name: "no audience", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { delete(c, "aud") }))},
+		{name: "expired", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() }))},
+		{name: "no expiry", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { delete(c, "exp") }))},
+		{name: "not yet valid", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }))},
+		{name: "no user name", token: rsaKey.sign(t, with(func(c jwt.MapClaims) { delete(c, "sub") }))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok, err := authenticator.AuthenticateToken(context.Background(), tt.token)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
+
+func TestNewJWTAuthenticator_RequiresAudience(t *testing.T) {
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	key := newRSASigningKey(t, "rsa")
+	writeJWKSFile(t, jwksFile, key)
+
+	// Any token of the issuer would be accepted without an audience, including one for another client
+	_, err := NewJWTAuthenticator(JWTOptions{Issuer: testIssuer, Keys: NewJWKSFromFile(jwksFile)})
+	require.Error(t, err)
+
+	authenticator := newTestJWTAuthenticator(t, NewJWKSFromFile(jwksFile), JWTOptions{})
+	claims := validClaims()
+	claims["aud"] = "another-client"
+	_, ok, err := authenticator.AuthenticateToken(context.Background(), key.sign(t, claims))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestJWTAuthenticator_ClaimMapping(t *testing.T) {
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	key := newEd25519SigningKey(t, "ed")
+	writeJWKSFile(t, jwksFile, key)
+	authenticator := newTestJWTAuthenticator(t, NewJWKSFromFile(jwksFile), JWTOptions{
+		UsernameClaim:  "email",
+		GroupsClaim:    "roles",
+		UsernamePrefix: "oidc:",
+		GroupsPrefix:   "oidc:",
+	})
+
+	claims := validClaims()
+	claims["roles"] = "admins"
+	user, ok, err := authenticator.AuthenticateToken(context.Background(), key.sign(t, claims))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, &User{Name: "oidc:alice@example.com", UID: "user-1234", Groups: []string{"oidc:admins"}}, user)
+
+	delete(claims, "email")
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), key.sign(t, claims))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestJWTAuthenticator_KeyRotation(t *testing.T) {
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	oldKey, newKey := newRSASigningKey(t, "old"), newECSigningKey(t, "new")
+	writeJWKSFile(t, jwksFile, oldKey)
+
+	keys := NewJWKSFromFile(jwksFile)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	authenticator := newTestJWTAuthenticator(t, keys, JWTOptions{})
+
+	_, ok, err := authenticator.AuthenticateToken(context.Background(), oldKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// The unknown key ID only reloads the set once the refresh interval has passed
+	writeJWKSFile(t, jwksFile, newKey)
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), newKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	now = now.Add(jwksMinRefreshInterval)
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), newKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// A key set that cannot be loaded is an error, not an invalid token
+	require.NoError(t, os.Remove(jwksFile))
+	now = now.Add(jwksMinRefreshInterval)
+	_, _, err = authenticator.AuthenticateToken(context.Background(), newRSASigningKey(t, "unknown").sign(t, validClaims()))
+	assert.Error(t, err)
+
+	// The keys loaded last stay in use
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), newKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestJWKS_SharedBackgroundLoad(t *testing.T) {
+	oldKey, newKey := newRSASigningKey(t, "old"), newECSigningKey(t, "new")
+	var loads atomic.Int32
+	release := make(chan struct{})
+	keys := newJWKS(func(ctx context.Context) ([]byte, error) {
+		if loads.Add(1) == 1 {
+			return jwksDocument(t, oldKey), nil
+		}
+		// A slow issuer, the load is not cancelled with the request that started it
+		select {
+		case <-release:
+			return jwksDocument(t, oldKey, newKey), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	now := time.Now()
+	var nowMu sync.Mutex
+	keys.now = func() time.Time {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		return now
+	}
+
+	_, err := keys.Keys(context.Background(), "old")
+	require.NoError(t, err)
+	nowMu.Lock()
+	now = now.Add(jwksMinRefreshInterval)
+	nowMu.Unlock()
+
+	// The request that starts the reload gives up, the reload goes on
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = keys.Keys(ctx, "new")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Known keys are served while the issuer is slow
+	found, err := keys.Keys(context.Background(), "old")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	// Callers that need the new key wait for the same load
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found, err := keys.Keys(context.Background(), "new")
+			assert.NoError(t, err)
+			assert.Len(t, found, 1)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), loads.Load())
+}
+
+func TestJWKS_FromURLAndIssuer(t *testing.T) {
+	key := newECSigningKey(t, "ec")
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+		case "/keys":
+			_, _ = w.Write(jwksDocument(t, key))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	claims := validClaims()
+	claims["iss"] = server.URL
+	token := key.sign(t, claims)
+
+	for name, keys := range map[string]*JWKS{
+		"url":    NewJWKSFromURL(server.URL+"/keys", server.Client()),
+		"issuer": NewJWKSFromIssuer(server.URL, server.Client()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			authenticator, err := NewJWTAuthenticator(JWTOptions{Issuer: server.URL, Audiences: []string{"k8s-controller"}, Keys: keys})
+			require.NoError(t, err)
+			user, ok, err := authenticator.AuthenticateToken(context.Background(), token)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, "user-1234", user.Name)
+		})
+	}
+
+	t.Run("unavailable", func(t *testing.T) {
+		authenticator, err := NewJWTAuthenticator(JWTOptions{Issuer: server.URL, Audiences: []string{"k8s-controller"}, Keys: NewJWKSFromURL(server.URL+"/missing", server.Client())})
+		require.NoError(t, err)
+		_, _, err = authenticator.AuthenticateToken(context.Background(), token)
+		assert.Error(t, err)
+	})
+}
+
+func TestUnion(t *testing.T) {
+	var calls int
+	tokenReview := NewTokenReviewAuthenticator(newFakeTokenReviewClient(&calls), TokenReviewOptions{})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	key := newECSigningKey(t, "ec")
+	writeJWKSFile(t, jwksFile, key)
+	union := Union(newTestJWTAuthenticator(t, NewJWKSFromFile(jwksFile), JWTOptions{}), tokenReview)
+
+	user, ok, err := union.AuthenticateToken(context.Background(), key.sign(t, validClaims()))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "user-1234", user.Name)
+
+	user, ok, err = union.AuthenticateToken(context.Background(), "good")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "alice", user.Name)
+
+	_, ok, err = union.AuthenticateToken(context.Background(), "bad")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = union.AuthenticateToken(context.Background(), "broken")
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultSubjectAccessReviewTTL is how long an allowed decision is cached
+	DefaultSubjectAccessReviewTTL = time.Minute
+	// DefaultSubjectAccessReviewDeniedTTL is how long a denied decision is cached
+	DefaultSubjectAccessReviewDeniedTTL = 10 * time.Second
+	// DefaultSubjectAccessReviewCacheSize is the maximum number of cached decisions
+	DefaultSubjectAccessReviewCacheSize = 8192
+	// subjectAccessReviewTimeout bounds the SubjectAccessReview request sent to the API server
+	subjectAccessReviewTimeout = 10 * time.Second
+)
+
+// Attributes describe a request on a Kubernetes resource, as in a SubjectAccessReview
+type Attributes struct {
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+// Authorizer decides whether a user may perform a request. It returns an error only when
+// the decision could not be made.
+type Authorizer interface {
+	Authorize(ctx context.Context, user *User, attrs Attributes) (bool, error)
+}
+
+// SubjectAccessReviewOptions configures a SubjectAccessReviewAuthorizer
+type SubjectAccessReviewOptions struct {
+	// TTL is how long an allowed decision is cached (default 1m)
+	TTL time.Duration
+	// DeniedTTL is how long a denied decision is cached (default 10s)
+	DeniedTTL time.Duration
+	// CacheSize is the maximum number of cached decisions (default 8192)
+	CacheSize int
+}
+
+// SubjectAccessReviewAuthorizer asks the API server through the authorization.k8s.io/v1 SubjectAccessReview API,
+// so callers get the same answer kubectl would
+type SubjectAccessReviewAuthorizer struct {
+	clientset kubernetes.Interface
+	options   SubjectAccessReviewOptions
+	cache     *decisionCache
+}
+
+// NewSubjectAccessReviewAuthorizer creates an authorizer that sends SubjectAccessReviews through the clientset
+func NewSubjectAccessReviewAuthorizer(clientset kubernetes.Interface, options SubjectAccessReviewOptions) *SubjectAccessReviewAuthorizer {
+	if options.TTL <= 0 {
+		options.TTL = DefaultSubjectAccessReviewTTL
+	}
+	if options.DeniedTTL <= 0 {
+		options.DeniedTTL = DefaultSubjectAccessReviewDeniedTTL
+	}
+	if options.CacheSize <= 0 {
+		options.CacheSize = DefaultSubjectAccessReviewCacheSize
+	}
+	return &SubjectAccessReviewAuthorizer{
+		clientset: clientset,
+		options:   options,
+		cache:     newDecisionCache(options.CacheSize),
+	}
+}
+
+// Authorize implements Authorizer. Decisions are cached per user and attributes; errors are not cached.
+func (a *SubjectAccessReviewAuthorizer) Authorize(ctx context.Context, user *User, attrs Attributes) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+
+	key := subjectAccessReviewKey(user, attrs)
+	if cached, found := a.cache.get(key); found {
+		return cached.allowed, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, subjectAccessReviewTimeout)
+	defer cancel()
+
+	review, err := a.clientset.AuthorizationV1().SubjectAccessReviews().Create(reqCtx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Name,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:      attrs.Verb,
+				Group:     attrs.Group,
+				Resource:  attrs.Resource,
+				Namespace: attrs.Namespace,
+				Name:      attrs.Name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("subject access review failed: %w", err)
+	}
+	// RBAC reports bindings it could not resolve, such as a RoleBinding to a deleted Role, as an
+	// evaluation error next to its decision. Without an allow it is a denial like any other, only a
+	// failed request is an error.
+	if review.Status.EvaluationError != "" && !review.Status.Allowed {
+		log.Warn().
+			Str("user", user.Name).
+			Str("verb", attrs.Verb).
+			Str("resource", attrs.Resource).
+			Str("namespace", attrs.Namespace).
+			Str("error", review.Status.EvaluationError).
+			Msg("Subject access review evaluation error, access denied")
+	}
+
+	if review.Status.Allowed {
+		a.cache.put(key, decision{allowed: true}, a.options.TTL)
+	} else {
+		a.cache.put(key, decision{}, a.options.DeniedTTL)
+	}
+	return review.Status.Allowed, nil
+}
+
+// subjectAccessReviewKey hashes everything the decision depends on
+func subjectAccessReviewKey(user *User, attrs Attributes) cacheKey {
+	h := sha256.New()
+	write := func(values ...string) {
+		for _, v := range values {
+			h.Write([]byte(v))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+
+	write(user.Name, user.UID)
+	write(user.Groups...)
+	keys := make([]string, 0, len(user.Extra))
+	for k := range user.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		write(k)
+		write(user.Extra[k]...)
+	}
+	write(attrs.Verb, attrs.Group, attrs.Resource, attrs.Namespace, attrs.Name)
+
+	var key cacheKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeSubjectAccessReviewClient allows members of "developers" to list deployments in team-a;
+// reviews for the namespace "broken" fail and RBAC cannot resolve a binding in "stale-binding"
+func newFakeSubjectAccessReviewClient(calls *int) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*calls++
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		attrs := review.Spec.ResourceAttributes
+		if attrs.Namespace == "broken" {
+			return true, nil, errors.New("connection refused")
+		}
+		if attrs.Namespace == "stale-binding" {
+			review.Status.EvaluationError = `role.rbac.authorization.k8s.io "deleted" not found`
+			return true, review, nil
+		}
+		developer := false
+		for _, g := range review.Spec.Groups {
+			developer = developer || g == "developers"
+		}
+		review.Status.Allowed = developer && attrs.Namespace == "team-a" && attrs.Verb == "list" &&
+			attrs.Group == "apps" && attrs.Resource == "deployments"
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestSubjectAccessReviewAuthorizer(t *testing.T) {
+	var calls int
+	authorizer := NewSubjectAccessReviewAuthorizer(newFakeSubjectAccessReviewClient(&calls), SubjectAccessReviewOptions{})
+	alice := &User{Name: "alice", Groups: []string{"developers"}}
+	bob := &User{Name: "bob"}
+	list := func(namespace string) Attributes {
+		return Attributes{Verb: "list", Group: "apps", Resource: "deployments", Namespace: namespace}
+	}
+
+	tests := []struct {
+		name  string
+		user  *User
+		attrs Attributes
+		want  bool
+	}{
+		{name: "allowed", user: alice, attrs: list("team-a"), want: true},
+		{name: "other namespace", user: alice, attrs: list("team-b"), want: false},
+		{name: "other verb", user: alice, attrs: Attributes{Verb: "patch", Group: "apps", Resource: "deployments", Namespace: "team-a"}, want: false},
+		{name: "other user", user: bob, attrs: list("team-a"), want: false},
+		{name: "anonymous", user: nil, attrs: list("team-a"), want: false},
+		{name: "evaluation error", user: alice, attrs: list("stale-binding"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := authorizer.Authorize(context.Background(), tt.user, tt.attrs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, allowed)
+		})
+	}
+
+	_, err := authorizer.Authorize(context.Background(), alice, list("broken"))
+	assert.Error(t, err)
+}
+
+func TestSubjectAccessReviewAuthorizer_Cache(t *testing.T) {
+	var calls int
+	now := time.Now()
+	authorizer := NewSubjectAccessReviewAuthorizer(newFakeSubjectAccessReviewClient(&calls), SubjectAccessReviewOptions{TTL: time.Minute, DeniedTTL: time.Second})
+	authorizer.cache.now = func() time.Time { return now }
+	alice := &User{Name: "alice", Groups: []string{"developers"}}
+	allowed := Attributes{Verb: "list", Group: "apps", Resource: "deployments", Namespace: "team-a"}
+	denied := Attributes{Verb: "list", Group: "apps", Resource: "deployments", Namespace: "team-b"}
+
+	for i := 0; i < 3; i++ {
+		_, _ = authorizer.Authorize(context.Background(), alice, allowed)
+		_, _ = authorizer.Authorize(context.Background(), alice, denied)
+	}
+	assert.Equal(t, 2, calls)
+
+	// Decisions are cached per user, including groups
+	_, _ = authorizer.Authorize(context.Background(), &User{Name: "alice"}, allowed)
+	assert.Equal(t, 3, calls)
+
+	now = now.Add(2 * time.Second)
+	_, _ = authorizer.Authorize(context.Background(), alice, allowed)
+	_, _ = authorizer.Authorize(context.Background(), alice, denied)
+	assert.Equal(t, 4, calls)
+
+	broken := Attributes{Verb: "list", Group: "apps", Resource: "deployments", Namespace: "broken"}
+	_, _ = authorizer.Authorize(context.Background(), alice, broken)
+	_, _ = authorizer.Authorize(context.Background(), alice, broken)
+	assert.Equal(t, 6, calls)
+}
+
+func TestSubjectAccessReviewKey(t *testing.T) {
+	attrs := Attributes{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "team-a", Name: "api"}
+
+	// Field boundaries are part of the key
+	assert.NotEqual(t,
+		subjectAccessReviewKey(&User{Name: "ab", Groups: []string{"c"}}, attrs),
+		subjectAccessReviewKey(&User{Name: "a", Groups: []string{"bc"}}, attrs))
+	assert.NotEqual(t,
+		subjectAccessReviewKey(&User{Name: "alice", Extra: map[string][]string{"scopes": {"read"}}}, attrs),
+		subjectAccessReviewKey(&User{Name: "alice", Extra: map[string][]string{"scopes": {"write"}}}, attrs))
+	assert.Equal(t,
+		subjectAccessReviewKey(&User{Name: "alice", Extra: map[string][]string{"a": {"1"}, "b": {"2"}}}, attrs),
+		subjectAccessReviewKey(&User{Name: "alice", Extra: map[string][]string{"b": {"2"}, "a": {"1"}}}, attrs))
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultTokenReviewTTL is how long a successful TokenReview is cached
+	DefaultTokenReviewTTL = 2 * time.Minute
+	// DefaultTokenReviewFailureTTL is how long a rejected token is cached
+	DefaultTokenReviewFailureTTL = 10 * time.Second
+	// DefaultTokenReviewCacheSize is the maximum number of cached tokens
+	DefaultTokenReviewCacheSize = 4096
+	// tokenReviewTimeout bounds the TokenReview request sent to the API server
+	tokenReviewTimeout = 10 * time.Second
+)
+
+// TokenReviewOptions configures a TokenReviewAuthenticator
+type TokenReviewOptions struct {
+	// Audiences the token must be valid for; empty means the API server's own audience
+	Audiences []string
+	// TTL is how long a successful review is cached (default 2m)
+	TTL time.Duration
+	// FailureTTL is how long a rejected token is cached (default 10s)
+	FailureTTL time.Duration
+	// CacheSize is the maximum number of cached tokens (default 4096)
+	CacheSize int
+}
+
+// TokenReviewAuthenticator validates tokens with the authentication.k8s.io/v1 TokenReview API
+type TokenReviewAuthenticator struct {
+	clientset kubernetes.Interface
+	options   TokenReviewOptions
+	cache     *decisionCache
+}
+
+// NewTokenReviewAuthenticator creates an authenticator that sends TokenReviews through the clientset
+func NewTokenReviewAuthenticator(clientset kubernetes.Interface, options TokenReviewOptions) *TokenReviewAuthenticator {
+	if options.TTL <= 0 {
+		options.TTL = DefaultTokenReviewTTL
+	}
+	if options.FailureTTL <= 0 {
+		options.FailureTTL = DefaultTokenReviewFailureTTL
+	}
+	if options.CacheSize <= 0 {
+		options.CacheSize = DefaultTokenReviewCacheSize
+	}
+	return &TokenReviewAuthenticator{
+		clientset: clientset,
+		options:   options,
+		cache:     newDecisionCache(options.CacheSize),
+	}
+}
+
+// AuthenticateToken implements Authenticator. Results are cached by the SHA-256 of the token,
+// so raw tokens are never kept in memory; errors are not cached.
+func (a *TokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token string) (*User, bool, error) {
+	key := cacheKey(sha256.Sum256([]byte(token)))
+	if cached, found := a.cache.get(key); found {
+		return cached.user, cached.allowed, nil
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, tokenReviewTimeout)
+	defer cancel()
+
+	review, err := a.clientset.AuthenticationV1().TokenReviews().Create(reqCtx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.options.Audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("token review failed: %w", err)
+	}
+	// Status.Error explains why an ordinary bad token, such as an expired one, was not authenticated;
+	// it is a rejection like any other, only a failed request is an error
+	if !review.Status.Authenticated || !a.audienceMatches(review.Status.Audiences) {
+		a.cache.put(key, decision{}, a.options.FailureTTL)
+		return nil, false, nil
+	}
+	user := userFromTokenReview(review.Status.User)
+	a.cache.put(key, decision{user: user, allowed: true}, a.options.TTL)
+	return user, true, nil
+}
+
+// audienceMatches checks that an audience-aware API server accepted one of the requested audiences
+func (a *TokenReviewAuthenticator) audienceMatches(audiences []string) bool {
+	if len(a.options.Audiences) == 0 || len(audiences) == 0 {
+		return true
+	}
+	for _, want := range a.options.Audiences {
+		for _, got := range audiences {
+			if want == got {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func userFromTokenReview(info authenticationv1.UserInfo) *User {
+	user := &User{Name: info.Username, UID: info.UID, Groups: info.Groups}
+	if len(info.Extra) > 0 {
+		user.Extra = make(map[string][]string, len(info.Extra))
+		for k, v := range info.Extra {
+			user.Extra[k] = []string(v)
+		}
+	}
+	return user
+}
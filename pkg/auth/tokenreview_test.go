@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeTokenReviewClient answers TokenReviews: "good" authenticates alice, "broken" fails, "expired" is
+// rejected with a status error, others are rejected
+func newFakeTokenReviewClient(calls *int) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*calls++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		switch review.Spec.Token {
+		case "good":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "alice",
+					UID:      "alice-uid",
+					Groups:   []string{"developers"},
+					Extra:    map[string]authenticationv1.ExtraValue{"scopes": {"read"}},
+				},
+			}
+		case "expired":
+			review.Status = authenticationv1.TokenReviewStatus{Error: "[invalid bearer token, token has expired]"}
+		case "broken":
+			return true, nil, errors.New("connection refused")
+		}
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"Bearer abc", "abc", false},
+		{"bearer  abc ", "abc", false},
+		{"Basic abc", "", true},
+		{"Bearer", "", true},
+		{"Bearer ", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			token, err := BearerToken(tt.header)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMissingToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, token)
+		})
+	}
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	var calls int
+	authenticator := NewTokenReviewAuthenticator(newFakeTokenReviewClient(&calls), TokenReviewOptions{})
+
+	user, ok, err := authenticator.AuthenticateToken(context.Background(), "good")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, &User{Name: "alice", UID: "alice-uid", Groups: []string{"developers"}, Extra: map[string][]string{"scopes": {"read"}}}, user)
+
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), "bad")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), "expired")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = authenticator.AuthenticateToken(context.Background(), "broken")
+	assert.Error(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func TestTokenReviewAuthenticator_Cache(t *testing.T) {
+	var calls int
+	now := time.Now()
+	authenticator := NewTokenReviewAuthenticator(newFakeTokenReviewClient(&calls), TokenReviewOptions{TTL: time.Minute, FailureTTL: time.Second})
+	authenticator.cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, ok, err := authenticator.AuthenticateToken(context.Background(), "good")
+		require.NoError(t, err)
+		require.True(t, ok)
+		_, ok, err = authenticator.AuthenticateToken(context.Background(), "bad")
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+	assert.Equal(t, 2, calls)
+
+	// Rejections expire sooner than successes
+	now = now.Add(2 * time.Second)
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "good")
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "bad")
+	assert.Equal(t, 3, calls)
+
+	now = now.Add(time.Minute)
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "good")
+	assert.Equal(t, 4, calls)
+
+	// Tokens rejected with a status error are cached like other rejections
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "expired")
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "expired")
+	assert.Equal(t, 5, calls)
+
+	// Errors are not cached
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "broken")
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "broken")
+	assert.Equal(t, 7, calls)
+}
+
+func TestTokenReviewAuthenticator_CacheSize(t *testing.T) {
+	var calls int
+	authenticator := NewTokenReviewAuthenticator(newFakeTokenReviewClient(&calls), TokenReviewOptions{CacheSize: 2})
+
+	for _, token := range []string{"a", "b", "c", "d"} {
+		_, _, err := authenticator.AuthenticateToken(context.Background(), token)
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, authenticator.cache.len(), 2)
+}
+
+func TestTokenReviewAuthenticator_Audiences(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		assert.Equal(t, []string{"k8s-controller"}, review.Spec.Audiences)
+		review.Status = authenticationv1.TokenReviewStatus{
+			Authenticated: true,
+			User:          authenticationv1.UserInfo{Username: "bob"},
+			Audiences:     []string{review.Spec.Token},
+		}
+		return true, review, nil
+	})
+	authenticator := NewTokenReviewAuthenticator(clientset, TokenReviewOptions{Audiences: []string{"k8s-controller"}})
+
+	_, ok, err := authenticator.AuthenticateToken(context.Background(), "k8s-controller")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), "other-audience")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,139 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for changes
+const DefaultReloadInterval = 10 * time.Second
+
+// Options configures a Reloader
+type Options struct {
+	// CertFile and KeyFile are the PEM encoded serving certificate and key
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle of CAs that sign client certificates. When set, client
+	// certificates are requested and verified if presented.
+	ClientCAFile string
+}
+
+// Reloader serves a TLS certificate and client CA bundle that are reloaded from disk when they change,
+// e.g. when cert-manager rotates the Secret they are mounted from. Handshakes always use the last
+// files that loaded successfully.
+type Reloader struct {
+	options Options
+
+	// config is the server configuration of the current certificate and CAs, built once per reload
+	config atomic.Pointer[tls.Config]
+
+	// mu serializes reloads
+	mu sync.Mutex
+	// contents of the files the current certificate and CAs were loaded from
+	certPEM, keyPEM, caPEM []byte
+}
+
+// NewReloader loads the certificate, key and client CAs, and fails if they are missing or invalid
+func NewReloader(options Options) (*Reloader, error) {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, fmt.Errorf("both a certificate and a key file are required")
+	}
+	r := &Reloader{options: options}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again and swaps in the new certificate and CAs if any of them changed.
+// It reports whether they changed; on error the previous ones stay in use.
+func (r *Reloader) Reload() (bool, error) {
+	certPEM, err := os.ReadFile(r.options.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.options.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key: %w", err)
+	}
+	var caPEM []byte
+	if r.options.ClientCAFile != "" {
+		if caPEM, err = os.ReadFile(r.options.ClientCAFile); err != nil {
+			return false, fmt.Errorf("failed to read client CA: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) && bytes.Equal(caPEM, r.caPEM) {
+		return false, nil
+	}
+
+	// The certificate and key are written one after the other, a mismatch is retried on the next check
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if caPEM != nil {
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return false, fmt.Errorf("no certificates found in client CA file %s", r.options.ClientCAFile)
+		}
+		// Requests without a certificate can still authenticate with a bearer token or hit public paths
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = clientCAs
+	}
+
+	r.config.Store(config)
+	r.certPEM, r.keyPEM, r.caPEM = certPEM, keyPEM, caPEM
+	return true, nil
+}
+
+// Start checks the files for changes every interval until the context is done
+func (r *Reloader) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Reload()
+			if err != nil {
+				log.Error().Err(err).Str("cert_file", r.options.CertFile).Msg("Failed to reload TLS certificate, keeping the current one")
+				continue
+			}
+			if changed {
+				log.Info().Str("cert_file", r.options.CertFile).Msg("Reloaded TLS certificate")
+			}
+		}
+	}
+}
+
+// TLSConfig returns a server configuration that picks up reloaded certificates on every handshake.
+// Handshakes share the configuration of the last reload instead of building their own.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config.Load(), nil
+		},
+	}
+}
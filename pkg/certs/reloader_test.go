@@ -0,0 +1,238 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCert is a certificate with its key, PEM encoded
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA when parent is nil
+func newTestCert(t *testing.T, subject pkix.Name, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestNewReloader_Errors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "test-ca"}, nil, 0)
+	server := newTestCert(t, pkix.Name{CommonName: "server"}, ca, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, server.certPEM)
+	writeFile(t, keyFile, server.keyPEM)
+	writeFile(t, filepath.Join(dir, "empty.crt"), []byte("not a certificate"))
+
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{name: "no key", options: Options{CertFile: certFile}},
+		{name: "missing file", options: Options{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}},
+		{name: "mismatched key", options: Options{CertFile: certFile, KeyFile: certFile}},
+		{name: "invalid client CA", options: Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(dir, "empty.crt")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReloader(tt.options)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "test-ca"}, nil, 0)
+	first := newTestCert(t, pkix.Name{CommonName: "first"}, ca, x509.ExtKeyUsageServerAuth)
+	second := newTestCert(t, pkix.Name{CommonName: "second"}, ca, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, first.certPEM)
+	writeFile(t, keyFile, first.keyPEM)
+
+	reloader, err := NewReloader(Options{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	served := func() string {
+		config, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "first", served())
+
+	// Handshakes share one configuration until the files change
+	config, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	changed, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	again, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Same(t, config, again)
+
+	// Half-written rotation: the old certificate stays until the key matches
+	writeFile(t, certFile, second.certPEM)
+	_, err = reloader.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "first", served())
+
+	writeFile(t, keyFile, second.keyPEM)
+	changed, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "second", served())
+	rotated, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.NotSame(t, config, rotated)
+}
+
+func TestReloader_Start(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "test-ca"}, nil, 0)
+	first := newTestCert(t, pkix.Name{CommonName: "first"}, ca, x509.ExtKeyUsageServerAuth)
+	second := newTestCert(t, pkix.Name{CommonName: "second"}, ca, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, first.certPEM)
+	writeFile(t, keyFile, first.keyPEM)
+
+	reloader, err := NewReloader(Options{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Start(ctx, 10*time.Millisecond)
+
+	writeFile(t, keyFile, second.keyPEM)
+	writeFile(t, certFile, second.certPEM)
+	assert.Eventually(t, func() bool {
+		config, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		return err == nil && string(config.Certificates[0].Certificate[0]) == string(second.cert.Raw)
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestReloader_ClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "test-ca"}, nil, 0)
+	otherCA := newTestCert(t, pkix.Name{CommonName: "other-ca"}, nil, 0)
+	server := newTestCert(t, pkix.Name{CommonName: "server"}, ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, pkix.Name{CommonName: "alice", Organization: []string{"developers"}}, ca, x509.ExtKeyUsageClientAuth)
+	stranger := newTestCert(t, pkix.Name{CommonName: "mallory"}, otherCA, x509.ExtKeyUsageClientAuth)
+
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	writeFile(t, certFile, server.certPEM)
+	writeFile(t, keyFile, server.keyPEM)
+	writeFile(t, caFile, ca.certPEM)
+
+	reloader, err := NewReloader(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	require.NoError(t, err)
+	defer ln.Close()
+	// handshakes receives the outcome of every server-side handshake
+	type handshake struct {
+		state tls.ConnectionState
+		err   error
+	}
+	handshakes := make(chan handshake, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			err = tlsConn.Handshake()
+			handshakes <- handshake{state: tlsConn.ConnectionState(), err: err}
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	dial := func(c *testCert) handshake {
+		config := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		if c != nil {
+			pair, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+			require.NoError(t, err)
+			// Always send the certificate, even if it is not signed by one of the CAs the server asks for
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &pair, nil }
+		}
+		// With TLS 1.3 the client may finish before the server rejects its certificate, so check the server side
+		if conn, err := tls.Dial("tcp", ln.Addr().String(), config); err == nil {
+			defer conn.Close()
+		}
+		return <-handshakes
+	}
+
+	result := dial(client)
+	require.NoError(t, result.err)
+	require.NotEmpty(t, result.state.VerifiedChains)
+	assert.Equal(t, "alice", result.state.PeerCertificates[0].Subject.CommonName)
+
+	// A certificate is optional, but must be signed by the client CA when presented
+	result = dial(nil)
+	require.NoError(t, result.err)
+	assert.Empty(t, result.state.VerifiedChains)
+	assert.Error(t, dial(stranger).err)
+}
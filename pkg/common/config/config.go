@@ -10,14 +10,43 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Port                    string `mapstructure:"PORT"`
-	KUBECONFIG              string `mapstructure:"KUBECONFIG"`
-	LoggingLevel            string `mapstructure:"LOGGING_LEVEL"`
-	Namespace               string `mapstructure:"NAMESPACE"`
-	InCluster               bool   `mapstructure:"IN_CLUSTER"`
-	MetricPort              string `mapstructure:"METRIC_PORT"`
-	EnableLeaderElection    bool   `mapstructure:"ENABLE_LEADER_ELECTION"`
-	LeaderElectionNamespace string `mapstructure:"LEADER_ELECTION_NAMESPACE"`
+	Port                    string  `mapstructure:"PORT"`
+	KUBECONFIG              string  `mapstructure:"KUBECONFIG"`
+	LoggingLevel            string  `mapstructure:"LOGGING_LEVEL"`
+	Namespace               string  `mapstructure:"NAMESPACE"`
+	InCluster               bool    `mapstructure:"IN_CLUSTER"`
+	MetricPort              string  `mapstructure:"METRIC_PORT"`
+	EnableLeaderElection    bool    `mapstructure:"ENABLE_LEADER_ELECTION"`
+	LeaderElectionNamespace string  `mapstructure:"LEADER_ELECTION_NAMESPACE"`
+	ExposeEnvValues         bool    `mapstructure:"EXPOSE_ENV_VALUES"`
+	ExposeSecretRefs        bool    `mapstructure:"EXPOSE_SECRET_REFS"`
+	EnableMutations         bool    `mapstructure:"ENABLE_MUTATIONS"`
+	HealthProbePort         string  `mapstructure:"HEALTH_PROBE_PORT"`
+	AuthTokenReview         bool    `mapstructure:"AUTH_TOKEN_REVIEW"`
+	AuthPublicPaths         string  `mapstructure:"AUTH_PUBLIC_PATHS"`
+	AuthCacheTTL            string  `mapstructure:"AUTH_CACHE_TTL"`
+	AuthSubjectAccessReview bool    `mapstructure:"AUTH_SUBJECT_ACCESS_REVIEW"`
+	AuthAPIKeysFile         string  `mapstructure:"AUTH_API_KEYS_FILE"`
+	AuthAdminUsers          string  `mapstructure:"AUTH_ADMIN_USERS"`
+	AuthAdminGroups         string  `mapstructure:"AUTH_ADMIN_GROUPS"`
+	AuthJWTIssuer           string  `mapstructure:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience         string  `mapstructure:"AUTH_JWT_AUDIENCE"`
+	AuthJWTJWKS             string  `mapstructure:"AUTH_JWT_JWKS"`
+	AuthJWTUsernameClaim    string  `mapstructure:"AUTH_JWT_USERNAME_CLAIM"`
+	AuthJWTGroupsClaim      string  `mapstructure:"AUTH_JWT_GROUPS_CLAIM"`
+	AuthJWTUsernamePrefix   string  `mapstructure:"AUTH_JWT_USERNAME_PREFIX"`
+	AuthJWTGroupsPrefix     string  `mapstructure:"AUTH_JWT_GROUPS_PREFIX"`
+	RateLimitRead           float64 `mapstructure:"RATE_LIMIT_READ"`
+	RateLimitReadBurst      int     `mapstructure:"RATE_LIMIT_READ_BURST"`
+	RateLimitWrite          float64 `mapstructure:"RATE_LIMIT_WRITE"`
+	RateLimitWriteBurst     int     `mapstructure:"RATE_LIMIT_WRITE_BURST"`
+	MaxInFlightRequests     int     `mapstructure:"MAX_IN_FLIGHT_REQUESTS"`
+	AccessLog               string  `mapstructure:"ACCESS_LOG"`
+	AccessLogSampleRate     float64 `mapstructure:"ACCESS_LOG_SAMPLE_RATE"`
+	TLSCertFile             string  `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile              string  `mapstructure:"TLS_KEY_FILE"`
+	TLSClientCAFile         string  `mapstructure:"TLS_CLIENT_CA_FILE"`
+	ShutdownDelay           string  `mapstructure:"SHUTDOWN_DELAY"`
 }
 
 // LoadConfig reads configuration from file or environment variables
@@ -51,6 +80,93 @@ func LoadConfig(path string) (config Config, err error) {
 	if err := viper.BindEnv("LEADER_ELECTION_NAMESPACE"); err != nil {
 		return config, fmt.Errorf("failed to bind LEADER_ELECTION_NAMESPACE env var: %w", err)
 	}
+	if err := viper.BindEnv("EXPOSE_ENV_VALUES"); err != nil {
+		return config, fmt.Errorf("failed to bind EXPOSE_ENV_VALUES env var: %w", err)
+	}
+	if err := viper.BindEnv("EXPOSE_SECRET_REFS"); err != nil {
+		return config, fmt.Errorf("failed to bind EXPOSE_SECRET_REFS env var: %w", err)
+	}
+	if err := viper.BindEnv("ENABLE_MUTATIONS"); err != nil {
+		return config, fmt.Errorf("failed to bind ENABLE_MUTATIONS env var: %w", err)
+	}
+	if err := viper.BindEnv("HEALTH_PROBE_PORT"); err != nil {
+		return config, fmt.Errorf("failed to bind HEALTH_PROBE_PORT env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_TOKEN_REVIEW"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_TOKEN_REVIEW env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_PUBLIC_PATHS"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_PUBLIC_PATHS env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_CACHE_TTL"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_CACHE_TTL env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_SUBJECT_ACCESS_REVIEW"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_SUBJECT_ACCESS_REVIEW env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_API_KEYS_FILE"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_API_KEYS_FILE env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_ADMIN_USERS"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_ADMIN_USERS env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_ADMIN_GROUPS"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_ADMIN_GROUPS env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_ISSUER"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_ISSUER env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_AUDIENCE"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_AUDIENCE env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_JWKS"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_JWKS env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_USERNAME_CLAIM"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_USERNAME_CLAIM env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_GROUPS_CLAIM"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_GROUPS_CLAIM env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_USERNAME_PREFIX"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_USERNAME_PREFIX env var: %w", err)
+	}
+	if err := viper.BindEnv("AUTH_JWT_GROUPS_PREFIX"); err != nil {
+		return config, fmt.Errorf("failed to bind AUTH_JWT_GROUPS_PREFIX env var: %w", err)
+	}
+	if err := viper.BindEnv("RATE_LIMIT_READ"); err != nil {
+		return config, fmt.Errorf("failed to bind RATE_LIMIT_READ env var: %w", err)
+	}
+	if err := viper.BindEnv("RATE_LIMIT_READ_BURST"); err != nil {
+		return config, fmt.Errorf("failed to bind RATE_LIMIT_READ_BURST env var: %w", err)
+	}
+	if err := viper.BindEnv("RATE_LIMIT_WRITE"); err != nil {
+		return config, fmt.Errorf("failed to bind RATE_LIMIT_WRITE env var: %w", err)
+	}
+	if err := viper.BindEnv("RATE_LIMIT_WRITE_BURST"); err != nil {
+		return config, fmt.Errorf("failed to bind RATE_LIMIT_WRITE_BURST env var: %w", err)
+	}
+	if err := viper.BindEnv("MAX_IN_FLIGHT_REQUESTS"); err != nil {
+		return config, fmt.Errorf("failed to bind MAX_IN_FLIGHT_REQUESTS env var: %w", err)
+	}
+	if err := viper.BindEnv("ACCESS_LOG"); err != nil {
+		return config, fmt.Errorf("failed to bind ACCESS_LOG env var: %w", err)
+	}
+	if err := viper.BindEnv("ACCESS_LOG_SAMPLE_RATE"); err != nil {
+		return config, fmt.Errorf("failed to bind ACCESS_LOG_SAMPLE_RATE env var: %w", err)
+	}
+	if err := viper.BindEnv("TLS_CERT_FILE"); err != nil {
+		return config, fmt.Errorf("failed to bind TLS_CERT_FILE env var: %w", err)
+	}
+	if err := viper.BindEnv("TLS_KEY_FILE"); err != nil {
+		return config, fmt.Errorf("failed to bind TLS_KEY_FILE env var: %w", err)
+	}
+	if err := viper.BindEnv("TLS_CLIENT_CA_FILE"); err != nil {
+		return config, fmt.Errorf("failed to bind TLS_CLIENT_CA_FILE env var: %w", err)
+	}
+	if err := viper.BindEnv("SHUTDOWN_DELAY"); err != nil {
+		return config, fmt.Errorf("failed to bind SHUTDOWN_DELAY env var: %w", err)
+	}
 
 	// Enable automatic environment variable reading
 	viper.AutomaticEnv()
@@ -99,7 +215,7 @@ func (c *Config) setDefaults() {
 	if c.LeaderElectionNamespace == "" {
 		c.LeaderElectionNamespace = "default"
 	}
-	// InCluster defaults to false, no need to set it
+	// InCluster, ExposeEnvValues, ExposeSecretRefs, EnableMutations and the Auth* switches default to false, no need to set them
 }
 
 // GetConfigPath returns the path to the config directory
@@ -126,6 +242,7 @@ func (c *Config) PrintConfig() {
 	fmt.Printf("Configuration:\n")
 	fmt.Printf("  PORT: %s\n", c.Port)
 	fmt.Printf("  METRIC_PORT: %s\n", c.MetricPort)
+	fmt.Printf("  HEALTH_PROBE_PORT: %s\n", c.HealthProbePort)
 	fmt.Printf("  LOGGING_LEVEL: %s\n", c.LoggingLevel)
 	if c.KUBECONFIG != "" {
 		fmt.Printf("  KUBECONFIG: %s\n", c.KUBECONFIG)
@@ -136,4 +253,30 @@ func (c *Config) PrintConfig() {
 	fmt.Printf("  IN_CLUSTER: %t\n", c.InCluster)
 	fmt.Printf("  ENABLE_LEADER_ELECTION: %t\n", c.EnableLeaderElection)
 	fmt.Printf("  LEADER_ELECTION_NAMESPACE: %s\n", c.LeaderElectionNamespace)
+	fmt.Printf("  EXPOSE_ENV_VALUES: %t\n", c.ExposeEnvValues)
+	fmt.Printf("  EXPOSE_SECRET_REFS: %t\n", c.ExposeSecretRefs)
+	fmt.Printf("  ENABLE_MUTATIONS: %t\n", c.EnableMutations)
+	fmt.Printf("  AUTH_TOKEN_REVIEW: %t\n", c.AuthTokenReview)
+	fmt.Printf("  AUTH_PUBLIC_PATHS: %s\n", c.AuthPublicPaths)
+	fmt.Printf("  AUTH_CACHE_TTL: %s\n", c.AuthCacheTTL)
+	fmt.Printf("  AUTH_SUBJECT_ACCESS_REVIEW: %t\n", c.AuthSubjectAccessReview)
+	fmt.Printf("  AUTH_API_KEYS_FILE: %s\n", c.AuthAPIKeysFile)
+	fmt.Printf("  AUTH_JWT_ISSUER: %s\n", c.AuthJWTIssuer)
+	fmt.Printf("  AUTH_JWT_AUDIENCE: %s\n", c.AuthJWTAudience)
+	fmt.Printf("  AUTH_JWT_JWKS: %s\n", c.AuthJWTJWKS)
+	fmt.Printf("  AUTH_JWT_USERNAME_CLAIM: %s\n", c.AuthJWTUsernameClaim)
+	fmt.Printf("  AUTH_JWT_GROUPS_CLAIM: %s\n", c.AuthJWTGroupsClaim)
+	fmt.Printf("  AUTH_JWT_USERNAME_PREFIX: %s\n", c.AuthJWTUsernamePrefix)
+	fmt.Printf("  AUTH_JWT_GROUPS_PREFIX: %s\n", c.AuthJWTGroupsPrefix)
+	fmt.Printf("  RATE_LIMIT_READ: %g\n", c.RateLimitRead)
+	fmt.Printf("  RATE_LIMIT_READ_BURST: %d\n", c.RateLimitReadBurst)
+	fmt.Printf("  RATE_LIMIT_WRITE: %g\n", c.RateLimitWrite)
+	fmt.Printf("  RATE_LIMIT_WRITE_BURST: %d\n", c.RateLimitWriteBurst)
+	fmt.Printf("  MAX_IN_FLIGHT_REQUESTS: %d\n", c.MaxInFlightRequests)
+	fmt.Printf("  ACCESS_LOG: %s\n", c.AccessLog)
+	fmt.Printf("  ACCESS_LOG_SAMPLE_RATE: %g\n", c.AccessLogSampleRate)
+	fmt.Printf("  TLS_CERT_FILE: %s\n", c.TLSCertFile)
+	fmt.Printf("  TLS_KEY_FILE: %s\n", c.TLSKeyFile)
+	fmt.Printf("  TLS_CLIENT_CA_FILE: %s\n", c.TLSClientCAFile)
+	fmt.Printf("  SHUTDOWN_DELAY: %s\n", c.ShutdownDelay)
 }
@@ -245,6 +245,45 @@ func TestLoadConfig_WithEnvironmentVariables(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_RateLimits(t *testing.T) {
+	viper.Reset()
+	keys := []string{"RATE_LIMIT_READ", "RATE_LIMIT_READ_BURST", "RATE_LIMIT_WRITE", "RATE_LIMIT_WRITE_BURST", "MAX_IN_FLIGHT_REQUESTS"}
+	cleanup := envSnapshot(t, keys...)
+	defer cleanup()
+
+	for key, value := range map[string]string{
+		"RATE_LIMIT_READ":        "2.5",
+		"RATE_LIMIT_READ_BURST":  "10",
+		"RATE_LIMIT_WRITE":       "0.2",
+		"RATE_LIMIT_WRITE_BURST": "1",
+		"MAX_IN_FLIGHT_REQUESTS": "100",
+	} {
+		require.NoError(t, os.Setenv(key, value))
+	}
+
+	config, err := LoadConfig("nonexistent/path")
+	require.NoError(t, err)
+	require.Equal(t, 2.5, config.RateLimitRead)
+	require.Equal(t, 10, config.RateLimitReadBurst)
+	require.Equal(t, 0.2, config.RateLimitWrite)
+	require.Equal(t, 1, config.RateLimitWriteBurst)
+	require.Equal(t, 100, config.MaxInFlightRequests)
+}
+
+func TestLoadConfig_AccessLog(t *testing.T) {
+	viper.Reset()
+	cleanup := envSnapshot(t, "ACCESS_LOG", "ACCESS_LOG_SAMPLE_RATE")
+	defer cleanup()
+
+	require.NoError(t, os.Setenv("ACCESS_LOG", "/var/log/k8s-controller/access.log"))
+	require.NoError(t, os.Setenv("ACCESS_LOG_SAMPLE_RATE", "0.05"))
+
+	config, err := LoadConfig("nonexistent/path")
+	require.NoError(t, err)
+	require.Equal(t, "/var/log/k8s-controller/access.log", config.AccessLog)
+	require.Equal(t, 0.05, config.AccessLogSampleRate)
+}
+
 func TestLoadConfig_WithDefaults(t *testing.T) {
 	// Reset Viper to clear any cached values
 	viper.Reset()
@@ -2,6 +2,7 @@ package ctrl
 
 import (
 	"context"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 	appsv1 "k8s.io/api/apps/v1"
@@ -17,7 +18,9 @@ import (
 type DeploymentReconciler struct {
 	client.Client
 	Scheme     *runtime.Scheme
-	Namespaces []string // List of namespaces to watch
+	Namespaces []string // List of namespaces to watch, changed with SetNamespaces once the controller runs
+
+	mu sync.RWMutex
 }
 
 // Reconcile handles reconciliation of Deployment resources
@@ -55,8 +58,18 @@ func (r *DeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return ctrl.Result{}, nil
 }
 
+// SetNamespaces replaces the namespaces to watch, e.g. when informers are started or stopped at runtime
+func (r *DeploymentReconciler) SetNamespaces(namespaces []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Namespaces = namespaces
+	log.Info().Strs("namespaces", namespaces).Msg("Deployment controller namespaces updated")
+}
+
 // isNamespaceWatched checks if namespace is being watched
 func (r *DeploymentReconciler) isNamespaceWatched(namespace string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	for _, ns := range r.Namespaces {
 		if ns == namespace {
 			return true
@@ -77,6 +90,13 @@ func AddDeploymentControllerWithName(mgr manager.Manager, name string) error {
 
 // AddDeploymentControllerWithNameAndNamespaces adds the Deployment controller to the manager with custom name and namespaces
 func AddDeploymentControllerWithNameAndNamespaces(mgr manager.Manager, name string, namespaces []string) error {
+	_, err := AddDeploymentReconciler(mgr, name, namespaces)
+	return err
+}
+
+// AddDeploymentReconciler adds the Deployment controller to the manager and returns its reconciler,
+// whose namespaces can be changed with SetNamespaces while the manager runs
+func AddDeploymentReconciler(mgr manager.Manager, name string, namespaces []string) (*DeploymentReconciler, error) {
 	r := &DeploymentReconciler{
 		Client:     mgr.GetClient(),
 		Scheme:     mgr.GetScheme(),
@@ -93,10 +113,14 @@ func AddDeploymentControllerWithNameAndNamespaces(mgr manager.Manager, name stri
 		Strs("namespaces", namespaces).
 		Msg("Adding Deployment controller with namespace filter")
 
-	return ctrl.NewControllerManagedBy(mgr).
+	err := ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		For(&appsv1.Deployment{}).
 		WithEventFilter(namespacePredicate).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
 		Complete(r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
 }
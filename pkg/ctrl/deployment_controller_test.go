@@ -10,7 +10,9 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestDeploymentReconciler_BasicFlow(t *testing.T) {
@@ -197,4 +199,19 @@ func TestAddDeploymentController(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDeploymentReconciler_SetNamespaces(t *testing.T) {
+	r := &DeploymentReconciler{Namespaces: []string{"default"}}
+	require.True(t, r.isNamespaceWatched("default"))
+	require.False(t, r.isNamespaceWatched("team-a"))
+
+	r.SetNamespaces([]string{"team-a"})
+	require.False(t, r.isNamespaceWatched("default"))
+	require.True(t, r.isNamespaceWatched("team-a"))
+
+	// Requests for namespaces no longer watched are ignored
+	result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "api"}})
+	require.NoError(t, err)
+	require.Equal(t, reconcile.Result{}, result)
+}
+
 func int32Ptr(i int32) *int32 { return &i }
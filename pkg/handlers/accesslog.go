@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+// maxRequestIDLength bounds incoming X-Request-ID values, longer ones are replaced
+const maxRequestIDLength = 128
+
+// AccessLog writes one JSON line per request, separate from the application log. Successful
+// requests are sampled, failed ones (status 400 and above) are always written.
+type AccessLog struct {
+	logger     zerolog.Logger
+	sampleRate float64
+	random     func() float64
+}
+
+// NewAccessLog creates an access log writing to w. sampleRate is the fraction of successful requests
+// written, from 0 (only failures) to 1 (every request).
+func NewAccessLog(w io.Writer, sampleRate float64) *AccessLog {
+	return &AccessLog{
+		logger:     zerolog.New(w).With().Timestamp().Logger(),
+		sampleRate: sampleRate,
+		random:     rand.Float64,
+	}
+}
+
+// sampled reports whether a request with the status is written
+func (a *AccessLog) sampled(status int) bool {
+	if status >= 400 || a.sampleRate >= 1 {
+		return true
+	}
+	return a.random() < a.sampleRate
+}
+
+// log writes the line of a handled request, when it is sampled
+func (a *AccessLog) log(ctx *fasthttp.RequestCtx, route, requestID string, start time.Time) {
+	status := ctx.Response.StatusCode()
+	if !a.sampled(status) {
+		return
+	}
+
+	event := a.logger.Log().
+		Str("request_id", requestID).
+		Str("method", string(ctx.Method())).
+		Str("route", route).
+		Str("path", string(ctx.Path())).
+		Int("status", status).
+		Float64("latency_ms", float64(time.Since(start).Microseconds())/1000).
+		Str("remote_ip", ctx.RemoteIP().String()).
+		Str("user_agent", string(ctx.UserAgent()))
+	// Reading the body of a stream would consume it
+	if !ctx.Response.IsBodyStream() {
+		event = event.Int("bytes", len(ctx.Response.Body()))
+	}
+	if user := requestUser(ctx); user != nil {
+		event = event.Str("user", user.Name)
+	}
+	event.Send()
+}
+
+// requestID returns the X-Request-ID of the request when it is a sensible identifier, so requests
+// can be followed across proxies, and a new UUID otherwise
+func requestID(ctx *fasthttp.RequestCtx) string {
+	if id := string(ctx.Request.Header.Peek("X-Request-ID")); validRequestID(id) {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// validRequestID accepts short identifiers made of letters, digits and -_.:, which are safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLogEntries decodes the lines written to an access log
+func accessLogEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(line, &entry))
+		entries = append(entries, entry)
+	}
+	buf.Reset()
+	return entries
+}
+
+func TestValidRequestID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"":                                      false,
+		"abc-123":                               true,
+		"0d9c5a3e-7f0e-4a8b-9a55-1c2d3e4f5a6b":  true,
+		"trace:1.2_3":                           true,
+		"has space":                             false,
+		"line\nbreak":                           false,
+		`quote"`:                                false,
+		strings.Repeat("a", maxRequestIDLength): true,
+		strings.Repeat("a", maxRequestIDLength+1): false,
+	} {
+		assert.Equal(t, want, validRequestID(id), id)
+	}
+}
+
+func TestHandlerManager_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog := NewAccessLog(&buf, 0.5)
+	samples := []float64{0.7, 0.2}
+	accessLog.random = func() float64 {
+		sample := samples[0]
+		samples = samples[1:]
+		return sample
+	}
+
+	opts := DefaultOptions()
+	opts.Authenticator = &fakeAuthenticator{}
+	opts.AccessLog = accessLog
+	var appLog bytes.Buffer
+	appLogger := zerolog.New(&appLog)
+	opts.Logger = &appLogger
+	informerManager := newFakeInformerManager(t, []string{"team-a"}, newTestDeployment("team-a", "api", 1))
+	handler := NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler()
+
+	t.Run("successful requests are sampled", func(t *testing.T) {
+		// The first sample is above the rate, the second below
+		ctx := listRequest(handler, "/deployments/team-a", "Authorization", "Bearer good")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Empty(t, accessLogEntries(t, &buf))
+
+		ctx = listRequest(handler, "/deployments/team-a?output=csv", "Authorization", "Bearer good", "User-Agent", "kubectl/1.30", "X-Request-ID", "trace-42")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Equal(t, "trace-42", string(ctx.Response.Header.Peek("X-Request-ID")))
+
+		entries := accessLogEntries(t, &buf)
+		require.Len(t, entries, 1)
+		entry := entries[0]
+		assert.Equal(t, "trace-42", entry["request_id"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, "/deployments/{namespace}", entry["route"])
+		assert.Equal(t, "/deployments/team-a", entry["path"])
+		assert.EqualValues(t, 200, entry["status"])
+		assert.EqualValues(t, len(ctx.Response.Body()), entry["bytes"])
+		assert.Equal(t, "alice", entry["user"])
+		assert.Equal(t, "kubectl/1.30", entry["user_agent"])
+		assert.Contains(t, entry, "latency_ms")
+		assert.Contains(t, entry, "time")
+	})
+
+	t.Run("failures are always written", func(t *testing.T) {
+		ctx := listRequest(handler, "/deployments/team-a", "X-Request-ID", "not valid")
+		require.Equal(t, 401, ctx.Response.StatusCode())
+		requestID := string(ctx.Response.Header.Peek("X-Request-ID"))
+		assert.NotEqual(t, "not valid", requestID)
+
+		entries := accessLogEntries(t, &buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, requestID, entries[0]["request_id"])
+		assert.EqualValues(t, 401, entries[0]["status"])
+		assert.NotContains(t, entries[0], "user")
+
+		ctx = listRequest(handler, "/unknown", "Authorization", "Bearer good")
+		require.Equal(t, 404, ctx.Response.StatusCode())
+		entries = accessLogEntries(t, &buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, unmatchedRoute, entries[0]["route"])
+	})
+
+	t.Run("successful reads are left out of the application log", func(t *testing.T) {
+		// The access log is written in full again, the remaining samples are not used
+		accessLog.sampleRate = 1
+		appLog.Reset()
+		require.Equal(t, 200, listRequest(handler, "/namespaces", "Authorization", "Bearer good").Response.StatusCode())
+		assert.Empty(t, appLog.String())
+		assert.Len(t, accessLogEntries(t, &buf), 1)
+
+		// Warnings and errors still are
+		require.Equal(t, 404, listRequest(handler, "/unknown", "Authorization", "Bearer good").Response.StatusCode())
+		assert.Contains(t, appLog.String(), "Endpoint not found")
+	})
+}
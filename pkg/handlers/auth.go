@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+)
+
+// DefaultPublicPaths are served without authentication unless Options.PublicPaths is set
+var DefaultPublicPaths = []string{"/healthz", "/readyz", "/livez"}
+
+// userKey is the user value under which the authenticated *auth.User is stored
+const userKey = "auth.user"
+
+// Verbs authorized on apps/deployments, matching what kubectl needs for the same data. Mutating
+// endpoints are authorized with their action name, which is checked as patch.
+const (
+	verbGet   = "get"
+	verbList  = "list"
+	verbWatch = "watch"
+	verbPatch = "patch"
+)
+
+// verbUpdate is authorized on the namespace for the admin endpoints, which change what the server watches
+const verbUpdate = "update"
+
+// authenticate identifies the caller by a verified TLS client certificate or a bearer token. It writes
+// an error response and returns false when the request must not be served. Requests are let through
+// unchanged when the path is public or no authentication method is configured.
+func (hm *HandlerManager) authenticate(ctx *fasthttp.RequestCtx, path string, logger *zerolog.Logger) bool {
+	if !hm.authenticationEnabled() || hm.isPublicPath(path) {
+		return true
+	}
+
+	user := clientCertificateUser(ctx)
+	if user == nil && hm.options.Authenticator != nil {
+		token, err := auth.BearerToken(string(ctx.Request.Header.Peek("Authorization")))
+		if err != nil {
+			message := "A bearer token is required"
+			if hm.options.ClientCertAuth {
+				message = "A bearer token or client certificate is required"
+			}
+			hm.writeUnauthorizedResponse(ctx, message, *logger)
+			return false
+		}
+
+		var ok bool
+		user, ok, err = hm.options.Authenticator.AuthenticateToken(ctx, token)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to authenticate request")
+			hm.writeErrorResponse(ctx, CodeAuthUnavailable, "Authentication is temporarily unavailable", 503, *logger)
+			return false
+		}
+		if !ok {
+			hm.writeUnauthorizedResponse(ctx, "The bearer token is not valid", *logger)
+			return false
+		}
+	}
+	if user == nil {
+		hm.writeUnauthorizedResponse(ctx, "A client certificate is required", *logger)
+		return false
+	}
+
+	ctx.SetUserValue(userKey, user)
+	*logger = logger.With().Str("user", user.Name).Strs("groups", user.Groups).Logger()
+	return true
+}
+
+// authenticationEnabled reports whether requests to non-public paths must be authenticated
+func (hm *HandlerManager) authenticationEnabled() bool {
+	return hm.options.Authenticator != nil || hm.options.ClientCertAuth
+}
+
+// clientCertificateUser returns the identity of the verified TLS client certificate, or nil if there is none
+func clientCertificateUser(ctx *fasthttp.RequestCtx) *auth.User {
+	state := ctx.TLSConnectionState()
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return auth.UserFromCertificate(state.PeerCertificates[0])
+}
+
+// isPublicPath reports whether the path, with or without APIPrefix, is served without authentication.
+// A public path ending in /* also covers every path below it.
+func (hm *HandlerManager) isPublicPath(path string) bool {
+	if path == APIPrefix || strings.HasPrefix(path, APIPrefix+"/") {
+		path = strings.TrimPrefix(path, APIPrefix)
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	publicPaths := hm.options.PublicPaths
+	if publicPaths == nil {
+		publicPaths = DefaultPublicPaths
+	}
+	for _, public := range publicPaths {
+		if prefix, ok := strings.CutSuffix(public, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if path == public {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize reports whether the user may perform verb on deployments in the namespace, or on the named
+// deployment when name is set. Users with a scope, such as API keys, are limited to it; others are
+// checked with the Authorizer. Everything is allowed without an Authorizer or an authenticated user,
+// that is when authentication is off or the path is public.
+func (hm *HandlerManager) authorize(ctx context.Context, user *auth.User, verb, namespace, name string) (bool, error) {
+	if user != nil && user.Scope != nil {
+		return user.Scope.Allows(scopePermission(verb), namespace), nil
+	}
+	if hm.options.Authorizer == nil || user == nil {
+		return true, nil
+	}
+	if verb != verbGet && verb != verbList && verb != verbWatch {
+		verb = verbPatch
+	}
+	return hm.options.Authorizer.Authorize(ctx, user, auth.Attributes{
+		Verb:      verb,
+		Group:     "apps",
+		Resource:  "deployments",
+		Namespace: namespace,
+		Name:      name,
+	})
+}
+
+// scopePermission returns the API key permission needed for verb: read for reads, the action for mutations
+func scopePermission(verb string) string {
+	switch verb {
+	case verbGet, verbList, verbWatch:
+		return auth.PermissionRead
+	default:
+		return verb
+	}
+}
+
+// checkAccess authorizes the request on deployments in the namespace. It writes an error response
+// and returns false when the request must not be served.
+func (hm *HandlerManager) checkAccess(ctx *fasthttp.RequestCtx, verb, namespace, name string, logger zerolog.Logger) bool {
+	allowed, err := hm.authorize(ctx, requestUser(ctx), verb, namespace, name)
+	if err != nil {
+		hm.writeAuthorizationErrorResponse(ctx, err, logger)
+		return false
+	}
+	if !allowed {
+		resource := "deployments"
+		if name != "" {
+			resource += "/" + name
+		}
+		logger.Warn().Str("verb", verb).Str("namespace", namespace).Msg("Forbidden request")
+		hm.writeErrorResponse(ctx, CodeForbidden, "Not allowed to "+verb+" "+resource+" in namespace "+namespace, 403, logger)
+		return false
+	}
+	return true
+}
+
+// checkAdmin authorizes an admin request on the namespace. Admin endpoints need an authenticated user:
+// API keys with the admin permission for the namespace, configured admin users and groups, or users
+// the Authorizer allows to update the namespace itself. Everybody else is denied, also when there is
+// no Authorizer. It writes an error response and returns false when the request must not be served.
+func (hm *HandlerManager) checkAdmin(ctx *fasthttp.RequestCtx, namespace string, logger zerolog.Logger) bool {
+	user := requestUser(ctx)
+	if user == nil {
+		logger.Warn().Str("namespace", namespace).Msg("Admin request without authentication")
+		hm.writeErrorResponse(ctx, CodeForbidden, "Admin endpoints are only served to authenticated users", 403, logger)
+		return false
+	}
+
+	var allowed bool
+	switch {
+	case user.Scope != nil:
+		allowed = user.Scope.Allows(auth.PermissionAdmin, namespace)
+	case hm.isConfiguredAdmin(user):
+		allowed = true
+	case hm.options.Authorizer != nil:
+		var err error
+		// The API server authorizes requests on a namespace object within the namespace itself
+		allowed, err = hm.options.Authorizer.Authorize(ctx, user, auth.Attributes{
+			Verb:      verbUpdate,
+			Resource:  "namespaces",
+			Namespace: namespace,
+			Name:      namespace,
+		})
+		if err != nil {
+			hm.writeAuthorizationErrorResponse(ctx, err, logger)
+			return false
+		}
+	}
+	if !allowed {
+		logger.Warn().Str("namespace", namespace).Msg("Forbidden admin request")
+		hm.writeErrorResponse(ctx, CodeForbidden, "Not allowed to update namespace "+namespace, 403, logger)
+		return false
+	}
+	return true
+}
+
+// isConfiguredAdmin reports whether the user or one of its groups is in AdminUsers or AdminGroups
+func (hm *HandlerManager) isConfiguredAdmin(user *auth.User) bool {
+	for _, name := range hm.options.AdminUsers {
+		if name == user.Name {
+			return true
+		}
+	}
+	for _, group := range hm.options.AdminGroups {
+		for _, userGroup := range user.Groups {
+			if group == userGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibleNamespaces returns the namespaces in which the user may perform verb on deployments, in order
+func (hm *HandlerManager) visibleNamespaces(ctx context.Context, user *auth.User, verb string, namespaces []string) ([]string, error) {
+	if !hm.accessRestricted(user) {
+		return namespaces, nil
+	}
+
+	visible := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		allowed, err := hm.authorize(ctx, user, verb, ns, "")
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			visible = append(visible, ns)
+		}
+	}
+	return visible, nil
+}
+
+// accessRestricted reports whether the user may be denied access to some namespaces: users with a
+// scope, such as API keys, and every user when an Authorizer is set
+func (hm *HandlerManager) accessRestricted(user *auth.User) bool {
+	return user != nil && (user.Scope != nil || hm.options.Authorizer != nil)
+}
+
+// requestUser returns the authenticated user of the request, or nil when authentication is off or the path is public
+func requestUser(ctx *fasthttp.RequestCtx) *auth.User {
+	user, _ := ctx.UserValue(userKey).(*auth.User)
+	return user
+}
+
+// writeUnauthorizedResponse writes a 401 problem document with a Bearer challenge
+func (hm *HandlerManager) writeUnauthorizedResponse(ctx *fasthttp.RequestCtx, message string, logger zerolog.Logger) {
+	logger.Warn().Str("path", string(ctx.Path())).Msg("Unauthenticated request")
+
+	ctx.Response.Header.Set("WWW-Authenticate", `Bearer realm="k8s-controller"`)
+	hm.writeProblemResponse(ctx, ErrorResponse{
+		Code:    CodeUnauthenticated,
+		Error:   "Unauthorized",
+		Message: message,
+	}, 401, logger)
+}
+
+// writeAuthorizationErrorResponse writes a 503 response for an authorization decision that could not be made
+func (hm *HandlerManager) writeAuthorizationErrorResponse(ctx *fasthttp.RequestCtx, err error, logger zerolog.Logger) {
+	logger.Error().Err(err).Msg("Failed to authorize request")
+	hm.writeErrorResponse(ctx, CodeAuthzUnavailable, "Authorization is temporarily unavailable", 503, logger)
+}
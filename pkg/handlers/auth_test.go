@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAuthenticator accepts the token "good" as alice and fails on "broken"
+type fakeAuthenticator struct {
+	calls atomic.Int32
+}
+
+func (f *fakeAuthenticator) AuthenticateToken(_ context.Context, token string) (*auth.User, bool, error) {
+	f.calls.Add(1)
+	switch token {
+	case "good":
+		return &auth.User{Name: "alice", Groups: []string{"developers"}}, true, nil
+	case "broken":
+		return nil, false, errors.New("token review failed")
+	}
+	return nil, false, nil
+}
+
+func newAuthTestHandler(t *testing.T, authenticator auth.Authenticator, publicPaths []string) fasthttp.RequestHandler {
+	opts := DefaultOptions()
+	opts.Authenticator = authenticator
+	opts.PublicPaths = publicPaths
+	return NewHandlerManagerWithOptions(newFakeInformerManager(t, nil), "test-version", opts).CreateHandler()
+}
+
+func authRequest(handler fasthttp.RequestHandler, path, authorization string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.SetMethod("GET")
+	if authorization != "" {
+		ctx.Request.Header.Set("Authorization", authorization)
+	}
+	handler(ctx)
+	return ctx
+}
+
+func TestHandlerManager_Authentication(t *testing.T) {
+	handler := newAuthTestHandler(t, &fakeAuthenticator{}, nil)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantCode      string
+	}{
+		{name: "valid token", path: "/api/v1/namespaces", authorization: "Bearer good", wantStatus: 200},
+		{name: "missing token", path: "/api/v1/namespaces", wantStatus: 401, wantCode: CodeUnauthenticated},
+		{name: "basic auth", path: "/namespaces", authorization: "Basic YWxpY2U6c2VjcmV0", wantStatus: 401, wantCode: CodeUnauthenticated},
+		{name: "invalid token", path: "/namespaces", authorization: "Bearer bad", wantStatus: 401, wantCode: CodeUnauthenticated},
+		{name: "unknown route", path: "/api/v1/secrets", authorization: "", wantStatus: 401, wantCode: CodeUnauthenticated},
+		{name: "review error", path: "/namespaces", authorization: "Bearer broken", wantStatus: 503, wantCode: CodeAuthUnavailable},
+		{name: "public probe", path: "/healthz", wantStatus: 200},
+		{name: "public probe with prefix", path: "/api/v1/livez", wantStatus: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := authRequest(handler, tt.path, tt.authorization)
+			assert.Equal(t, tt.wantStatus, ctx.Response.StatusCode())
+			if tt.wantCode == "" {
+				return
+			}
+
+			var response ErrorResponse
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+			if tt.wantStatus == 401 {
+				assert.Equal(t, `Bearer realm="k8s-controller"`, string(ctx.Response.Header.Peek("WWW-Authenticate")))
+			}
+		})
+	}
+}
+
+func TestHandlerManager_AuthenticationDisabled(t *testing.T) {
+	ctx := authRequest(newAuthTestHandler(t, nil, nil), "/namespaces", "")
+	assert.Equal(t, 200, ctx.Response.StatusCode())
+}
+
+func TestHandlerManager_AuthenticationPublicPaths(t *testing.T) {
+	authenticator := &fakeAuthenticator{}
+	handler := newAuthTestHandler(t, authenticator, []string{"/", "/openapi.json", "/deployments/*"})
+
+	for _, path := range []string{"/", "/api/v1", "/openapi.json", "/deployments", "/api/v1/deployments/default"} {
+		assert.NotEqual(t, 401, authRequest(handler, path, "").Response.StatusCode(), path)
+	}
+	// Probes are not public once the list is overridden
+	assert.Equal(t, 401, authRequest(handler, "/healthz", "").Response.StatusCode())
+	assert.Equal(t, 401, authRequest(handler, "/namespaces", "").Response.StatusCode())
+	assert.Zero(t, authenticator.calls.Load())
+}
+
+func TestHandlerManager_AuthenticationLogsUser(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	opts := DefaultOptions()
+	opts.Authenticator = &fakeAuthenticator{}
+	opts.Logger = &logger
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, nil), "test-version", opts).CreateHandler()
+	ctx := authRequest(handler, "/namespaces", "Bearer good")
+	require.Equal(t, 200, ctx.Response.StatusCode())
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(bytes.SplitN(buf.Bytes(), []byte("\n"), 2)[0], &entry))
+	assert.Equal(t, "HTTP request received", entry["message"])
+	assert.Equal(t, "alice", entry["user"])
+	assert.Equal(t, []interface{}{"developers"}, entry["groups"])
+	assert.Equal(t, string(ctx.Response.Header.Peek("X-Request-ID")), entry["request_id"])
+
+	user := requestUser(ctx)
+	require.NotNil(t, user)
+	assert.Equal(t, "alice", user.Name)
+}
+
+// fakeAuthorizer allows the verbs listed per namespace, for every user; err fails every decision
+type fakeAuthorizer struct {
+	allowed map[string][]string
+	err     error
+	calls   int
+}
+
+func (f *fakeAuthorizer) Authorize(_ context.Context, _ *auth.User, attrs auth.Attributes) (bool, error) {
+	f.calls++
+	if f.err != nil {
+		return false, f.err
+	}
+	if attrs.Group != "apps" || attrs.Resource != "deployments" {
+		return false, nil
+	}
+	for _, verb := range f.allowed[attrs.Namespace] {
+		if verb == attrs.Verb {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newAuthzTestHandler(t *testing.T, authorizer auth.Authorizer) fasthttp.RequestHandler {
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"},
+		newTestDeployment("team-a", "api", 1), newTestDeployment("team-b", "billing", 1))
+
+	opts := DefaultOptions()
+	opts.Authenticator = &fakeAuthenticator{}
+	opts.Authorizer = authorizer
+	return NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler()
+}
+
+func TestHandlerManager_Authorization(t *testing.T) {
+	authorizer := &fakeAuthorizer{allowed: map[string][]string{"team-a": {"list", "get"}, "team-b": {"get"}}}
+	handler := newAuthzTestHandler(t, authorizer)
+
+	t.Run("namespaces are filtered", func(t *testing.T) {
+		ctx := authRequest(handler, "/api/v1/namespaces", "Bearer good")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		var response NamespaceResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, []string{"team-a"}, response.Namespaces)
+	})
+
+	t.Run("deployments are filtered", func(t *testing.T) {
+		ctx := authRequest(handler, "/api/v1/deployments", "Bearer good")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		var response DeploymentsAllResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		require.Len(t, response.Namespaces, 1)
+		assert.Equal(t, "team-a", response.Namespaces[0].Namespace)
+		assert.Equal(t, []string{"api"}, response.Namespaces[0].Deployments)
+		assert.Equal(t, 1, response.TotalCount)
+	})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{path: "/api/v1/deployments/team-a", wantStatus: 200},
+		{path: "/api/v1/deployments/team-b", wantStatus: 403, wantCode: CodeForbidden},
+		{path: "/api/v1/deployments/team-c", wantStatus: 403, wantCode: CodeForbidden},
+		{path: "/api/v1/deployments/team-a/api", wantStatus: 200},
+		{path: "/api/v1/deployments/team-b/billing", wantStatus: 200},
+		{path: "/api/v1/deployments/team-c/api", wantStatus: 403, wantCode: CodeForbidden},
+		{path: "/api/v1/deployments/team-c/api/revisions", wantStatus: 403, wantCode: CodeForbidden},
+		{path: "/api/v1/deployments/team-b/billing/pods", wantStatus: 200},
+		{path: "/api/v1/deployments/team-c/api/pods", wantStatus: 403, wantCode: CodeForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ctx := authRequest(handler, tt.path, "Bearer good")
+			assert.Equal(t, tt.wantStatus, ctx.Response.StatusCode())
+			if tt.wantCode == "" {
+				return
+			}
+			var response ErrorResponse
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+
+	t.Run("public paths are not authorized", func(t *testing.T) {
+		calls := authorizer.calls
+		assert.Equal(t, 200, authRequest(handler, "/readyz", "").Response.StatusCode())
+		assert.Equal(t, calls, authorizer.calls)
+	})
+}
+
+// newScopedKeyOptions returns options authenticating the API key "key-a", which may only read team-a
+func newScopedKeyOptions(t *testing.T) Options {
+	t.Helper()
+	keyFile := filepath.Join(t.TempDir(), "api-keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte(`
+keys:
+  - name: team-a
+    hash: `+auth.HashAPIKey("key-a")+`
+    namespaces: [team-a]
+    permissions: [read]
+`), 0o600))
+	apiKeys, err := auth.NewAPIKeyStore(keyFile)
+	require.NoError(t, err)
+
+	opts := DefaultOptions()
+	opts.Authenticator = apiKeys
+	return opts
+}
+
+func TestHandlerManager_APIKeyScope(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte(`
+keys:
+  - name: team-a
+    hash: `+auth.HashAPIKey("key-a")+`
+    namespaces: [team-a]
+    permissions: [read, scale]
+`), 0o600))
+	apiKeys, err := auth.NewAPIKeyStore(keyFile)
+	require.NoError(t, err)
+
+	clientset := fake.NewSimpleClientset(newTestDeployment("team-a", "api", 1), newTestDeployment("team-b", "billing", 1))
+	opts := DefaultOptions()
+	opts.Authenticator = apiKeys
+	// Scoped users are not sent to the Authorizer, which would deny everything here
+	opts.Authorizer = &fakeAuthorizer{}
+	opts.EnableMutations = true
+	opts.Clientset = clientset
+	handler := NewHandlerManagerWithOptions(startFakeInformers(t, clientset, []string{"team-a", "team-b"}), "test-version", opts).CreateHandler()
+
+	ctx := authRequest(handler, "/api/v1/namespaces", "Bearer key-a")
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	var response NamespaceResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, []string{"team-a"}, response.Namespaces)
+
+	assert.Equal(t, 200, authRequest(handler, "/api/v1/deployments/team-a/api", "Bearer key-a").Response.StatusCode())
+	assert.Equal(t, 403, authRequest(handler, "/api/v1/deployments/team-b/billing", "Bearer key-a").Response.StatusCode())
+
+	post := func(uri string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.Set("Authorization", "Bearer key-a")
+		ctx.Request.SetBodyString(`{"replicas":2}`)
+		handler(ctx)
+		return ctx
+	}
+	assert.Equal(t, 200, post("/api/v1/deployments/team-a/api/scale?dryRun=true").Response.StatusCode())
+	assert.Equal(t, 403, post("/api/v1/deployments/team-b/billing/scale?dryRun=true").Response.StatusCode())
+
+	ctx = post("/api/v1/deployments/team-a/api/restart?dryRun=true")
+	assert.Equal(t, 403, ctx.Response.StatusCode())
+	var problem ErrorResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &problem))
+	assert.Equal(t, CodeForbidden, problem.Code)
+	assert.Equal(t, "Not allowed to restart deployments/api in namespace team-a", problem.Message)
+
+	assert.Equal(t, uint64(6), apiKeys.Usage()[0].Requests)
+}
+
+func TestHandlerManager_AuthorizationEvaluationError(t *testing.T) {
+	// RBAC cannot resolve a binding in team-b, as when a RoleBinding points at a deleted Role
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		if review.Spec.ResourceAttributes.Namespace == "team-b" {
+			review.Status.EvaluationError = `role.rbac.authorization.k8s.io "deleted" not found`
+		} else {
+			review.Status.Allowed = true
+		}
+		return true, review, nil
+	})
+	handler := newAuthzTestHandler(t, auth.NewSubjectAccessReviewAuthorizer(clientset, auth.SubjectAccessReviewOptions{}))
+	// The authorizer derives its timeout from the request context, which needs a server
+	request := func(path string) *fasthttp.RequestCtx {
+		var req fasthttp.Request
+		req.SetRequestURI(path)
+		req.Header.Set("Authorization", "Bearer good")
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init(&req, nil, nil)
+		handler(ctx)
+		return ctx
+	}
+
+	ctx := request("/api/v1/deployments")
+	require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+	var response DeploymentsAllResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	require.Len(t, response.Namespaces, 1)
+	assert.Equal(t, "team-a", response.Namespaces[0].Namespace)
+
+	ctx = request("/api/v1/deployments/team-b")
+	assert.Equal(t, 403, ctx.Response.StatusCode())
+}
+
+func TestHandlerManager_AuthorizationUnavailable(t *testing.T) {
+	handler := newAuthzTestHandler(t, &fakeAuthorizer{err: errors.New("subject access review failed")})
+
+	for _, path := range []string{"/namespaces", "/deployments", "/deployments/team-a", "/deployments/team-a/api"} {
+		ctx := authRequest(handler, path, "Bearer good")
+		assert.Equal(t, 503, ctx.Response.StatusCode(), path)
+
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, CodeAuthzUnavailable, response.Code, path)
+	}
+}
+
+func TestRestrictEventFilter(t *testing.T) {
+	event := func(namespace, name string) informer.DeploymentEvent {
+		return informer.DeploymentEvent{Namespace: namespace, Name: name, Deployment: newTestDeployment(namespace, name, 1)}
+	}
+
+	filter := restrictEventFilter(nil, []string{"team-a"})
+	assert.True(t, filter(event("team-a", "api")))
+	assert.False(t, filter(event("team-b", "api")))
+
+	args := fasthttp.Args{}
+	args.Set("name", "api")
+	filter = restrictEventFilter(parseEventFilter(&args), []string{"team-a", "team-b"})
+	assert.True(t, filter(event("team-b", "api")))
+	assert.False(t, filter(event("team-b", "billing")))
+	assert.False(t, filter(event("team-c", "api")))
+}
+
+// newTestCertificate creates a certificate signed by parent, or a self-signed CA when parent is nil
+func newTestCertificate(t *testing.T, subject pkix.Name, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	signer, signerKey := template, crypto.Signer(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey.(crypto.Signer)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// clientCertRequest serves a request on a TLS connection on which the client presented cert, if not nil
+func clientCertRequest(t *testing.T, handler fasthttp.RequestHandler, path string, cert *tls.Certificate) *fasthttp.RequestCtx {
+	t.Helper()
+	ca := newTestCertificate(t, pkix.Name{CommonName: "test-ca"}, nil)
+	server := newTestCertificate(t, pkix.Name{CommonName: "server"}, &ca)
+	if cert != nil && cert.Leaf == nil {
+		signed := newTestCertificate(t, pkix.Name{CommonName: "alice", Organization: []string{"admins", "developers"}}, &ca)
+		cert = &signed
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	tlsServer := tls.Server(serverConn, &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    clientCAs,
+	})
+	// The server certificate is irrelevant here, only the client certificate is checked
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+	if cert != nil {
+		clientConfig.Certificates = []tls.Certificate{*cert}
+	}
+	go func() { _ = tls.Client(clientConn, clientConfig).Handshake() }()
+	require.NoError(t, tlsServer.Handshake())
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init2(tlsServer, nil, false)
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+	return ctx
+}
+
+func TestHandlerManager_ClientCertificateAuthentication(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ClientCertAuth = true
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, nil), "test-version", opts).CreateHandler()
+
+	// A zero certificate asks clientCertRequest for one signed by the client CA
+	ctx := clientCertRequest(t, handler, "/namespaces", &tls.Certificate{})
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Equal(t, &auth.User{Name: "alice", Groups: []string{"admins", "developers"}}, requestUser(ctx))
+
+	ctx = clientCertRequest(t, handler, "/namespaces", nil)
+	assert.Equal(t, 401, ctx.Response.StatusCode())
+	assert.Equal(t, 200, clientCertRequest(t, handler, "/healthz", nil).Response.StatusCode())
+
+	// Plain HTTP requests have no certificate either
+	assert.Equal(t, 401, authRequest(handler, "/namespaces", "").Response.StatusCode())
+}
+
+func TestHandlerManager_ClientCertificateOrToken(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ClientCertAuth = true
+	opts.Authenticator = &fakeAuthenticator{}
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, nil), "test-version", opts).CreateHandler()
+
+	assert.Equal(t, 200, authRequest(handler, "/namespaces", "Bearer good").Response.StatusCode())
+	ctx := authRequest(handler, "/namespaces", "")
+	require.Equal(t, 401, ctx.Response.StatusCode())
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, "A bearer token or client certificate is required", response.Detail)
+
+	ctx = clientCertRequest(t, handler, "/namespaces", &tls.Certificate{})
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Equal(t, "alice", requestUser(ctx).Name)
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// redactedValue replaces values hidden by the redaction options
+const redactedValue = "[REDACTED]"
+
+// lastAppliedConfigAnnotation holds the full manifest (including env values) written by kubectl apply
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// DeploymentDetailResponse represents the response structure for the deployment detail endpoint
+type DeploymentDetailResponse struct {
+	Namespace         string                `json:"namespace"`
+	Name              string                `json:"name"`
+	UID               string                `json:"uid"`
+	ResourceVersion   string                `json:"resource_version"`
+	Generation        int64                 `json:"generation"`
+	CreationTimestamp time.Time             `json:"creation_timestamp"`
+	Age               string                `json:"age"`
+	Labels            map[string]string     `json:"labels,omitempty"`
+	Annotations       map[string]string     `json:"annotations,omitempty"`
+	Spec              DeploymentSpecSummary `json:"spec"`
+	Status            DeploymentStatus      `json:"status"`
+	Conditions        []DeploymentCondition `json:"conditions"`
+	Containers        []ContainerSummary    `json:"containers"`
+	Images            []string              `json:"images"`
+}
+
+// DeploymentSpecSummary summarizes the desired state of a deployment
+type DeploymentSpecSummary struct {
+	Replicas                int32  `json:"replicas"`
+	Selector                string `json:"selector"`
+	Strategy                string `json:"strategy"`
+	MaxSurge                string `json:"max_surge,omitempty"`
+	MaxUnavailable          string `json:"max_unavailable,omitempty"`
+	MinReadySeconds         int32  `json:"min_ready_seconds"`
+	RevisionHistoryLimit    *int32 `json:"revision_history_limit,omitempty"`
+	ProgressDeadlineSeconds *int32 `json:"progress_deadline_seconds,omitempty"`
+	Paused                  bool   `json:"paused"`
+}
+
+// DeploymentStatus mirrors the replica counters of a deployment status
+type DeploymentStatus struct {
+	ObservedGeneration  int64 `json:"observed_generation"`
+	Replicas            int32 `json:"replicas"`
+	UpdatedReplicas     int32 `json:"updated_replicas"`
+	ReadyReplicas       int32 `json:"ready_replicas"`
+	AvailableReplicas   int32 `json:"available_replicas"`
+	UnavailableReplicas int32 `json:"unavailable_replicas"`
+}
+
+// DeploymentCondition represents a single deployment condition
+type DeploymentCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastUpdateTime     time.Time `json:"last_update_time"`
+	LastTransitionTime time.Time `json:"last_transition_time"`
+}
+
+// ContainerSummary describes a container of the deployment's pod template
+type ContainerSummary struct {
+	Name     string             `json:"name"`
+	Image    string             `json:"image"`
+	Init     bool               `json:"init,omitempty"`
+	Ports    []int32            `json:"ports,omitempty"`
+	Env      []EnvVarSummary    `json:"env,omitempty"`
+	EnvFrom  []EnvSourceSummary `json:"env_from,omitempty"`
+	Requests map[string]string  `json:"requests,omitempty"`
+	Limits   map[string]string  `json:"limits,omitempty"`
+}
+
+// EnvVarSummary describes a container environment variable
+type EnvVarSummary struct {
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"`
+	ValueFrom string `json:"value_from,omitempty"`
+}
+
+// EnvSourceSummary describes a ConfigMap or Secret imported with envFrom
+type EnvSourceSummary struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// DeploymentSummary is a compact representation of a deployment used by streaming and mutating endpoints
+type DeploymentSummary struct {
+	Namespace         string   `json:"namespace"`
+	Name              string   `json:"name"`
+	ResourceVersion   string   `json:"resource_version"`
+	Generation        int64    `json:"generation"`
+	Replicas          int32    `json:"replicas"`
+	ReadyReplicas     int32    `json:"ready_replicas"`
+	UpdatedReplicas   int32    `json:"updated_replicas"`
+	AvailableReplicas int32    `json:"available_replicas"`
+	Paused            bool     `json:"paused"`
+	Images            []string `json:"images"`
+}
+
+// summarizeDeployment builds the compact representation of a deployment
+func summarizeDeployment(d *appsv1.Deployment) DeploymentSummary {
+	summary := DeploymentSummary{
+		Namespace:         d.Namespace,
+		Name:              d.Name,
+		ResourceVersion:   d.ResourceVersion,
+		Generation:        d.Generation,
+		Replicas:          desiredReplicas(d),
+		ReadyReplicas:     d.Status.ReadyReplicas,
+		UpdatedReplicas:   d.Status.UpdatedReplicas,
+		AvailableReplicas: d.Status.AvailableReplicas,
+		Paused:            d.Spec.Paused,
+		Images:            make([]string, 0, len(d.Spec.Template.Spec.Containers)),
+	}
+	for _, c := range d.Spec.Template.Spec.Containers {
+		summary.Images = append(summary.Images, c.Image)
+	}
+	return summary
+}
+
+// handleGetDeploymentDetail handles GET /deployments/{namespace}/{name} - returns a single cached deployment
+func (hm *HandlerManager) handleGetDeploymentDetail(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	namespace, name := pathParam(ctx, "namespace"), pathParam(ctx, "name")
+	if namespace == "" || name == "" {
+		hm.writeErrorResponse(ctx, CodeInvalidPath, "Invalid path format. Use /deployments/{namespace}/{name}", 400, logger)
+		return
+	}
+
+	logger.Info().Str("namespace", namespace).Str("name", name).Msg("Deployment detail request received")
+
+	if !hm.checkAccess(ctx, verbGet, namespace, name, logger) {
+		return
+	}
+	if !hm.informerManager.HasInformer(namespace) {
+		hm.writeErrorResponse(ctx, CodeNamespaceNotWatched, "Namespace not being watched: "+namespace, 404, logger)
+		return
+	}
+
+	deployment, found := hm.informerManager.GetDeployment(namespace, name)
+	if !found {
+		hm.writeErrorResponse(ctx, CodeDeploymentNotFound, "Deployment not found: "+namespace+"/"+name, 404, logger)
+		return
+	}
+
+	hm.writeResponse(ctx, hm.buildDeploymentDetail(deployment, time.Now()), 200, logger)
+}
+
+// buildDeploymentDetail converts a cached deployment into its API representation, applying redaction options
+func (hm *HandlerManager) buildDeploymentDetail(d *appsv1.Deployment, now time.Time) DeploymentDetailResponse {
+	resp := DeploymentDetailResponse{
+		Namespace:         d.Namespace,
+		Name:              d.Name,
+		UID:               string(d.UID),
+		ResourceVersion:   d.ResourceVersion,
+		Generation:        d.Generation,
+		CreationTimestamp: d.CreationTimestamp.Time,
+		Age:               duration.HumanDuration(now.Sub(d.CreationTimestamp.Time)),
+		Labels:            d.Labels,
+		Annotations:       hm.redactAnnotations(d.Annotations),
+		Spec:              summarizeDeploymentSpec(d),
+		Status: DeploymentStatus{
+			ObservedGeneration:  d.Status.ObservedGeneration,
+			Replicas:            d.Status.Replicas,
+			UpdatedReplicas:     d.Status.UpdatedReplicas,
+			ReadyReplicas:       d.Status.ReadyReplicas,
+			AvailableReplicas:   d.Status.AvailableReplicas,
+			UnavailableReplicas: d.Status.UnavailableReplicas,
+		},
+		Conditions: make([]DeploymentCondition, 0, len(d.Status.Conditions)),
+		Containers: make([]ContainerSummary, 0, len(d.Spec.Template.Spec.InitContainers)+len(d.Spec.Template.Spec.Containers)),
+		Images:     make([]string, 0, len(d.Spec.Template.Spec.Containers)),
+	}
+
+	for _, c := range d.Status.Conditions {
+		resp.Conditions = append(resp.Conditions, DeploymentCondition{
+			Type:               string(c.Type),
+			Status:             string(c.Status),
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastUpdateTime:     c.LastUpdateTime.Time,
+			LastTransitionTime: c.LastTransitionTime.Time,
+		})
+	}
+
+	seenImages := make(map[string]bool)
+	addContainers := func(containers []corev1.Container, init bool) {
+		for _, c := range containers {
+			resp.Containers = append(resp.Containers, hm.summarizeContainer(c, init))
+			if !seenImages[c.Image] {
+				seenImages[c.Image] = true
+				resp.Images = append(resp.Images, c.Image)
+			}
+		}
+	}
+	addContainers(d.Spec.Template.Spec.InitContainers, true)
+	addContainers(d.Spec.Template.Spec.Containers, false)
+
+	return resp
+}
+
+// summarizeDeploymentSpec extracts the user-facing fields of a deployment spec
+func summarizeDeploymentSpec(d *appsv1.Deployment) DeploymentSpecSummary {
+	spec := DeploymentSpecSummary{
+		Strategy:                string(d.Spec.Strategy.Type),
+		MinReadySeconds:         d.Spec.MinReadySeconds,
+		RevisionHistoryLimit:    d.Spec.RevisionHistoryLimit,
+		ProgressDeadlineSeconds: d.Spec.ProgressDeadlineSeconds,
+		Paused:                  d.Spec.Paused,
+	}
+	if d.Spec.Replicas != nil {
+		spec.Replicas = *d.Spec.Replicas
+	}
+	if d.Spec.Selector != nil {
+		spec.Selector = metav1.FormatLabelSelector(d.Spec.Selector)
+	}
+	if ru := d.Spec.Strategy.RollingUpdate; ru != nil {
+		if ru.MaxSurge != nil {
+			spec.MaxSurge = ru.MaxSurge.String()
+		}
+		if ru.MaxUnavailable != nil {
+			spec.MaxUnavailable = ru.MaxUnavailable.String()
+		}
+	}
+	return spec
+}
+
+// summarizeContainer converts a container spec into its API representation, applying redaction options
+func (hm *HandlerManager) summarizeContainer(c corev1.Container, init bool) ContainerSummary {
+	summary := ContainerSummary{
+		Name:  c.Name,
+		Image: c.Image,
+		Init:  init,
+	}
+
+	for _, p := range c.Ports {
+		summary.Ports = append(summary.Ports, p.ContainerPort)
+	}
+
+	for _, env := range c.Env {
+		ev := EnvVarSummary{Name: env.Name}
+		if env.ValueFrom != nil {
+			ev.ValueFrom = hm.describeEnvSource(env.ValueFrom)
+		} else if hm.options.RedactEnvValues && env.Value != "" {
+			ev.Value = redactedValue
+		} else {
+			ev.Value = env.Value
+		}
+		summary.Env = append(summary.Env, ev)
+	}
+
+	for _, src := range c.EnvFrom {
+		es := EnvSourceSummary{Prefix: src.Prefix}
+		switch {
+		case src.SecretRef != nil:
+			es.Kind = "Secret"
+			es.Name = src.SecretRef.Name
+			if hm.options.RedactSecretRefs {
+				es.Name = redactedValue
+			}
+		case src.ConfigMapRef != nil:
+			es.Kind = "ConfigMap"
+			es.Name = src.ConfigMapRef.Name
+		}
+		summary.EnvFrom = append(summary.EnvFrom, es)
+	}
+
+	summary.Requests = quantitiesToStrings(c.Resources.Requests)
+	summary.Limits = quantitiesToStrings(c.Resources.Limits)
+
+	return summary
+}
+
+// describeEnvSource renders an env var source in kubectl describe style, e.g. "secretKeyRef(db-creds/password)"
+func (hm *HandlerManager) describeEnvSource(src *corev1.EnvVarSource) string {
+	switch {
+	case src.SecretKeyRef != nil:
+		if hm.options.RedactSecretRefs {
+			return "secretKeyRef(" + redactedValue + ")"
+		}
+		return "secretKeyRef(" + src.SecretKeyRef.Name + "/" + src.SecretKeyRef.Key + ")"
+	case src.ConfigMapKeyRef != nil:
+		return "configMapKeyRef(" + src.ConfigMapKeyRef.Name + "/" + src.ConfigMapKeyRef.Key + ")"
+	case src.FieldRef != nil:
+		return "fieldRef(" + src.FieldRef.FieldPath + ")"
+	case src.ResourceFieldRef != nil:
+		return "resourceFieldRef(" + src.ResourceFieldRef.Resource + ")"
+	default:
+		return "unknown"
+	}
+}
+
+// redactAnnotations returns the annotations with the last-applied manifest hidden when env values or secret
+// references are redacted, since it contains the full pod template in plain text
+func (hm *HandlerManager) redactAnnotations(annotations map[string]string) map[string]string {
+	if _, ok := annotations[lastAppliedConfigAnnotation]; !ok || !(hm.options.RedactEnvValues || hm.options.RedactSecretRefs) {
+		return annotations
+	}
+	redacted := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		redacted[k] = v
+	}
+	redacted[lastAppliedConfigAnnotation] = redactedValue
+	return redacted
+}
+
+// quantitiesToStrings converts a resource list into a plain string map
+func quantitiesToStrings(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(list))
+	for name, q := range list {
+		out[string(name)] = q.String()
+	}
+	return out
+}
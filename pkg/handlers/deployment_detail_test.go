@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newDetailTestDeployment() *appsv1.Deployment {
+	dep := newTestDeployment("team-a", "api", 3)
+	dep.Annotations = map[string]string{
+		lastAppliedConfigAnnotation: `{"spec":{"template":{"spec":{"containers":[{"env":[{"name":"TOKEN","value":"s3cr3t"}]}]}}}}`,
+		"team":                      "a",
+	}
+	dep.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "migrate:1.0"}}
+	dep.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+		{Name: "TOKEN", Value: "s3cr3t"},
+		{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "db-creds"},
+				Key:                  "password",
+			},
+		}},
+	}
+	dep.Spec.Template.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{
+		{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "api-secrets"}}},
+	}
+	dep.Status = appsv1.DeploymentStatus{
+		Replicas:          3,
+		ReadyReplicas:     2,
+		AvailableReplicas: 2,
+		Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "MinimumReplicasAvailable"},
+		},
+	}
+	return dep
+}
+
+func TestHandlerManager_handleGetDeploymentDetail(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a"}, newDetailTestDeployment())
+	handlerManager := NewHandlerManager(informerManager, "test-version")
+	handler := handlerManager.CreateHandler()
+
+	t.Run("Found", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments/team-a/api")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		require.Equal(t, 200, ctx.Response.StatusCode())
+
+		var response DeploymentDetailResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+
+		assert.Equal(t, "team-a", response.Namespace)
+		assert.Equal(t, "api", response.Name)
+		assert.Equal(t, int32(3), response.Spec.Replicas)
+		assert.Equal(t, "app=api", response.Spec.Selector)
+		assert.Equal(t, int32(2), response.Status.ReadyReplicas)
+		assert.Equal(t, "60m", response.Age)
+		assert.Equal(t, []string{"migrate:1.0", "nginx:1.21"}, response.Images)
+		require.Len(t, response.Containers, 2)
+		assert.True(t, response.Containers[0].Init)
+		require.Len(t, response.Conditions, 1)
+		assert.Equal(t, "Available", response.Conditions[0].Type)
+		assert.Equal(t, "a", response.Annotations["team"])
+	})
+
+	t.Run("DeploymentNotFound", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments/team-a/missing")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		assert.Equal(t, 404, ctx.Response.StatusCode())
+
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Contains(t, response.Message, "Deployment not found")
+	})
+
+	t.Run("NamespaceNotWatched", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments/team-b/api")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		assert.Equal(t, 404, ctx.Response.StatusCode())
+
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Contains(t, response.Message, "Namespace not being watched")
+	})
+}
+
+func TestHandlerManager_buildDeploymentDetail_Redaction(t *testing.T) {
+	dep := newDetailTestDeployment()
+
+	t.Run("Redacted", func(t *testing.T) {
+		handlerManager := NewHandlerManager(nil, "test-version")
+		detail := handlerManager.buildDeploymentDetail(dep, dep.CreationTimestamp.Time)
+
+		env := detail.Containers[1].Env
+		require.Len(t, env, 2)
+		assert.Equal(t, redactedValue, env[0].Value)
+		assert.Equal(t, "secretKeyRef("+redactedValue+")", env[1].ValueFrom)
+		assert.Equal(t, redactedValue, detail.Containers[1].EnvFrom[0].Name)
+		assert.Equal(t, redactedValue, detail.Annotations[lastAppliedConfigAnnotation])
+		// The cached object must not be modified by redaction
+		assert.NotEqual(t, redactedValue, dep.Annotations[lastAppliedConfigAnnotation])
+	})
+
+	t.Run("Exposed", func(t *testing.T) {
+		handlerManager := NewHandlerManagerWithOptions(nil, "test-version", Options{})
+		detail := handlerManager.buildDeploymentDetail(dep, dep.CreationTimestamp.Time)
+
+		env := detail.Containers[1].Env
+		assert.Equal(t, "s3cr3t", env[0].Value)
+		assert.Equal(t, "secretKeyRef(db-creds/password)", env[1].ValueFrom)
+		assert.Equal(t, "api-secrets", detail.Containers[1].EnvFrom[0].Name)
+		assert.Equal(t, dep.Annotations[lastAppliedConfigAnnotation], detail.Annotations[lastAppliedConfigAnnotation])
+	})
+
+	t.Run("SecretRefsOnly", func(t *testing.T) {
+		handlerManager := NewHandlerManagerWithOptions(nil, "test-version", Options{RedactSecretRefs: true})
+		detail := handlerManager.buildDeploymentDetail(dep, dep.CreationTimestamp.Time)
+
+		assert.Equal(t, "s3cr3t", detail.Containers[1].Env[0].Value)
+		assert.Equal(t, "secretKeyRef("+redactedValue+")", detail.Containers[1].Env[1].ValueFrom)
+		// The last-applied manifest also holds the secret references
+		assert.Equal(t, redactedValue, detail.Annotations[lastAppliedConfigAnnotation])
+	})
+}
+
+func TestHandlerManager_handleGetDeploymentDetail_InvalidPath(t *testing.T) {
+	handlerManager := NewHandlerManager(nil, "test-version")
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/deployments/default")
+	ctx.Request.Header.SetMethod("GET")
+
+	handlerManager.handleGetDeploymentDetail(ctx, zerolog.Nop())
+
+	assert.Equal(t, 400, ctx.Response.StatusCode())
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+// listETag returns a strong ETag for a list response. It is derived from the response format, the
+// listed namespaces and the namespace, name and resourceVersion of every listed deployment, so it
+// changes whenever the informer cache hands out a new version of one of them.
+func listETag(format string, namespaces []string, items []deploymentItem) string {
+	h := newETagHash(format, namespaces)
+	for _, item := range items {
+		h.add(item.Namespace, item.Name, item.ResourceVersion)
+	}
+	return h.etag()
+}
+
+// etagHash builds the ETag of a list response item by item
+type etagHash struct {
+	hash hash.Hash
+}
+
+func newETagHash(format string, namespaces []string) *etagHash {
+	h := &etagHash{hash: sha256.New()}
+	h.add(format)
+	h.add(namespaces...)
+	return h
+}
+
+// add hashes one line of parts, separated so that no two different lines hash the same
+func (h *etagHash) add(parts ...string) {
+	for _, part := range parts {
+		h.hash.Write([]byte(part))
+		h.hash.Write([]byte{0})
+	}
+	h.hash.Write([]byte{'\n'})
+}
+
+func (h *etagHash) etag() string {
+	return `"` + hex.EncodeToString(h.hash.Sum(nil)[:16]) + `"`
+}
+
+// notModified sets the ETag and Cache-Control headers of a cacheable response. It writes a 304 and
+// returns true when If-None-Match names the ETag, the caller must not write a body then.
+func (hm *HandlerManager) notModified(ctx *fasthttp.RequestCtx, etag string, logger zerolog.Logger) bool {
+	ctx.Response.Header.Set("ETag", etag)
+	// Clients may keep the response but must revalidate it, the cache changes at any time. Responses
+	// depend on what the caller may see, so shared caches must not keep them when callers authenticate.
+	if hm.authenticationEnabled() {
+		ctx.Response.Header.Set("Cache-Control", "private, no-cache")
+	} else {
+		ctx.Response.Header.Set("Cache-Control", "no-cache")
+	}
+
+	if !etagMatches(string(ctx.Request.Header.Peek("If-None-Match")), etag) {
+		return false
+	}
+	// Unlike ctx.NotModified this keeps the headers already set, such as ETag, Vary and X-Request-ID
+	ctx.SetStatusCode(fasthttp.StatusNotModified)
+	ctx.Response.ResetBody()
+	logger.Info().Int("status_code", fasthttp.StatusNotModified).Msg("Response not modified")
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header names the ETag, using the weak comparison
+// RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(``, `"abc"`))
+	assert.False(t, etagMatches(`"abcd"`, `"abc"`))
+}
+
+func TestHandlerManager_ConditionalGet(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestDeployment("team-a", "api", 1), newTestDeployment("team-b", "web", 1))
+	informerManager := startFakeInformers(t, clientset, []string{"team-a", "team-b"})
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+	get := func(uri, ifNoneMatch string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.SetMethod("GET")
+		if ifNoneMatch != "" {
+			ctx.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		handler(ctx)
+		return ctx
+	}
+	require.Eventually(t, func() bool {
+		return len(informerManager.ListDeployments("team-a")) == 1 && len(informerManager.ListDeployments("team-b")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, uri := range []string{"/deployments", "/deployments/team-a", "/namespaces"} {
+		t.Run(uri, func(t *testing.T) {
+			ctx := get(uri, "")
+			require.Equal(t, 200, ctx.Response.StatusCode())
+			etag := string(ctx.Response.Header.Peek("ETag"))
+			require.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+			assert.Equal(t, "no-cache", string(ctx.Response.Header.Peek("Cache-Control")))
+
+			ctx = get(uri, etag)
+			assert.Equal(t, 304, ctx.Response.StatusCode())
+			assert.Empty(t, ctx.Response.Body())
+			assert.Equal(t, etag, string(ctx.Response.Header.Peek("ETag")))
+			assert.NotEmpty(t, ctx.Response.Header.Peek("X-Request-ID"))
+
+			// Each format is a representation of its own
+			assert.Equal(t, 200, get(uri+"?output=yaml", etag).Response.StatusCode())
+		})
+	}
+
+	etags := map[string]string{}
+	for _, uri := range []string{"/deployments", "/deployments/team-a", "/deployments/team-b"} {
+		etags[uri] = string(get(uri, "").Response.Header.Peek("ETag"))
+	}
+
+	// A new resourceVersion of a listed deployment changes the ETag of the lists including it
+	updated := newTestDeployment("team-a", "api", 2)
+	updated.ResourceVersion = "2"
+	_, err := clientset.AppsV1().Deployments("team-a").Update(context.Background(), updated, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		d, ok := informerManager.GetDeployment("team-a", "api")
+		return ok && d.ResourceVersion == "2"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 200, get("/deployments", etags["/deployments"]).Response.StatusCode())
+	assert.Equal(t, 200, get("/deployments/team-a", etags["/deployments/team-a"]).Response.StatusCode())
+	assert.Equal(t, 304, get("/deployments/team-b", etags["/deployments/team-b"]).Response.StatusCode())
+
+	// Pages followed by another one are not cacheable
+	ctx := get("/deployments?limit=1", "")
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek("ETag"))
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Field selector keys supported by the /deployments endpoints
+const (
+	fieldMetadataName      = "metadata.name"
+	fieldMetadataNamespace = "metadata.namespace"
+	fieldSpecPaused        = "spec.paused"
+	fieldStatusAvailable   = "status.available"
+)
+
+// supportedDeploymentFields lists the keys accepted in the fieldSelector query parameter
+var supportedDeploymentFields = []string{fieldMetadataName, fieldMetadataNamespace, fieldSpecPaused, fieldStatusAvailable}
+
+// parameterError describes an invalid query parameter
+type parameterError struct {
+	Parameter string
+	Err       error
+}
+
+func (e *parameterError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Parameter, e.Err)
+}
+
+// deploymentFilter selects deployments from the informer cache based on query parameters
+type deploymentFilter struct {
+	labelSelector labels.Selector
+	fieldSelector fields.Selector
+	namePrefix    string
+	available     *bool
+	paused        *bool
+	images        []string
+}
+
+// parseDeploymentFilter builds a deployment filter from the labelSelector, fieldSelector,
+// namePrefix, available, paused and image query parameters
+func parseDeploymentFilter(args *fasthttp.Args) (*deploymentFilter, error) {
+	filter := &deploymentFilter{
+		labelSelector: labels.Everything(),
+		fieldSelector: fields.Everything(),
+		namePrefix:    string(args.Peek("namePrefix")),
+	}
+
+	if raw := string(args.Peek("labelSelector")); raw != "" {
+		selector, err := labels.Parse(raw)
+		if err != nil {
+			return nil, &parameterError{Parameter: "labelSelector", Err: err}
+		}
+		filter.labelSelector = selector
+	}
+
+	if raw := string(args.Peek("fieldSelector")); raw != "" {
+		selector, err := fields.ParseSelector(raw)
+		if err != nil {
+			return nil, &parameterError{Parameter: "fieldSelector", Err: err}
+		}
+		for _, req := range selector.Requirements() {
+			if !isSupportedDeploymentField(req.Field) {
+				return nil, &parameterError{
+					Parameter: "fieldSelector",
+					Err:       fmt.Errorf("field %q is not supported, use one of: %s", req.Field, strings.Join(supportedDeploymentFields, ", ")),
+				}
+			}
+			if req.Field == fieldSpecPaused || req.Field == fieldStatusAvailable {
+				if _, err := strconv.ParseBool(req.Value); err != nil {
+					return nil, &parameterError{Parameter: "fieldSelector", Err: fmt.Errorf("field %q requires a boolean value", req.Field)}
+				}
+			}
+		}
+		filter.fieldSelector = selector
+	}
+
+	var err error
+	if filter.available, err = parseOptionalBool(args, "available"); err != nil {
+		return nil, err
+	}
+	if filter.paused, err = parseOptionalBool(args, "paused"); err != nil {
+		return nil, err
+	}
+
+	for _, image := range args.PeekMulti("image") {
+		if len(image) == 0 {
+			return nil, &parameterError{Parameter: "image", Err: fmt.Errorf("image must not be empty")}
+		}
+		filter.images = append(filter.images, string(image))
+	}
+
+	return filter, nil
+}
+
+// parseOptionalBool parses a boolean query parameter, returning nil when it is absent
+func parseOptionalBool(args *fasthttp.Args, name string) (*bool, error) {
+	if !args.Has(name) {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(string(args.Peek(name)))
+	if err != nil {
+		return nil, &parameterError{Parameter: name, Err: fmt.Errorf("must be true or false")}
+	}
+	return &value, nil
+}
+
+func isSupportedDeploymentField(field string) bool {
+	for _, f := range supportedDeploymentFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty reports whether the filter matches every deployment
+func (f *deploymentFilter) isEmpty() bool {
+	return f.labelSelector.Empty() && f.fieldSelector.Empty() && f.namePrefix == "" &&
+		f.available == nil && f.paused == nil && len(f.images) == 0
+}
+
+// Matches reports whether the deployment satisfies every condition of the filter
+func (f *deploymentFilter) Matches(d *appsv1.Deployment) bool {
+	if !f.labelSelector.Matches(labels.Set(d.Labels)) {
+		return false
+	}
+	if !f.fieldSelector.Matches(deploymentFields(d)) {
+		return false
+	}
+	if f.namePrefix != "" && !strings.HasPrefix(d.Name, f.namePrefix) {
+		return false
+	}
+	if f.available != nil && *f.available != isFullyAvailable(d) {
+		return false
+	}
+	if f.paused != nil && *f.paused != d.Spec.Paused {
+		return false
+	}
+	if len(f.images) > 0 && !deploymentUsesAnyImage(d, f.images) {
+		return false
+	}
+	return true
+}
+
+// deploymentFields returns the field set evaluated by field selectors
+func deploymentFields(d *appsv1.Deployment) fields.Set {
+	return fields.Set{
+		fieldMetadataName:      d.Name,
+		fieldMetadataNamespace: d.Namespace,
+		fieldSpecPaused:        strconv.FormatBool(d.Spec.Paused),
+		fieldStatusAvailable:   strconv.FormatBool(isFullyAvailable(d)),
+	}
+}
+
+// desiredReplicas returns the desired replica count, defaulting to 1 like the API server does
+func desiredReplicas(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+// isFullyAvailable reports whether all desired replicas of the deployment are available
+func isFullyAvailable(d *appsv1.Deployment) bool {
+	return d.Status.AvailableReplicas >= desiredReplicas(d)
+}
+
+// deploymentUsesAnyImage reports whether any container of the deployment runs one of the images.
+// An image without a tag or digest matches every tag of that repository.
+func deploymentUsesAnyImage(d *appsv1.Deployment, images []string) bool {
+	for _, containers := range [][]corev1.Container{d.Spec.Template.Spec.InitContainers, d.Spec.Template.Spec.Containers} {
+		for _, c := range containers {
+			for _, image := range images {
+				if c.Image == image || (!hasImageTagOrDigest(image) && imageRepository(c.Image) == image) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// imageRepository strips the tag and digest from an image reference
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	// A colon after the last slash separates the tag; earlier colons belong to a registry port
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// hasImageTagOrDigest reports whether the image reference pins a tag or digest
+func hasImageTagOrDigest(image string) bool {
+	return imageRepository(image) != image
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseDeploymentFilter_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		parameter string
+	}{
+		{"invalid label selector", "labelSelector=app%20in%20(", "labelSelector"},
+		{"invalid field selector syntax", "fieldSelector=metadata.name", "fieldSelector"},
+		{"unsupported field", "fieldSelector=spec.replicas%3D3", "fieldSelector"},
+		{"non-boolean field value", "fieldSelector=spec.paused%3Dmaybe", "fieldSelector"},
+		{"invalid available", "available=yes", "available"},
+		{"invalid paused", "paused=2", "paused"},
+		{"empty image", "image=", "image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := fasthttp.AcquireArgs()
+			defer fasthttp.ReleaseArgs(args)
+			args.Parse(tt.query)
+
+			_, err := parseDeploymentFilter(args)
+			require.Error(t, err)
+
+			perr, ok := err.(*parameterError)
+			require.True(t, ok)
+			assert.Equal(t, tt.parameter, perr.Parameter)
+		})
+	}
+}
+
+func TestDeploymentFilter_Matches(t *testing.T) {
+	web := newTestDeployment("team-a", "web-frontend", 2)
+	web.Labels["tier"] = "frontend"
+	web.Status.AvailableReplicas = 2
+
+	worker := newTestDeployment("team-a", "worker", 3)
+	worker.Labels["tier"] = "backend"
+	worker.Spec.Paused = true
+	worker.Status.AvailableReplicas = 1
+	worker.Spec.Template.Spec.Containers[0].Image = "registry.local:5000/team/worker@sha256:abc"
+	worker.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "busybox:1.36"}}
+
+	tests := []struct {
+		query string
+		web   bool
+		work  bool
+	}{
+		{"", true, true},
+		{"labelSelector=tier%3Dfrontend", true, false},
+		{"labelSelector=tier%20in%20(frontend,backend),app!%3Dweb-frontend", false, true},
+		{"fieldSelector=metadata.name%3Dworker", false, true},
+		{"fieldSelector=status.available%3Dtrue", true, false},
+		{"fieldSelector=spec.paused!%3Dtrue", true, false},
+		{"namePrefix=web-", true, false},
+		{"available=false", false, true},
+		{"paused=true", false, true},
+		{"image=nginx:1.21", true, false},
+		{"image=nginx", true, false},
+		{"image=nginx:1.20", false, false},
+		{"image=registry.local:5000/team/worker", false, true},
+		{"image=busybox:1.36", false, true},
+		{"image=busybox:1.36&image=nginx", true, true},
+		{"labelSelector=tier%3Dfrontend&paused=true", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			args := fasthttp.AcquireArgs()
+			defer fasthttp.ReleaseArgs(args)
+			args.Parse(tt.query)
+
+			filter, err := parseDeploymentFilter(args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.web, filter.Matches(web), "web-frontend")
+			assert.Equal(t, tt.work, filter.Matches(worker), "worker")
+		})
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                          "nginx",
+		"nginx:1.21":                     "nginx",
+		"registry.local:5000/nginx":      "registry.local:5000/nginx",
+		"registry.local:5000/nginx:1.21": "registry.local:5000/nginx",
+		"nginx@sha256:abc":               "nginx",
+		"nginx:1.21@sha256:abc":          "nginx",
+	}
+	for image, want := range tests {
+		assert.Equal(t, want, imageRepository(image), image)
+	}
+}
+
+func TestHandlerManager_FilteredDeployments(t *testing.T) {
+	frontend := newTestDeployment("team-a", "frontend", 1)
+	frontend.Labels["tier"] = "web"
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"},
+		frontend,
+		newTestDeployment("team-a", "backend", 1),
+		newTestDeployment("team-b", "frontend-canary", 1),
+	)
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+
+	t.Run("ByNamespace", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments/team-a?labelSelector=tier%3Dweb")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		require.Equal(t, 200, ctx.Response.StatusCode())
+
+		var response DeploymentResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, []string{"frontend"}, response.Deployments)
+		assert.Equal(t, 1, response.Count)
+	})
+
+	t.Run("AllNamespaces", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments?namePrefix=frontend")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		require.Equal(t, 200, ctx.Response.StatusCode())
+
+		var response DeploymentsAllResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, 2, response.TotalCount)
+		assert.Len(t, response.Namespaces, 2)
+	})
+
+	t.Run("InvalidSelector", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments?labelSelector=%3D%3D")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		require.Equal(t, 400, ctx.Response.StatusCode())
+
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, "Invalid Parameter", response.Error)
+		assert.Equal(t, "labelSelector", response.Parameter)
+		assert.NotEmpty(t, response.Message)
+	})
+}
@@ -1,78 +1,268 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"net/url"
+	"errors"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+	"github.com/vanelin/k8s-controller/pkg/health"
 	"github.com/vanelin/k8s-controller/pkg/informer"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 // DeploymentResponse represents the response structure for deployment endpoints
 type DeploymentResponse struct {
-	Namespace   string   `json:"namespace"`
-	Deployments []string `json:"deployments"`
-	Count       int      `json:"count"`
+	Namespace          string   `json:"namespace"`
+	Deployments        []string `json:"deployments"`
+	Count              int      `json:"count"`
+	Continue           string   `json:"continue,omitempty"`
+	RemainingItemCount *int64   `json:"remaining_item_count,omitempty"`
 }
 
 // NamespaceResponse represents the response structure for namespace endpoints
 type NamespaceResponse struct {
 	Namespaces []string `json:"namespaces"`
 	Count      int      `json:"count"`
+	// Statuses has the informer state of each namespace, only with status=true
+	Statuses []NamespaceStatus `json:"statuses,omitempty"`
 }
 
-// ErrorResponse represents error response structure
+// RootResponse is returned by the root endpoint
+type RootResponse struct {
+	Message   string            `json:"message"`
+	Version   string            `json:"version"`
+	Endpoints map[string]string `json:"endpoints"`
+}
+
+// ErrorResponse is the RFC 7807 problem document returned with Content-Type application/problem+json.
+// Error and Message repeat Title and Detail in the format used before problem documents.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Parameter string `json:"parameter,omitempty"`
 }
 
+// Error codes of ErrorResponse. They are stable, clients should match on them rather than on messages.
+const (
+	CodeRouteNotFound        = "route_not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeInvalidPath          = "invalid_path"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeInvalidBody          = "invalid_body"
+	CodeNotAcceptable        = "not_acceptable"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeContinueExpired      = "continue_expired"
+	CodeNoNamespaces         = "no_namespaces_watched"
+	CodeNamespaceNotWatched  = "namespace_not_watched"
+	CodeDeploymentNotFound   = "deployment_not_found"
+	CodeRevisionNotFound     = "revision_not_found"
+	CodeDeploymentPaused     = "deployment_paused"
+	CodeMutationsDisabled    = "mutations_disabled"
+	CodeClientUnavailable    = "client_unavailable"
+	CodeKubernetesAPI        = "kubernetes_api_error"
+	CodeNamespaceSyncTimeout = "namespace_sync_timeout"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeAuthUnavailable      = "authentication_unavailable"
+	CodeForbidden            = "forbidden"
+	CodeAuthzUnavailable     = "authorization_unavailable"
+	CodeRateLimited          = "rate_limited"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeInternal             = "internal_error"
+)
+
+// problemContentType is the media type of ErrorResponse
+const problemContentType = "application/problem+json"
+
 // DeploymentsAllResponse represents the response for all deployments across namespaces
 type DeploymentsAllResponse struct {
-	Namespaces []DeploymentResponse `json:"namespaces"`
-	TotalCount int                  `json:"total_count"`
+	Namespaces         []DeploymentResponse `json:"namespaces"`
+	TotalCount         int                  `json:"total_count"`
+	Continue           string               `json:"continue,omitempty"`
+	RemainingItemCount *int64               `json:"remaining_item_count,omitempty"`
+}
+
+// Options configures optional behaviour of the HandlerManager
+type Options struct {
+	// RedactEnvValues hides literal container env values in deployment detail responses
+	RedactEnvValues bool
+	// RedactSecretRefs hides the names and keys of Secrets referenced from container env
+	RedactSecretRefs bool
+	// ListSnapshotTTL is how long continue tokens of paginated lists stay valid (default 5m)
+	ListSnapshotTTL time.Duration
+	// StreamHeartbeatInterval is how often event streams send a keep-alive or WebSocket ping (default 15s)
+	StreamHeartbeatInterval time.Duration
+	// StreamBufferSize is the number of events queued per stream before a slow client is dropped
+	StreamBufferSize int
+	// EnableMutations turns on the scale, restart, pause, resume and image endpoints
+	EnableMutations bool
+	// Clientset is used by the mutating endpoints to patch deployments
+	Clientset kubernetes.Interface
+	// Health is reported by the probe endpoints. When nil, only the informer sync state is reported.
+	Health *health.State
+	// Authenticator validates bearer tokens. When nil, requests are not authenticated.
+	Authenticator auth.Authenticator
+	// ClientCertAuth identifies callers by verified TLS client certificates and rejects requests without
+	// one, unless they authenticate with a bearer token
+	ClientCertAuth bool
+	// PublicPaths are served without authentication, relative to APIPrefix (default DefaultPublicPaths)
+	PublicPaths []string
+	// Authorizer limits authenticated users to the namespaces they may access. When nil, every user sees everything.
+	Authorizer auth.Authorizer
+	// ReadRateLimit and WriteRateLimit are the requests per second each client may send to GET and to
+	// mutating routes, keyed by authenticated user or remote IP. Zero disables the limit.
+	ReadRateLimit  float64
+	WriteRateLimit float64
+	// ReadRateBurst and WriteRateBurst are the requests a client may send at once (default one second worth)
+	ReadRateBurst  int
+	WriteRateBurst int
+	// MaxInFlight caps the requests handled at the same time across all clients. Zero disables the cap.
+	MaxInFlight int
+	// AdminUsers and AdminGroups may start and stop namespace watches without an Authorizer. Users with
+	// a scope, such as API keys, need the admin permission instead.
+	AdminUsers  []string
+	AdminGroups []string
+	// WatchSyncTimeout bounds how long POST /namespaces/{namespace}/watch waits for a namespace to sync
+	// (default DefaultWatchSyncTimeout)
+	WatchSyncTimeout time.Duration
+	// InformerContext bounds the informers started by POST /namespaces/{namespace}/watch (default context.Background())
+	InformerContext context.Context
+	// AccessLog writes a line per request. When set, successful reads are left out of the application log.
+	AccessLog *AccessLog
+	// Logger is the application log of the requests (default the global zerolog logger)
+	Logger *zerolog.Logger
+}
+
+// DefaultOptions returns the options used by NewHandlerManager
+func DefaultOptions() Options {
+	return Options{
+		RedactEnvValues:  true,
+		RedactSecretRefs: true,
+	}
 }
 
 // HandlerManager manages HTTP handlers with access to the informer manager
 type HandlerManager struct {
 	informerManager *informer.DeploymentInformerManager
 	appVersion      string
+	options         Options
+	listSnapshots   *listSnapshotStore
+	listCache       *listCache
+	health          *health.State
+	readLimiter     *clientRateLimiter
+	writeLimiter    *clientRateLimiter
+	inFlight        atomic.Int64
+	// watchLocks serializes POST and DELETE /namespaces/{namespace}/watch per namespace
+	watchLocks namespaceLocks
+}
+
+// logger returns the application logger of the requests
+func (hm *HandlerManager) logger() *zerolog.Logger {
+	if hm.options.Logger != nil {
+		return hm.options.Logger
+	}
+	return &log.Logger
 }
 
 // NewHandlerManager creates a new handler manager
 func NewHandlerManager(informerManager *informer.DeploymentInformerManager, appVersion string) *HandlerManager {
+	return NewHandlerManagerWithOptions(informerManager, appVersion, DefaultOptions())
+}
+
+// NewHandlerManagerWithOptions creates a new handler manager with custom options
+func NewHandlerManagerWithOptions(informerManager *informer.DeploymentInformerManager, appVersion string, options Options) *HandlerManager {
+	healthState := options.Health
+	if healthState == nil {
+		healthState = health.NewState()
+		if informerManager != nil {
+			healthState.SetInformerStatus(informerManager.SyncStatus)
+		}
+	}
+
 	return &HandlerManager{
 		informerManager: informerManager,
 		appVersion:      appVersion,
+		options:         options,
+		listSnapshots:   newListSnapshotStore(options.ListSnapshotTTL),
+		listCache:       newListCache(),
+		health:          healthState,
+		readLimiter:     newClientRateLimiter(options.ReadRateLimit, options.ReadRateBurst),
+		writeLimiter:    newClientRateLimiter(options.WriteRateLimit, options.WriteRateBurst),
 	}
 }
 
 // CreateHandler creates the main HTTP handler with routing
 func (hm *HandlerManager) CreateHandler() fasthttp.RequestHandler {
+	router := newRouter(hm.routes())
+
 	return func(ctx *fasthttp.RequestCtx) {
-		requestID := uuid.New().String()
+		start := time.Now()
+		requestID := requestID(ctx)
 		ctx.Response.Header.Set("X-Request-ID", requestID)
 
-		logger := log.With().Str("request_id", requestID).Logger()
+		logger := hm.logger().With().Str("request_id", requestID).Logger()
 
 		path := string(ctx.Path())
 		method := string(ctx.Method())
 
+		route, allowed := router.match(ctx, path, method)
+		if hm.options.AccessLog != nil {
+			defer hm.options.AccessLog.log(ctx, routeLabel(route), requestID, start)
+		}
+		defer instrumentRequest(ctx, routeLabel(route), methodLabel(method))()
+		// Probes are polled every few seconds, only log them when they fail. With an access log, reads
+		// only reach the application log with warnings and errors; changes are always logged.
+		if route != nil && route.quiet || hm.options.AccessLog != nil && !isWriteRequest(method) {
+			logger = logger.Level(zerolog.WarnLevel)
+		}
+
+		// Probes are exempt from the limits, so a busy server is not restarted by its kubelet. Streams only
+		// count while they are set up, the connection is handed off when the handler returns.
+		limited := route == nil || !route.quiet
+		if limited {
+			if !hm.acquireInFlight(ctx, logger) {
+				return
+			}
+			defer hm.releaseInFlight()
+		}
+
+		// Failed authentications count against the remote IP, which is throttled before authenticating
+		if limited && !hm.checkAuthFailureLimit(ctx, method, path, logger) {
+			return
+		}
+		if !hm.authenticate(ctx, path, &logger) {
+			if limited {
+				hm.chargeAuthFailure(ctx, method)
+			}
+			return
+		}
+		if limited && !hm.checkRateLimit(ctx, method, logger) {
+			return
+		}
+
 		logger.Info().Str("method", method).Str("path", path).Msg("HTTP request received")
 
 		switch {
-		case path == "/deployments" && method == "GET":
-			hm.handleGetDeployments(ctx, logger)
-		case strings.HasPrefix(path, "/deployments/") && method == "GET":
-			hm.handleGetDeploymentsByNamespace(ctx, logger)
-		case path == "/namespaces" && method == "GET":
-			hm.handleGetNamespaces(ctx, logger)
-		case path == "/" && method == "GET":
-			hm.handleRoot(ctx, logger)
+		case route != nil:
+			if route.negotiatesFormat() && !hm.negotiateFormat(ctx, logger) {
+				return
+			}
+			route.handler(ctx, logger)
+		case len(allowed) > 0:
+			hm.handleMethodNotAllowed(ctx, allowed, logger)
 		default:
 			hm.handleNotFound(ctx, logger)
 		}
@@ -83,94 +273,234 @@ func (hm *HandlerManager) CreateHandler() fasthttp.RequestHandler {
 func (hm *HandlerManager) handleGetDeployments(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
 	logger.Info().Msg("Deployments request received")
 
-	availableNamespaces := hm.informerManager.GetAvailableNamespaces()
-	if len(availableNamespaces) == 0 {
-		hm.writeErrorResponse(ctx, "No namespaces are being watched", 404, logger)
+	filter, err := parseDeploymentFilter(ctx.QueryArgs())
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return
+	}
+	opts, err := parseListOptions(ctx.QueryArgs())
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return
+	}
+
+	if len(hm.informerManager.GetAvailableNamespaces()) == 0 {
+		hm.writeErrorResponse(ctx, CodeNoNamespaces, "No namespaces are being watched", 404, logger)
+		return
+	}
+	// Namespaces the caller may not list are left out rather than failing the whole request
+	availableNamespaces, err := hm.visibleNamespaces(ctx, requestUser(ctx), verbList, hm.informerManager.GetAvailableNamespaces())
+	if err != nil {
+		hm.writeAuthorizationErrorResponse(ctx, err, logger)
+		return
+	}
+
+	if hm.listCacheable(ctx, filter, opts) {
+		encoded, err := hm.listCache.allNamespaces(hm.informerManager.Snapshot(), availableNamespaces)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to encode deployments")
+			hm.writeErrorResponse(ctx, CodeInternal, "Failed to serialize response", 500, logger)
+			return
+		}
+		hm.writeEncodedList(ctx, encoded, logger)
 		return
 	}
 
-	var responses []DeploymentResponse
-	total := 0
-	for _, ns := range availableNamespaces {
-		deployments := hm.informerManager.GetDeploymentNames(ns)
-		resp := DeploymentResponse{
-			Namespace:   ns,
-			Deployments: deployments,
-			Count:       len(deployments),
+	page, err := hm.paginate(opts, listFingerprint(ctx), func() []deploymentItem {
+		var items []deploymentItem
+		for _, ns := range availableNamespaces {
+			items = append(items, hm.collectDeploymentItems(ns, filter, opts)...)
 		}
-		responses = append(responses, resp)
-		total += len(deployments)
+		return items
+	})
+	if err != nil {
+		hm.writeListErrorResponse(ctx, err, logger)
+		return
+	}
+
+	// Unpaginated responses list every watched namespace, even when it has no matching deployments
+	namespaces := availableNamespaces
+	if opts.limit > 0 || opts.continueAt != "" {
+		namespaces = nil
+	}
+
+	// Pages followed by another one carry a fresh continue token and are never the same
+	if page.continueAt == "" && hm.notModified(ctx, listETag(requestFormat(ctx).name, namespaces, page.items), logger) {
+		return
 	}
 
 	allResp := DeploymentsAllResponse{
-		Namespaces: responses,
-		TotalCount: total,
+		Namespaces:         groupDeploymentItems(page.items, namespaces),
+		TotalCount:         len(page.items),
+		Continue:           page.continueAt,
+		RemainingItemCount: page.remaining,
 	}
 
-	hm.writeJSONResponse(ctx, allResp, 200, logger)
+	hm.writeResponse(ctx, allResp, 200, logger)
 }
 
 // handleGetDeploymentsByNamespace handles GET /deployments/{namespace} - returns deployments from specific namespace
 func (hm *HandlerManager) handleGetDeploymentsByNamespace(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
-	path := string(ctx.Path())
-	parts := strings.Split(path, "/")
-
-	if len(parts) != 3 {
-		hm.writeErrorResponse(ctx, "Invalid path format. Use /deployments/{namespace}", 400, logger)
+	namespace := pathParam(ctx, "namespace")
+	if namespace == "" {
+		hm.writeErrorResponse(ctx, CodeInvalidPath, "Invalid path format. Use /deployments/{namespace}", 400, logger)
 		return
 	}
 
-	namespace := parts[2]
-	// URL decode the namespace
-	decodedNamespace, err := url.QueryUnescape(namespace)
+	logger.Info().Str("namespace", namespace).Msg("Deployments by namespace request received")
+
+	filter, err := parseDeploymentFilter(ctx.QueryArgs())
 	if err != nil {
-		hm.writeErrorResponse(ctx, "Invalid namespace encoding", 400, logger)
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return
+	}
+	opts, err := parseListOptions(ctx.QueryArgs())
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
 		return
 	}
 
-	logger.Info().Str("namespace", decodedNamespace).Msg("Deployments by namespace request received")
-
+	if !hm.checkAccess(ctx, verbList, namespace, "", logger) {
+		return
+	}
 	// Check if informer exists for this namespace
-	if !hm.informerManager.HasInformer(decodedNamespace) {
-		hm.writeErrorResponse(ctx, "Namespace not being watched: "+decodedNamespace, 404, logger)
+	if !hm.informerManager.HasInformer(namespace) {
+		hm.writeErrorResponse(ctx, CodeNamespaceNotWatched, "Namespace not being watched: "+namespace, 404, logger)
+		return
+	}
+
+	if snapshot, ok := hm.informerManager.Snapshot().Namespace(namespace); ok && hm.listCacheable(ctx, filter, opts) {
+		encoded, err := hm.listCache.namespace(snapshot)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to encode deployments")
+			hm.writeErrorResponse(ctx, CodeInternal, "Failed to serialize response", 500, logger)
+			return
+		}
+		hm.writeEncodedList(ctx, encoded, logger)
+		return
+	}
+
+	page, err := hm.paginate(opts, listFingerprint(ctx), func() []deploymentItem {
+		return hm.collectDeploymentItems(namespace, filter, opts)
+	})
+	if err != nil {
+		hm.writeListErrorResponse(ctx, err, logger)
 		return
 	}
 
-	deployments := hm.informerManager.GetDeploymentNames(decodedNamespace)
+	if page.continueAt == "" && hm.notModified(ctx, listETag(requestFormat(ctx).name, []string{namespace}, page.items), logger) {
+		return
+	}
+
+	deployments := make([]string, 0, len(page.items))
+	for _, item := range page.items {
+		deployments = append(deployments, item.Name)
+	}
 
 	response := DeploymentResponse{
-		Namespace:   decodedNamespace,
-		Deployments: deployments,
-		Count:       len(deployments),
+		Namespace:          namespace,
+		Deployments:        deployments,
+		Count:              len(deployments),
+		Continue:           page.continueAt,
+		RemainingItemCount: page.remaining,
 	}
 
-	hm.writeJSONResponse(ctx, response, 200, logger)
+	hm.writeResponse(ctx, response, 200, logger)
+}
+
+// collectDeploymentItems returns the cached deployments of a namespace that match the filter, in the requested order
+func (hm *HandlerManager) collectDeploymentItems(namespace string, filter *deploymentFilter, opts listOptions) []deploymentItem {
+	var matched []*appsv1.Deployment
+	for _, d := range hm.informerManager.ListDeployments(namespace) {
+		if filter.Matches(d) {
+			matched = append(matched, d)
+		}
+	}
+	sortDeployments(matched, opts)
+
+	items := make([]deploymentItem, 0, len(matched))
+	for _, d := range matched {
+		items = append(items, deploymentItem{Namespace: d.Namespace, Name: d.Name, ResourceVersion: d.ResourceVersion})
+	}
+	return items
+}
+
+// groupDeploymentItems groups consecutive items by namespace. Namespaces listed in namespaces
+// are always present in the result, in that order, even if they have no items.
+func groupDeploymentItems(items []deploymentItem, namespaces []string) []DeploymentResponse {
+	responses := make([]DeploymentResponse, 0, len(namespaces))
+	index := make(map[string]int, len(namespaces))
+	for _, ns := range namespaces {
+		index[ns] = len(responses)
+		responses = append(responses, DeploymentResponse{Namespace: ns, Deployments: []string{}})
+	}
+
+	for _, item := range items {
+		i, ok := index[item.Namespace]
+		if !ok {
+			i = len(responses)
+			index[item.Namespace] = i
+			responses = append(responses, DeploymentResponse{Namespace: item.Namespace, Deployments: []string{}})
+		}
+		responses[i].Deployments = append(responses[i].Deployments, item.Name)
+		responses[i].Count++
+	}
+	return responses
 }
 
 // handleGetNamespaces handles GET /namespaces - returns list of available namespaces
 func (hm *HandlerManager) handleGetNamespaces(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
 	logger.Info().Msg("Namespaces request received")
 
-	namespaces := hm.informerManager.GetAvailableNamespaces()
+	status, err := parseOptionalBool(ctx.QueryArgs(), "status")
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return
+	}
+
+	namespaces, err := hm.visibleNamespaces(ctx, requestUser(ctx), verbList, hm.informerManager.GetAvailableNamespaces())
+	if err != nil {
+		hm.writeAuthorizationErrorResponse(ctx, err, logger)
+		return
+	}
 
 	response := NamespaceResponse{
 		Namespaces: namespaces,
 		Count:      len(namespaces),
 	}
+	if status != nil && *status {
+		// The status changes with every event, so these responses carry no ETag
+		response.Statuses = hm.namespaceStatuses(namespaces)
+	} else if hm.notModified(ctx, listETag(requestFormat(ctx).name, namespaces, nil), logger) {
+		return
+	}
 
-	hm.writeJSONResponse(ctx, response, 200, logger)
+	hm.writeResponse(ctx, response, 200, logger)
 }
 
 // handleRoot handles GET / - returns basic API information
 func (hm *HandlerManager) handleRoot(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
 	logger.Info().Msg("Root request received")
 
-	response := map[string]interface{}{
-		"message": "Kubernetes Controller API",
-		"version": hm.appVersion,
-		"endpoints": map[string]string{
-			"deployments": "/deployments",
-			"namespaces":  "/namespaces",
+	response := RootResponse{
+		Message: "Kubernetes Controller API",
+		Version: hm.appVersion,
+		Endpoints: map[string]string{
+			"deployments":          APIPrefix + "/deployments",
+			"deployment_detail":    APIPrefix + "/deployments/{namespace}/{name}",
+			"namespaces":           APIPrefix + "/namespaces",
+			"namespace_status":     APIPrefix + "/namespaces?status=true",
+			"summary":              APIPrefix + "/summary",
+			"namespace_watch":      APIPrefix + "/namespaces/{namespace}/watch",
+			"deployment_events":    APIPrefix + "/events/deployments",
+			"deployment_watch":     APIPrefix + "/watch/deployments",
+			"deployment_action":    APIPrefix + "/deployments/{namespace}/{name}/{scale|restart|pause|resume|image|rollback}",
+			"deployment_revisions": APIPrefix + "/deployments/{namespace}/{name}/revisions",
+			"deployment_pods":      APIPrefix + "/deployments/{namespace}/{name}/pods",
+			"healthz":              "/healthz",
+			"readyz":               "/readyz",
+			"livez":                "/livez",
+			"openapi":              APIPrefix + "/openapi.json",
 		},
 	}
 
@@ -181,18 +511,34 @@ func (hm *HandlerManager) handleRoot(ctx *fasthttp.RequestCtx, logger zerolog.Lo
 func (hm *HandlerManager) handleNotFound(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
 	logger.Warn().Str("path", string(ctx.Path())).Msg("Endpoint not found")
 
-	response := ErrorResponse{
+	hm.writeProblemResponse(ctx, ErrorResponse{
+		Code:    CodeRouteNotFound,
 		Error:   "Not Found",
 		Message: "The requested endpoint does not exist",
-	}
+	}, 404, logger)
+}
 
-	hm.writeJSONResponse(ctx, response, 404, logger)
+// handleMethodNotAllowed handles requests to a known path with a method it does not support
+func (hm *HandlerManager) handleMethodNotAllowed(ctx *fasthttp.RequestCtx, allowed []string, logger zerolog.Logger) {
+	logger.Warn().Str("path", string(ctx.Path())).Strs("allowed", allowed).Msg("Method not allowed")
+
+	ctx.Response.Header.Set("Allow", strings.Join(allowed, ", "))
+	hm.writeProblemResponse(ctx, ErrorResponse{
+		Code:    CodeMethodNotAllowed,
+		Error:   "Method Not Allowed",
+		Message: "Method " + string(ctx.Method()) + " is not allowed, use " + strings.Join(allowed, " or "),
+	}, 405, logger)
 }
 
 // writeJSONResponse writes a JSON response to the HTTP context
 func (hm *HandlerManager) writeJSONResponse(ctx *fasthttp.RequestCtx, data interface{}, statusCode int, logger zerolog.Logger) {
+	hm.writeJSON(ctx, data, statusCode, "application/json", logger)
+}
+
+// writeJSON writes data as JSON with the given content type
+func (hm *HandlerManager) writeJSON(ctx *fasthttp.RequestCtx, data interface{}, statusCode int, contentType string, logger zerolog.Logger) {
 	ctx.SetStatusCode(statusCode)
-	ctx.Response.Header.Set("Content-Type", "application/json")
+	ctx.Response.Header.Set("Content-Type", contentType)
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -211,12 +557,47 @@ func (hm *HandlerManager) writeJSONResponse(ctx *fasthttp.RequestCtx, data inter
 	logger.Info().Int("status_code", statusCode).Msg("Response sent successfully")
 }
 
-// writeErrorResponse writes an error JSON response to the HTTP context
-func (hm *HandlerManager) writeErrorResponse(ctx *fasthttp.RequestCtx, message string, statusCode int, logger zerolog.Logger) {
+// writeErrorResponse writes a problem document with one of the Code constants to the HTTP context
+func (hm *HandlerManager) writeErrorResponse(ctx *fasthttp.RequestCtx, code, message string, statusCode int, logger zerolog.Logger) {
 	response := ErrorResponse{
+		Code:    code,
 		Error:   "Request Error",
 		Message: message,
 	}
 
-	hm.writeJSONResponse(ctx, response, statusCode, logger)
+	hm.writeProblemResponse(ctx, response, statusCode, logger)
+}
+
+// writeProblemResponse fills in the RFC 7807 members of the response and writes it
+func (hm *HandlerManager) writeProblemResponse(ctx *fasthttp.RequestCtx, response ErrorResponse, statusCode int, logger zerolog.Logger) {
+	response.Type = "about:blank"
+	response.Title = fasthttp.StatusMessage(statusCode)
+	response.Status = statusCode
+	response.Detail = response.Message
+	response.Instance = string(ctx.Path())
+
+	hm.writeJSON(ctx, response, statusCode, problemContentType, logger)
+}
+
+// writeListErrorResponse writes the response for a failed pagination request
+func (hm *HandlerManager) writeListErrorResponse(ctx *fasthttp.RequestCtx, err error, logger zerolog.Logger) {
+	if errors.Is(err, errContinueExpired) {
+		hm.writeErrorResponse(ctx, CodeContinueExpired, err.Error(), 410, logger)
+		return
+	}
+	hm.writeParameterErrorResponse(ctx, err, logger)
+}
+
+// writeParameterErrorResponse writes a 400 response describing an invalid query parameter
+func (hm *HandlerManager) writeParameterErrorResponse(ctx *fasthttp.RequestCtx, err error, logger zerolog.Logger) {
+	response := ErrorResponse{
+		Code:    CodeInvalidParameter,
+		Error:   "Invalid Parameter",
+		Message: err.Error(),
+	}
+	if perr, ok := err.(*parameterError); ok {
+		response.Parameter = perr.Parameter
+	}
+
+	hm.writeProblemResponse(ctx, response, 400, logger)
 }
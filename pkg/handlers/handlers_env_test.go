@@ -236,22 +236,41 @@ func TestHandlerManager_Integration_WithRealKubernetes(t *testing.T) {
 		assert.Contains(t, response.Message, "Namespace not being watched")
 	})
 
+	t.Run("DeploymentDetail", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/deployments/test-ns-1/deployment-1")
+		ctx.Request.Header.SetMethod("GET")
+
+		handler := handlerManager.CreateHandler()
+		handler(ctx)
+
+		assert.Equal(t, 200, ctx.Response.StatusCode())
+
+		var response DeploymentDetailResponse
+		err := json.Unmarshal(ctx.Response.Body(), &response)
+		require.NoError(t, err)
+
+		assert.Equal(t, "test-ns-1", response.Namespace)
+		assert.Equal(t, "deployment-1", response.Name)
+		assert.Equal(t, []string{"nginx:1.21"}, response.Images)
+	})
+
 	t.Run("InvalidPath", func(t *testing.T) {
 		ctx := &fasthttp.RequestCtx{}
-		ctx.Request.SetRequestURI("/deployments/invalid/path")
+		ctx.Request.SetRequestURI("/deployments/invalid/path/extra")
 		ctx.Request.Header.SetMethod("GET")
 
 		handler := handlerManager.CreateHandler()
 		handler(ctx)
 
-		assert.Equal(t, 400, ctx.Response.StatusCode())
+		assert.Equal(t, 404, ctx.Response.StatusCode())
 
 		var response ErrorResponse
 		err := json.Unmarshal(ctx.Response.Body(), &response)
 		require.NoError(t, err)
 
-		assert.Equal(t, "Request Error", response.Error)
-		assert.Contains(t, response.Message, "Invalid path format")
+		assert.Equal(t, "Not Found", response.Error)
+		assert.Equal(t, CodeRouteNotFound, response.Code)
 	})
 
 	t.Run("NotFoundEndpoint", func(t *testing.T) {
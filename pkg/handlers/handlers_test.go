@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 	"github.com/vanelin/k8s-controller/pkg/informer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestNewHandlerManager(t *testing.T) {
@@ -122,6 +129,7 @@ func TestHandlerManager_handleGetDeploymentsByNamespace_NamespaceNotWatched(t *t
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.SetRequestURI("/deployments/test-namespace")
 	ctx.Request.Header.SetMethod("GET")
+	ctx.SetUserValue("namespace", "test-namespace")
 
 	logger := zerolog.Nop()
 	handlerManager.handleGetDeploymentsByNamespace(ctx, logger)
@@ -187,9 +195,10 @@ func TestHandlerManager_writeErrorResponse(t *testing.T) {
 	ctx := &fasthttp.RequestCtx{}
 
 	logger := zerolog.Nop()
-	handlerManager.writeErrorResponse(ctx, "Test error message", 400, logger)
+	handlerManager.writeErrorResponse(ctx, CodeInvalidPath, "Test error message", 400, logger)
 
 	assert.Equal(t, 400, ctx.Response.StatusCode())
+	assert.Equal(t, "application/problem+json", string(ctx.Response.Header.ContentType()))
 
 	var response ErrorResponse
 	err := json.Unmarshal(ctx.Response.Body(), &response)
@@ -197,6 +206,11 @@ func TestHandlerManager_writeErrorResponse(t *testing.T) {
 
 	assert.Equal(t, "Request Error", response.Error)
 	assert.Equal(t, "Test error message", response.Message)
+	assert.Equal(t, "about:blank", response.Type)
+	assert.Equal(t, "Bad Request", response.Title)
+	assert.Equal(t, 400, response.Status)
+	assert.Equal(t, "Test error message", response.Detail)
+	assert.Equal(t, CodeInvalidPath, response.Code)
 }
 
 func TestURLDecoding(t *testing.T) {
@@ -225,3 +239,45 @@ func TestURLDecoding(t *testing.T) {
 		})
 	}
 }
+
+// newFakeInformerManager starts informers backed by a fake clientset seeded with the given objects
+func newFakeInformerManager(t *testing.T, namespaces []string, objects ...runtime.Object) *informer.DeploymentInformerManager {
+	t.Helper()
+	return startFakeInformers(t, fake.NewSimpleClientset(objects...), namespaces)
+}
+
+// startFakeInformers starts informers backed by the given fake clientset
+func startFakeInformers(t *testing.T, clientset *fake.Clientset, namespaces []string) *informer.DeploymentInformerManager {
+	t.Helper()
+	informerManager := informer.NewDeploymentInformerManager(clientset)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	for _, ns := range namespaces {
+		informerManager.StartInformer(ctx, ns)
+	}
+	return informerManager
+}
+
+// newTestDeployment builds a minimal deployment for fake clientsets
+func newTestDeployment(namespace, name string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			ResourceVersion:   "1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Labels:            map[string]string{"app": name},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": name},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx:1.21"}}},
+			},
+		},
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/health"
+)
+
+// handleHealthz handles GET /healthz - readiness checks except shutdown
+func (hm *HandlerManager) handleHealthz(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	hm.writeProbeResponse(ctx, "healthz", hm.health.Healthy(), logger)
+}
+
+// handleReadyz handles GET /readyz - fails while shutting down or before the caches have synced
+func (hm *HandlerManager) handleReadyz(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	hm.writeProbeResponse(ctx, "readyz", hm.health.Ready(), logger)
+}
+
+// handleLivez handles GET /livez - fails only if the process can no longer serve requests
+func (hm *HandlerManager) handleLivez(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	hm.writeProbeResponse(ctx, "livez", hm.health.Live(), logger)
+}
+
+// writeProbeResponse writes the result of a probe in the format of the Kubernetes API server:
+// "ok" on success, and one line per check with ?verbose or when a check fails.
+func (hm *HandlerManager) writeProbeResponse(ctx *fasthttp.RequestCtx, probe string, results []health.Result, logger zerolog.Logger) {
+	results = excludeChecks(results, ctx.QueryArgs().PeekMulti("exclude"))
+
+	failed := health.FirstError(results) != nil
+	verbose := ctx.QueryArgs().Has("verbose")
+
+	ctx.Response.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
+
+	if !failed && !verbose {
+		ctx.SetStatusCode(200)
+		ctx.SetBodyString("ok")
+		return
+	}
+
+	var body strings.Builder
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(&body, "[-]%s failed: %v\n", r.Name, r.Err)
+		case r.Info != "":
+			fmt.Fprintf(&body, "[+]%s ok (%s)\n", r.Name, r.Info)
+		default:
+			fmt.Fprintf(&body, "[+]%s ok\n", r.Name)
+		}
+	}
+
+	if failed {
+		fmt.Fprintf(&body, "%s check failed\n", probe)
+		ctx.SetStatusCode(500)
+		logger.Warn().Str("probe", probe).Err(health.FirstError(results)).Msg("Probe check failed")
+	} else {
+		fmt.Fprintf(&body, "%s check passed\n", probe)
+		ctx.SetStatusCode(200)
+	}
+	ctx.SetBodyString(body.String())
+}
+
+// excludeChecks drops the checks named in ?exclude=, which may be repeated
+func excludeChecks(results []health.Result, exclude [][]byte) []health.Result {
+	if len(exclude) == 0 {
+		return results
+	}
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[strings.TrimSpace(string(name))] = true
+	}
+
+	kept := make([]health.Result, 0, len(results))
+	for _, r := range results {
+		if !skip[r.Name] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/health"
+)
+
+func getProbe(handler fasthttp.RequestHandler, uri string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+	return ctx
+}
+
+func TestHandlerManager_Probes(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a"})
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+
+	for _, path := range []string{"/healthz", "/readyz", "/livez"} {
+		ctx := getProbe(handler, path)
+		assert.Equal(t, 200, ctx.Response.StatusCode(), path)
+		assert.Equal(t, "ok", string(ctx.Response.Body()), path)
+		assert.Equal(t, "text/plain; charset=utf-8", string(ctx.Response.Header.ContentType()), path)
+	}
+
+	ctx := getProbe(handler, "/readyz?verbose")
+	assert.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Equal(t, "[+]shutdown ok\n[+]informer-sync:team-a ok\n[+]leader-election ok (disabled)\nreadyz check passed\n", string(ctx.Response.Body()))
+}
+
+func TestHandlerManager_ProbesDuringShutdown(t *testing.T) {
+	state := health.NewState()
+	state.EnableLeaderElection()
+	opts := DefaultOptions()
+	opts.Health = state
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, nil), "test-version", opts).CreateHandler()
+
+	state.SetShuttingDown()
+
+	ctx := getProbe(handler, "/readyz")
+	assert.Equal(t, 500, ctx.Response.StatusCode())
+	assert.Equal(t, "[-]shutdown failed: shutdown in progress\n[+]leader-election ok (follower)\nreadyz check failed\n", string(ctx.Response.Body()))
+
+	// Liveness must not restart a pod that is draining
+	assert.Equal(t, 200, getProbe(handler, "/livez").Response.StatusCode())
+	assert.Equal(t, 200, getProbe(handler, "/healthz").Response.StatusCode())
+
+	ctx = getProbe(handler, "/readyz?exclude=shutdown")
+	assert.Equal(t, 200, ctx.Response.StatusCode())
+}
+
+func TestHandlerManager_ProbesReportUnsyncedCache(t *testing.T) {
+	state := health.NewState()
+	state.ExpectManagerCache()
+	opts := DefaultOptions()
+	opts.Health = state
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, nil), "test-version", opts).CreateHandler()
+
+	ctx := getProbe(handler, "/healthz?verbose")
+	assert.Equal(t, 500, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), "[-]manager-cache failed")
+
+	state.SetManagerCacheSynced()
+	assert.Equal(t, 200, getProbe(handler, "/healthz").Response.StatusCode())
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+)
+
+// maxCachedLists bounds the /deployments responses kept by the list cache, one per set of namespaces
+// visible to callers, e.g. one per team
+const maxCachedLists = 16
+
+// listCache holds the JSON of unfiltered deployment lists, built from the informer snapshot. A
+// namespace is encoded once per snapshot generation, so an informer event only re-encodes its own
+// namespace, and /deployments is assembled from the encoded namespaces without marshaling again.
+type listCache struct {
+	mu         sync.Mutex
+	namespaces map[string]*encodedList
+	// all are the last /deployments responses, most recently used first. Callers seeing the same
+	// namespaces share one.
+	all []*encodedList
+}
+
+// encodedList is a list response encoded for one version of the snapshot
+type encodedList struct {
+	// key identifies the namespaces and generations the response was built from
+	key   string
+	body  []byte
+	etag  string
+	count int
+
+	gzipOnce sync.Once
+	gzipBody []byte
+}
+
+func newListCache() *listCache {
+	return &listCache{namespaces: make(map[string]*encodedList)}
+}
+
+// namespace returns the encoded DeploymentResponse of a namespace snapshot
+func (c *listCache) namespace(ns *informer.NamespaceSnapshot) (*encodedList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.namespaceLocked(ns)
+}
+
+func (c *listCache) namespaceLocked(ns *informer.NamespaceSnapshot) (*encodedList, error) {
+	key := strconv.FormatUint(ns.Generation, 10)
+	if cached, ok := c.namespaces[ns.Namespace]; ok && cached.key == key {
+		return cached, nil
+	}
+
+	response := DeploymentResponse{Namespace: ns.Namespace, Deployments: make([]string, 0, len(ns.Deployments))}
+	etag := newETagHash("json", []string{ns.Namespace})
+	for _, d := range ns.Deployments {
+		response.Deployments = append(response.Deployments, d.Name)
+		etag.add(d.Namespace, d.Name, d.ResourceVersion)
+	}
+	response.Count = len(response.Deployments)
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	encoded := &encodedList{key: key, body: body, etag: etag.etag(), count: response.Count}
+	c.namespaces[ns.Namespace] = encoded
+	return encoded, nil
+}
+
+// allNamespaces returns the encoded DeploymentsAllResponse listing the namespaces, leaving out those not in the snapshot
+func (c *listCache) allNamespaces(snapshot *informer.Snapshot, namespaces []string) (*encodedList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshots := make([]*informer.NamespaceSnapshot, 0, len(namespaces))
+	present := make([]string, 0, len(namespaces))
+	keys := make([]string, 0, len(namespaces))
+	for _, name := range namespaces {
+		ns, ok := snapshot.Namespace(name)
+		if !ok {
+			continue
+		}
+		snapshots = append(snapshots, ns)
+		present = append(present, name)
+		keys = append(keys, name+"="+strconv.FormatUint(ns.Generation, 10))
+	}
+	key := strings.Join(keys, ",")
+	for i, cached := range c.all {
+		if cached.key == key {
+			copy(c.all[1:i+1], c.all[:i])
+			c.all[0] = cached
+			return cached, nil
+		}
+	}
+
+	// The same bytes json.Marshal produces for DeploymentsAllResponse
+	var body bytes.Buffer
+	body.WriteString(`{"namespaces":[`)
+	etag := newETagHash("json", present)
+	total := 0
+	for i, ns := range snapshots {
+		encoded, err := c.namespaceLocked(ns)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(encoded.body)
+		for _, d := range ns.Deployments {
+			etag.add(d.Namespace, d.Name, d.ResourceVersion)
+		}
+		total += encoded.count
+	}
+	body.WriteString(`],"total_count":` + strconv.Itoa(total) + `}`)
+
+	// Namespaces no longer watched are not needed anymore
+	for name := range c.namespaces {
+		if _, ok := snapshot.Namespace(name); !ok {
+			delete(c.namespaces, name)
+		}
+	}
+
+	// Responses of older generations are no longer hit and are the first to go
+	encoded := &encodedList{key: key, body: body.Bytes(), etag: etag.etag(), count: total}
+	if len(c.all) == maxCachedLists {
+		c.all = c.all[:maxCachedLists-1]
+	}
+	c.all = append([]*encodedList{encoded}, c.all...)
+	return encoded, nil
+}
+
+// gzipped returns the body compressed with gzip, compressing it on first use
+func (e *encodedList) gzipped() []byte {
+	e.gzipOnce.Do(func() {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		// Writes to a bytes.Buffer do not fail
+		_, _ = w.Write(e.body)
+		_ = w.Close()
+		e.gzipBody = buf.Bytes()
+	})
+	return e.gzipBody
+}
+
+// listCacheable reports whether a list request can be served from the list cache: JSON without
+// filters, pagination or a custom order
+func (hm *HandlerManager) listCacheable(ctx *fasthttp.RequestCtx, filter *deploymentFilter, opts listOptions) bool {
+	return hm.listCache != nil && filter.isEmpty() && opts == listOptions{sortBy: sortByName} &&
+		requestFormat(ctx).name == "json"
+}
+
+// writeEncodedList writes a cached list response, compressed when the client accepts gzip
+func (hm *HandlerManager) writeEncodedList(ctx *fasthttp.RequestCtx, encoded *encodedList, logger zerolog.Logger) {
+	compress := ctx.Request.Header.HasAcceptEncoding("gzip")
+	ctx.Response.Header.Add("Vary", "Accept-Encoding")
+	etag := encoded.etag
+	if compress {
+		// A strong ETag names one exact body, the compressed one is another
+		etag = strings.TrimSuffix(etag, `"`) + `-gzip"`
+	}
+	if hm.notModified(ctx, etag, logger) {
+		return
+	}
+
+	ctx.SetStatusCode(200)
+	ctx.Response.Header.Set("Content-Type", "application/json")
+	if compress {
+		ctx.Response.Header.Set("Content-Encoding", "gzip")
+		ctx.Response.SetBodyRaw(encoded.gzipped())
+	} else {
+		ctx.Response.SetBodyRaw(encoded.body)
+	}
+	logger.Info().Int("status_code", 200).Int("count", encoded.count).Bool("gzip", compress).Msg("Response sent from list cache")
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vanelin/k8s-controller/pkg/informer"
+)
+
+// listRequest sends a GET request with optional request headers given as name, value pairs
+func listRequest(handler fasthttp.RequestHandler, uri string, headers ...string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.SetMethod("GET")
+	for i := 0; i+1 < len(headers); i += 2 {
+		ctx.Request.Header.Set(headers[i], headers[i+1])
+	}
+	handler(ctx)
+	return ctx
+}
+
+// waitForSnapshot waits until the snapshot holds the given number of deployments
+func waitForSnapshot(t testing.TB, informerManager *informer.DeploymentInformerManager, count int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		total := 0
+		snapshot := informerManager.Snapshot()
+		for _, name := range snapshot.Namespaces() {
+			ns, _ := snapshot.Namespace(name)
+			total += len(ns.Deployments)
+		}
+		return total == count
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestHandlerManager_ListCache(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		newTestDeployment("team-a", "web", 1),
+		newTestDeployment("team-a", "api", 1),
+		newTestDeployment("team-b", "worker", 1),
+	)
+	informerManager := startFakeInformers(t, clientset, []string{"team-a", "team-b", "team-c"})
+	waitForSnapshot(t, informerManager, 3)
+
+	cached := NewHandlerManager(informerManager, "test-version")
+	handler := cached.CreateHandler()
+	uncached := NewHandlerManager(informerManager, "test-version")
+	uncached.listCache = nil
+	uncachedHandler := uncached.CreateHandler()
+
+	// The cache serves the same bytes and ETags as the informer stores
+	for _, uri := range []string{"/deployments", "/deployments/team-a", "/deployments/team-c"} {
+		ctx := listRequest(handler, uri)
+		want := listRequest(uncachedHandler, uri)
+		require.Equal(t, 200, ctx.Response.StatusCode(), uri)
+		assert.Equal(t, string(want.Response.Body()), string(ctx.Response.Body()), uri)
+		assert.Equal(t, string(want.Response.Header.Peek("ETag")), string(ctx.Response.Header.Peek("ETag")), uri)
+		assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()), uri)
+	}
+
+	// Compressed responses have an ETag of their own
+	ctx := listRequest(handler, "/deployments", "Accept-Encoding", "gzip, br")
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Equal(t, "gzip", string(ctx.Response.Header.Peek("Content-Encoding")))
+	reader, err := gzip.NewReader(bytes.NewReader(ctx.Response.Body()))
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, string(listRequest(handler, "/deployments").Response.Body()), string(body))
+	etag := string(ctx.Response.Header.Peek("ETag"))
+	assert.Regexp(t, `-gzip"$`, etag)
+	assert.Equal(t, 304, listRequest(handler, "/deployments", "Accept-Encoding", "gzip", "If-None-Match", etag).Response.StatusCode())
+
+	// Filtered, paginated and non-JSON requests go to the informer stores
+	assert.Contains(t, string(listRequest(handler, "/deployments/team-a?namePrefix=w").Response.Body()), `"deployments":["web"]`)
+	assert.Contains(t, string(listRequest(handler, "/deployments?limit=1").Response.Body()), `"continue"`)
+	assert.Equal(t, "NAMESPACE,NAME\nteam-b,worker\n", string(listRequest(handler, "/deployments/team-b?output=csv").Response.Body()))
+
+	// An informer event only re-encodes its namespace
+	teamB := cached.listCache.namespaces["team-b"]
+	_, err = clientset.AppsV1().Deployments("team-a").Create(context.Background(), newTestDeployment("team-a", "cache", 1), metav1.CreateOptions{})
+	require.NoError(t, err)
+	waitForSnapshot(t, informerManager, 4)
+
+	ctx = listRequest(handler, "/deployments")
+	assert.Equal(t, string(listRequest(uncachedHandler, "/deployments").Response.Body()), string(ctx.Response.Body()))
+	assert.Contains(t, string(ctx.Response.Body()), `"deployments":["api","cache","web"]`)
+	assert.Same(t, teamB, cached.listCache.namespaces["team-b"])
+}
+
+func TestListCache_NamespaceSets(t *testing.T) {
+	namespaces := []string{"team-a", "team-b"}
+	for i := 0; i < maxCachedLists-1; i++ {
+		namespaces = append(namespaces, fmt.Sprintf("team-%02d", i))
+	}
+	clientset := fake.NewSimpleClientset(newTestDeployment("team-a", "web", 1), newTestDeployment("team-b", "worker", 1))
+	informerManager := startFakeInformers(t, clientset, namespaces)
+	require.Eventually(t, func() bool {
+		return len(informerManager.Snapshot().Namespaces()) == len(namespaces)
+	}, 10*time.Second, 10*time.Millisecond)
+	waitForSnapshot(t, informerManager, 2)
+	snapshot := informerManager.Snapshot()
+	cache := newListCache()
+
+	// Callers seeing different namespaces take turns without evicting each other
+	teamA, err := cache.allNamespaces(snapshot, []string{"team-a"})
+	require.NoError(t, err)
+	both, err := cache.allNamespaces(snapshot, []string{"team-a", "team-b"})
+	require.NoError(t, err)
+	again, err := cache.allNamespaces(snapshot, []string{"team-a"})
+	require.NoError(t, err)
+	assert.Same(t, teamA, again)
+	again, err = cache.allNamespaces(snapshot, []string{"team-a", "team-b"})
+	require.NoError(t, err)
+	assert.Same(t, both, again)
+
+	// The least recently used response goes first
+	for i := 0; i < maxCachedLists-1; i++ {
+		_, err := cache.allNamespaces(snapshot, []string{"team-b", fmt.Sprintf("team-%02d", i)})
+		require.NoError(t, err)
+	}
+	require.Len(t, cache.all, maxCachedLists)
+	again, err = cache.allNamespaces(snapshot, []string{"team-a", "team-b"})
+	require.NoError(t, err)
+	assert.Same(t, both, again)
+	again, err = cache.allNamespaces(snapshot, []string{"team-a"})
+	require.NoError(t, err)
+	assert.NotSame(t, teamA, again)
+	assert.Len(t, cache.all, maxCachedLists)
+}
+
+// newBenchmarkHandler returns handler managers over namespaces x perNamespace deployments, with and
+// without the list cache
+func newBenchmarkHandler(b *testing.B, namespaces, perNamespace int) (cached, uncached *HandlerManager) {
+	var objects []runtime.Object
+	var names []string
+	for i := 0; i < namespaces; i++ {
+		namespace := fmt.Sprintf("team-%02d", i)
+		names = append(names, namespace)
+		for j := 0; j < perNamespace; j++ {
+			objects = append(objects, newTestDeployment(namespace, fmt.Sprintf("deployment-%04d", j), 1))
+		}
+	}
+
+	informerManager := informer.NewDeploymentInformerManager(fake.NewSimpleClientset(objects...))
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Cleanup(cancel)
+	for _, namespace := range names {
+		informerManager.StartInformer(ctx, namespace)
+	}
+	waitForSnapshot(b, informerManager, len(objects))
+
+	cached = NewHandlerManager(informerManager, "bench")
+	uncached = NewHandlerManager(informerManager, "bench")
+	uncached.listCache = nil
+	return cached, uncached
+}
+
+// BenchmarkListDeployments compares lists served from the informer stores with the list cache,
+// for 5000 deployments in 10 namespaces
+func BenchmarkListDeployments(b *testing.B) {
+	cached, uncached := newBenchmarkHandler(b, 10, 500)
+	// Keep the request log out of the measurement
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	b.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	benchmarks := []struct {
+		name    string
+		hm      *HandlerManager
+		uri     string
+		headers []string
+	}{
+		{name: "all/store", hm: uncached, uri: "/deployments"},
+		{name: "all/cache", hm: cached, uri: "/deployments"},
+		{name: "all/cache-gzip", hm: cached, uri: "/deployments", headers: []string{"Accept-Encoding", "gzip"}},
+		{name: "namespace/store", hm: uncached, uri: "/deployments/team-03"},
+		{name: "namespace/cache", hm: cached, uri: "/deployments/team-03"},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			handler := bm.hm.CreateHandler()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if ctx := listRequest(handler, bm.uri, bm.headers...); ctx.Response.StatusCode() != 200 {
+						b.Errorf("status %d", ctx.Response.StatusCode())
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/valyala/fasthttp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// unmatchedRoute is the route label of requests that matched no route, so unknown paths cannot
+// create new label values
+const unmatchedRoute = "unmatched"
+
+// HTTP API metrics, served with the controller-runtime metrics on METRIC_PORT. Routes are labelled
+// by their pattern, e.g. /deployments/{namespace}, never by the raw path.
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8s_controller_http_requests_total",
+		Help: "Number of HTTP API requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8s_controller_http_request_duration_seconds",
+		Help:    "Latency of HTTP API requests by route, method and status code. Streams are measured until they are set up.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	httpResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8s_controller_http_response_size_bytes",
+		Help:    "Size of HTTP API response bodies by route, method and status code. Streams are not included.",
+		Buckets: prometheus.ExponentialBuckets(100, 4, 8),
+	}, []string{"route", "method", "code"})
+
+	httpRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_controller_http_requests_in_flight",
+		Help: "Number of HTTP API requests being handled by route.",
+	}, []string{"route"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(httpRequestsTotal, httpRequestDuration, httpResponseSize, httpRequestsInFlight)
+}
+
+// routeLabel returns the route label of a request
+func routeLabel(r *route) string {
+	if r == nil {
+		return unmatchedRoute
+	}
+	return r.pattern
+}
+
+// methodLabel limits the method label to the methods of the API, so arbitrary methods cannot create label values
+func methodLabel(method string) string {
+	switch method {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodPost, fasthttp.MethodPut,
+		fasthttp.MethodPatch, fasthttp.MethodDelete, fasthttp.MethodOptions:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// instrumentRequest counts the request as in flight and returns a function that records it once handled
+func instrumentRequest(ctx *fasthttp.RequestCtx, route, method string) func() {
+	start := time.Now()
+	inFlight := httpRequestsInFlight.WithLabelValues(route)
+	inFlight.Inc()
+
+	return func() {
+		inFlight.Dec()
+		code := strconv.Itoa(ctx.Response.StatusCode())
+		httpRequestsTotal.WithLabelValues(route, method, code).Inc()
+		httpRequestDuration.WithLabelValues(route, method, code).Observe(time.Since(start).Seconds())
+		// Reading the body of a stream would consume it
+		if !ctx.Response.IsBodyStream() {
+			httpResponseSize.WithLabelValues(route, method, code).Observe(float64(len(ctx.Response.Body())))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestHandlerManager_Metrics(t *testing.T) {
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, []string{"default"}), "test-version", DefaultOptions()).CreateHandler()
+	request := func(method, path string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.SetMethod(method)
+		handler(ctx)
+	}
+
+	requests := func(route, method, code string) float64 {
+		return testutil.ToFloat64(httpRequestsTotal.WithLabelValues(route, method, code))
+	}
+	before := map[string]float64{
+		"detail":  requests("/deployments/{namespace}/{name}", "GET", "404"),
+		"list":    requests("/deployments/{namespace}", "GET", "200"),
+		"unknown": requests(unmatchedRoute, "GET", "404"),
+		"method":  requests(unmatchedRoute, "other", "405"),
+	}
+
+	request("GET", "/api/v1/deployments/default/missing")
+	request("GET", "/deployments/default/other")
+	request("GET", "/api/v1/deployments/default")
+	request("GET", "/no/such/path")
+	request("BREW", "/deployments")
+
+	// Routes are labelled by pattern, with and without the API prefix
+	assert.Equal(t, 2.0, requests("/deployments/{namespace}/{name}", "GET", "404")-before["detail"])
+	assert.Equal(t, 1.0, requests("/deployments/{namespace}", "GET", "200")-before["list"])
+	assert.Equal(t, 1.0, requests(unmatchedRoute, "GET", "404")-before["unknown"])
+	assert.Equal(t, 1.0, requests(unmatchedRoute, "other", "405")-before["method"])
+	assert.Zero(t, testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/deployments/{namespace}")))
+
+	// The metrics are served from the controller-runtime registry
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{
+		"k8s_controller_http_requests_total",
+		"k8s_controller_http_request_duration_seconds",
+		"k8s_controller_http_response_size_bytes",
+		"k8s_controller_http_requests_in_flight",
+	} {
+		assert.True(t, names[name], name)
+	}
+}
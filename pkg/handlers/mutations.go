@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Actions supported by POST /deployments/{namespace}/{name}/{action}
+const (
+	actionScale    = "scale"
+	actionRestart  = "restart"
+	actionPause    = "pause"
+	actionResume   = "resume"
+	actionImage    = "image"
+	actionRollback = "rollback"
+)
+
+const (
+	// restartedAtAnnotation is the pod template annotation kubectl rollout restart sets
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+	// mutationTimeout bounds the patch request sent to the API server
+	mutationTimeout = 10 * time.Second
+	// maxMutationBodySize limits the request body of mutating endpoints
+	maxMutationBodySize = 64 * 1024
+)
+
+// ScaleRequest is the body of POST /deployments/{namespace}/{name}/scale
+type ScaleRequest struct {
+	Replicas *int32 `json:"replicas"`
+}
+
+// SetImageRequest is the body of POST /deployments/{namespace}/{name}/image, mapping container names to images
+type SetImageRequest struct {
+	Containers map[string]string `json:"containers"`
+}
+
+// DeploymentActionResponse is returned by the mutating endpoints
+type DeploymentActionResponse struct {
+	Action     string            `json:"action"`
+	DryRun     bool              `json:"dry_run"`
+	Deployment DeploymentSummary `json:"deployment"`
+}
+
+// handleDeploymentAction handles POST /deployments/{namespace}/{name}/{scale|restart|pause|resume|image|rollback}
+func (hm *HandlerManager) handleDeploymentAction(ctx *fasthttp.RequestCtx, action string, logger zerolog.Logger) {
+	namespace, name := pathParam(ctx, "namespace"), pathParam(ctx, "name")
+	logger = logger.With().Str("namespace", namespace).Str("name", name).Str("action", action).Logger()
+
+	current, dryRun, ok := hm.prepareMutation(ctx, action, namespace, name, logger)
+	if !ok {
+		return
+	}
+
+	if action == actionRollback {
+		hm.rollbackDeployment(ctx, current, dryRun, logger)
+		return
+	}
+
+	patch, err := buildDeploymentPatch(action, ctx.PostBody(), current, time.Now())
+	if err != nil {
+		hm.writeErrorResponse(ctx, CodeInvalidBody, err.Error(), 400, logger)
+		return
+	}
+
+	updated, ok := hm.patchDeployment(ctx, current, types.StrategicMergePatchType, patch, dryRun, logger)
+	if !ok {
+		return
+	}
+
+	hm.writeJSONResponse(ctx, DeploymentActionResponse{
+		Action:     action,
+		DryRun:     dryRun,
+		Deployment: summarizeDeployment(updated),
+	}, 200, logger)
+}
+
+// prepareMutation runs the checks shared by all mutating endpoints and returns the cached deployment.
+// When it returns false the error response has already been written.
+func (hm *HandlerManager) prepareMutation(ctx *fasthttp.RequestCtx, action, namespace, name string, logger zerolog.Logger) (*appsv1.Deployment, bool, bool) {
+	if !hm.options.EnableMutations {
+		hm.writeErrorResponse(ctx, CodeMutationsDisabled, "Mutating endpoints are disabled, set ENABLE_MUTATIONS=true to enable them", 403, logger)
+		return nil, false, false
+	}
+	if hm.options.Clientset == nil {
+		hm.writeErrorResponse(ctx, CodeClientUnavailable, "Kubernetes client is not configured", 503, logger)
+		return nil, false, false
+	}
+
+	dryRun, err := parseOptionalBool(ctx.QueryArgs(), "dryRun")
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return nil, false, false
+	}
+
+	if !hm.checkAccess(ctx, action, namespace, name, logger) {
+		return nil, false, false
+	}
+	if !hm.informerManager.HasInformer(namespace) {
+		hm.writeErrorResponse(ctx, CodeNamespaceNotWatched, "Namespace not being watched: "+namespace, 404, logger)
+		return nil, false, false
+	}
+	current, found := hm.informerManager.GetDeployment(namespace, name)
+	if !found {
+		hm.writeErrorResponse(ctx, CodeDeploymentNotFound, fmt.Sprintf("Deployment not found: %s/%s", namespace, name), 404, logger)
+		return nil, false, false
+	}
+
+	if len(ctx.PostBody()) > maxMutationBodySize {
+		hm.writeErrorResponse(ctx, CodeBodyTooLarge, "Request body too large", 413, logger)
+		return nil, false, false
+	}
+
+	return current, dryRun != nil && *dryRun, true
+}
+
+// patchDeployment sends a patch to the API server. When it returns false the error response has already been written.
+func (hm *HandlerManager) patchDeployment(ctx *fasthttp.RequestCtx, current *appsv1.Deployment, patchType types.PatchType, patch []byte, dryRun bool, logger zerolog.Logger) (*appsv1.Deployment, bool) {
+	opts := metav1.PatchOptions{FieldManager: "k8s-controller"}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	reqCtx, cancel := context.WithTimeout(context.Background(), mutationTimeout)
+	defer cancel()
+
+	updated, err := hm.options.Clientset.AppsV1().Deployments(current.Namespace).Patch(reqCtx, current.Name, patchType, patch, opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to patch deployment")
+		hm.writeErrorResponse(ctx, CodeKubernetesAPI, err.Error(), apiErrorStatusCode(err), logger)
+		return nil, false
+	}
+
+	logger.Info().Bool("dry_run", dryRun).Msg("Deployment patched")
+	return updated, true
+}
+
+// buildDeploymentPatch returns the strategic merge patch that performs the action
+func buildDeploymentPatch(action string, body []byte, current *appsv1.Deployment, now time.Time) ([]byte, error) {
+	var spec map[string]interface{}
+
+	switch action {
+	case actionScale:
+		var req ScaleRequest
+		if err := decodeRequestBody(body, &req); err != nil {
+			return nil, err
+		}
+		if req.Replicas == nil || *req.Replicas < 0 {
+			return nil, fmt.Errorf("replicas must be a non-negative integer")
+		}
+		spec = map[string]interface{}{"replicas": *req.Replicas}
+	case actionRestart:
+		spec = map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{restartedAtAnnotation: now.Format(time.RFC3339)},
+				},
+			},
+		}
+	case actionPause:
+		spec = map[string]interface{}{"paused": true}
+	case actionResume:
+		spec = map[string]interface{}{"paused": false}
+	case actionImage:
+		var req SetImageRequest
+		if err := decodeRequestBody(body, &req); err != nil {
+			return nil, err
+		}
+		podSpec, err := setImagePodSpecPatch(current, req.Containers)
+		if err != nil {
+			return nil, err
+		}
+		spec = map[string]interface{}{"template": map[string]interface{}{"spec": podSpec}}
+	default:
+		return nil, fmt.Errorf("unsupported action %q", action)
+	}
+
+	return json.Marshal(map[string]interface{}{"spec": spec})
+}
+
+// setImagePodSpecPatch builds the pod spec part of a set-image patch. Containers are merged by name,
+// so only the listed containers change; unknown container names are rejected.
+func setImagePodSpecPatch(current *appsv1.Deployment, images map[string]string) (map[string]interface{}, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("containers must map at least one container name to an image")
+	}
+
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var containers, initContainers []map[string]string
+	for _, name := range names {
+		image := strings.TrimSpace(images[name])
+		if image == "" {
+			return nil, fmt.Errorf("image for container %q must not be empty", name)
+		}
+		entry := map[string]string{"name": name, "image": image}
+		switch {
+		case hasContainer(current.Spec.Template.Spec.Containers, name):
+			containers = append(containers, entry)
+		case hasContainer(current.Spec.Template.Spec.InitContainers, name):
+			initContainers = append(initContainers, entry)
+		default:
+			return nil, fmt.Errorf("container %q not found in deployment %s/%s", name, current.Namespace, current.Name)
+		}
+	}
+
+	podSpec := map[string]interface{}{}
+	if len(containers) > 0 {
+		podSpec["containers"] = containers
+	}
+	if len(initContainers) > 0 {
+		podSpec["initContainers"] = initContainers
+	}
+	return podSpec, nil
+}
+
+// decodeRequestBody strictly decodes a JSON request body, rejecting unknown fields
+func decodeRequestBody(body []byte, v interface{}) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return fmt.Errorf("request body is required")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// apiErrorStatusCode maps a Kubernetes API error to the HTTP status returned to the client
+func apiErrorStatusCode(err error) int {
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Code != 0 {
+		return int(status.Status().Code)
+	}
+	return 500
+}
+
+func hasContainer(containers []corev1.Container, name string) bool {
+	for _, c := range containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
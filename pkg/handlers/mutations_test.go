@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newMutationTestHandler returns a handler with mutations enabled and the fake clientset behind it
+func newMutationTestHandler(t *testing.T, enabled bool) (fasthttp.RequestHandler, *fake.Clientset) {
+	t.Helper()
+	web := newTestDeployment("team-a", "web", 2)
+	web.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "migrate:1"}}
+	clientset := fake.NewSimpleClientset(web)
+	informerManager := startFakeInformers(t, clientset, []string{"team-a"})
+
+	opts := DefaultOptions()
+	opts.EnableMutations = enabled
+	opts.Clientset = clientset
+	return NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler(), clientset
+}
+
+func postAction(handler fasthttp.RequestHandler, uri, body string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(body)
+	handler(ctx)
+	return ctx
+}
+
+func TestDeploymentActions_Disabled(t *testing.T) {
+	handler, clientset := newMutationTestHandler(t, false)
+	clientset.ClearActions()
+
+	ctx := postAction(handler, "/deployments/team-a/web/scale", `{"replicas":3}`)
+	assert.Equal(t, 403, ctx.Response.StatusCode())
+	assert.Empty(t, clientset.Actions())
+}
+
+func TestDeploymentActions(t *testing.T) {
+	handler, clientset := newMutationTestHandler(t, true)
+
+	t.Run("Scale", func(t *testing.T) {
+		ctx := postAction(handler, "/deployments/team-a/web/scale", `{"replicas":5}`)
+		require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+
+		var response DeploymentActionResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, "scale", response.Action)
+		assert.False(t, response.DryRun)
+		assert.Equal(t, int32(5), response.Deployment.Replicas)
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		ctx := postAction(handler, "/deployments/team-a/web/restart", "")
+		require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+
+		d, err := clientset.AppsV1().Deployments("team-a").Get(t.Context(), "web", metav1.GetOptions{})
+		require.NoError(t, err)
+		restartedAt, err := time.Parse(time.RFC3339, d.Spec.Template.Annotations[restartedAtAnnotation])
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), restartedAt, time.Minute)
+	})
+
+	t.Run("PauseAndResume", func(t *testing.T) {
+		ctx := postAction(handler, "/deployments/team-a/web/pause", "")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		var response DeploymentActionResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.True(t, response.Deployment.Paused)
+
+		ctx = postAction(handler, "/deployments/team-a/web/resume", "")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.False(t, response.Deployment.Paused)
+	})
+
+	t.Run("SetImage", func(t *testing.T) {
+		ctx := postAction(handler, "/deployments/team-a/web/image", `{"containers":{"nginx":"nginx:1.27","migrate":"migrate:2"}}`)
+		require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+
+		var response DeploymentActionResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, []string{"nginx:1.27"}, response.Deployment.Images)
+
+		d, err := clientset.AppsV1().Deployments("team-a").Get(t.Context(), "web", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "migrate:2", d.Spec.Template.Spec.InitContainers[0].Image)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		clientset.ClearActions()
+		ctx := postAction(handler, "/deployments/team-a/web/scale?dryRun=true", `{"replicas":1}`)
+		require.Equal(t, 200, ctx.Response.StatusCode())
+
+		var response DeploymentActionResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.True(t, response.DryRun)
+
+		require.Len(t, clientset.Actions(), 1)
+		patch, ok := clientset.Actions()[0].(k8stesting.PatchActionImpl)
+		require.True(t, ok)
+		assert.Equal(t, []string{metav1.DryRunAll}, patch.PatchOptions.DryRun)
+	})
+}
+
+func TestDeploymentActions_Errors(t *testing.T) {
+	handler, _ := newMutationTestHandler(t, true)
+
+	tests := []struct {
+		name   string
+		uri    string
+		body   string
+		status int
+	}{
+		{"unwatched namespace", "/deployments/team-b/web/pause", "", 404},
+		{"unknown deployment", "/deployments/team-a/api/pause", "", 404},
+		{"missing replicas", "/deployments/team-a/web/scale", `{}`, 400},
+		{"negative replicas", "/deployments/team-a/web/scale", `{"replicas":-1}`, 400},
+		{"unknown field", "/deployments/team-a/web/scale", `{"replica":1}`, 400},
+		{"empty body", "/deployments/team-a/web/image", "", 400},
+		{"unknown container", "/deployments/team-a/web/image", `{"containers":{"sidecar":"envoy:1"}}`, 400},
+		{"empty image", "/deployments/team-a/web/image", `{"containers":{"nginx":" "}}`, 400},
+		{"invalid dryRun", "/deployments/team-a/web/pause?dryRun=maybe", "", 400},
+		{"unknown action", "/deployments/team-a/web/rollout", "", 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := postAction(handler, tt.uri, tt.body)
+			assert.Equal(t, tt.status, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/vanelin/k8s-controller/pkg/informer"
+)
+
+// NamespaceStatus tells whether a watched namespace is served from a synced cache, and why not
+type NamespaceStatus struct {
+	Namespace string `json:"namespace"`
+	Synced    bool   `json:"synced"`
+	// LastSync is when the deployments were last listed from the API server
+	LastSync    *time.Time `json:"last_sync,omitempty"`
+	Deployments int        `json:"deployments"`
+	// UnavailableDeployments have fewer available replicas than desired
+	UnavailableDeployments int        `json:"unavailable_deployments"`
+	LastEvent              *time.Time `json:"last_event,omitempty"`
+	// LastError is the last list or watch error, e.g. an RBAC denial; it stays after the informers recover
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// namespaceStatuses returns the informer state of the given watched namespaces, in the same order
+func (hm *HandlerManager) namespaceStatuses(namespaces []string) []NamespaceStatus {
+	byName := make(map[string]informer.NamespaceStatus)
+	for _, status := range hm.informerManager.NamespaceStatuses() {
+		byName[status.Namespace] = status
+	}
+
+	snapshot := hm.informerManager.Snapshot()
+	statuses := make([]NamespaceStatus, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		status := byName[namespace]
+		entry := NamespaceStatus{
+			Namespace:     namespace,
+			Synced:        status.Synced,
+			LastSync:      optionalTime(status.LastSync),
+			LastEvent:     optionalTime(status.LastEvent),
+			LastError:     status.LastError,
+			LastErrorTime: optionalTime(status.LastErrorTime),
+		}
+		if ns, ok := snapshot.Namespace(namespace); ok {
+			entry.Deployments = len(ns.Deployments)
+			for _, d := range ns.Deployments {
+				if !isFullyAvailable(d) {
+					entry.UnavailableDeployments++
+				}
+			}
+		}
+		statuses = append(statuses, entry)
+	}
+	return statuses
+}
+
+// optionalTime returns nil for the zero time, so it is left out of responses
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// namespaceStatusHeader and namespaceStatusRows are the table of GET /namespaces?status=true
+func namespaceStatusHeader() []string {
+	return []string{"NAMESPACE", "SYNCED", "DEPLOYMENTS", "UNAVAILABLE", "LAST-SYNC", "LAST-EVENT", "LAST-ERROR"}
+}
+
+func namespaceStatusRows(statuses []NamespaceStatus) [][]string {
+	rows := make([][]string, 0, len(statuses))
+	for _, ns := range statuses {
+		rows = append(rows, []string{
+			ns.Namespace,
+			strconv.FormatBool(ns.Synced),
+			strconv.Itoa(ns.Deployments),
+			strconv.Itoa(ns.UnavailableDeployments),
+			formatOptionalTime(ns.LastSync),
+			formatOptionalTime(ns.LastEvent),
+			ns.LastError,
+		})
+	}
+	return rows
+}
+
+// formatOptionalTime formats a time for tables and CSV, empty when it is not set
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestHandlerManager_NamespaceStatus(t *testing.T) {
+	available := newTestDeployment("team-a", "api", 2)
+	available.Status.AvailableReplicas = 2
+	clientset := fake.NewSimpleClientset(available, newTestDeployment("team-a", "web", 1))
+	clientset.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "locked" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", nil)
+	})
+	informerManager := startFakeInformers(t, clientset, []string{"team-a"})
+	informerManager.StartInformerAsync(t.Context(), "locked")
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+
+	var response NamespaceResponse
+	require.Eventually(t, func() bool {
+		ctx := listRequest(handler, "/api/v1/namespaces?status=true")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Empty(t, ctx.Response.Header.Peek("ETag"))
+		response = NamespaceResponse{}
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		return response.Count == 2 && response.Statuses[0].LastError != ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []string{"locked", "team-a"}, response.Namespaces)
+	require.Len(t, response.Statuses, 2)
+	locked, teamA := response.Statuses[0], response.Statuses[1]
+	assert.Equal(t, "locked", locked.Namespace)
+	assert.False(t, locked.Synced)
+	assert.Nil(t, locked.LastSync)
+	assert.Zero(t, locked.Deployments)
+	assert.Contains(t, locked.LastError, "forbidden")
+	assert.NotNil(t, locked.LastErrorTime)
+
+	assert.Equal(t, "team-a", teamA.Namespace)
+	assert.True(t, teamA.Synced)
+	assert.NotNil(t, teamA.LastSync)
+	assert.NotNil(t, teamA.LastEvent)
+	assert.Equal(t, 2, teamA.Deployments)
+	assert.Equal(t, 1, teamA.UnavailableDeployments)
+	assert.Empty(t, teamA.LastError)
+	assert.Nil(t, teamA.LastErrorTime)
+
+	csv := string(listRequest(handler, "/api/v1/namespaces?status=true&output=csv").Response.Body())
+	assert.Contains(t, csv, "NAMESPACE,SYNCED,DEPLOYMENTS,UNAVAILABLE,LAST-SYNC,LAST-EVENT,LAST-ERROR\n")
+	assert.Regexp(t, `\nteam-a,true,2,1,\S+Z,\S+Z,\n`, csv)
+
+	// Without status the response is the plain list
+	ctx := listRequest(handler, "/api/v1/namespaces")
+	assert.NotEmpty(t, ctx.Response.Header.Peek("ETag"))
+	assert.NotContains(t, string(ctx.Response.Body()), "statuses")
+	assert.Equal(t, 400, listRequest(handler, "/api/v1/namespaces?status=maybe").Response.StatusCode())
+}
+
+func TestHandlerManager_NamespaceStatusAuthorization(t *testing.T) {
+	handler := newAuthzTestHandler(t, &fakeAuthorizer{allowed: map[string][]string{"team-a": {"list"}}})
+
+	ctx := authRequest(handler, "/api/v1/namespaces?status=true", "Bearer good")
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	var response NamespaceResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	require.Len(t, response.Statuses, 1)
+	assert.Equal(t, "team-a", response.Statuses[0].Namespace)
+}
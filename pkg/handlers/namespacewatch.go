@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultWatchSyncTimeout bounds how long POST /namespaces/{namespace}/watch checks the namespace and
+// waits for its informers to sync, unless Options.WatchSyncTimeout is set
+const DefaultWatchSyncTimeout = 10 * time.Second
+
+// NamespaceWatchResponse is returned by POST and DELETE /namespaces/{namespace}/watch
+type NamespaceWatchResponse struct {
+	Namespace string `json:"namespace"`
+	Watched   bool   `json:"watched"`
+	// Changed is false when the namespace already was in the requested state
+	Changed bool `json:"changed"`
+	// Synced is true once the informers of a watched namespace have synced. A namespace started by the
+	// request that does not sync within the timeout is not watched, the request fails instead.
+	Synced bool `json:"synced"`
+	// Namespaces are all watched namespaces after the change
+	Namespaces []string `json:"namespaces"`
+}
+
+// handleWatchNamespace handles POST /namespaces/{namespace}/watch - starts the informers of a namespace
+func (hm *HandlerManager) handleWatchNamespace(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	namespace := pathParam(ctx, "namespace")
+	logger = logger.With().Str("namespace", namespace).Logger()
+
+	if !hm.checkAdmin(ctx, namespace, logger) {
+		return
+	}
+	if hm.options.Clientset == nil {
+		hm.writeErrorResponse(ctx, CodeClientUnavailable, "Kubernetes client is not configured", 503, logger)
+		return
+	}
+
+	timeout := hm.options.WatchSyncTimeout
+	if timeout <= 0 {
+		timeout = DefaultWatchSyncTimeout
+	}
+	reqCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Concurrent requests for the namespace must not see an informer that the first one is about to
+	// stop because it did not sync, they wait for it to finish instead
+	unlock, locked := hm.watchLocks.lock(reqCtx, namespace)
+	if !locked {
+		hm.writeErrorResponse(ctx, CodeNamespaceSyncTimeout, "Namespace did not sync within "+timeout.String()+": another request is still starting its watch", 504, logger)
+		return
+	}
+	defer unlock()
+
+	// An informer on a missing namespace would sync to an empty list, check the namespace exists first
+	if !hm.informerManager.HasInformer(namespace) {
+		if _, err := hm.options.Clientset.CoreV1().Namespaces().Get(reqCtx, namespace, metav1.GetOptions{}); err != nil {
+			logger.Warn().Err(err).Msg("Failed to get namespace to watch")
+			hm.writeErrorResponse(ctx, CodeKubernetesAPI, err.Error(), apiErrorStatusCode(err), logger)
+			return
+		}
+	}
+
+	informerCtx := hm.options.InformerContext
+	if informerCtx == nil {
+		informerCtx = context.Background()
+	}
+	changed := hm.informerManager.StartInformerAsync(informerCtx, namespace)
+	synced := hm.informerManager.WaitForSync(reqCtx, namespace)
+	if changed && !synced {
+		// An unsynced namespace fails /readyz, do not keep one that may never sync, e.g. without RBAC
+		message := "Namespace did not sync within " + timeout.String()
+		if lastError := hm.namespaceLastError(namespace); lastError != "" {
+			message += ": " + lastError
+		}
+		hm.informerManager.StopInformer(namespace)
+		logger.Warn().Str("error", message).Msg("Namespace watch not started")
+		hm.writeErrorResponse(ctx, CodeNamespaceSyncTimeout, message, 504, logger)
+		return
+	}
+	if changed {
+		logger.Info().Bool("synced", synced).Msg("Namespace watch started")
+	}
+
+	hm.writeJSONResponse(ctx, NamespaceWatchResponse{
+		Namespace:  namespace,
+		Watched:    true,
+		Changed:    changed,
+		Synced:     synced,
+		Namespaces: hm.informerManager.GetAvailableNamespaces(),
+	}, 200, logger)
+}
+
+// handleUnwatchNamespace handles DELETE /namespaces/{namespace}/watch - stops the informers of a namespace
+func (hm *HandlerManager) handleUnwatchNamespace(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	namespace := pathParam(ctx, "namespace")
+	logger = logger.With().Str("namespace", namespace).Logger()
+
+	if !hm.checkAdmin(ctx, namespace, logger) {
+		return
+	}
+
+	// A watch being started is stopped once it has synced or failed
+	unlock, _ := hm.watchLocks.lock(context.Background(), namespace)
+	defer unlock()
+	changed := hm.informerManager.StopInformer(namespace)
+	if changed {
+		logger.Info().Msg("Namespace watch stopped")
+	}
+
+	hm.writeJSONResponse(ctx, NamespaceWatchResponse{
+		Namespace:  namespace,
+		Watched:    false,
+		Changed:    changed,
+		Namespaces: hm.informerManager.GetAvailableNamespaces(),
+	}, 200, logger)
+}
+
+// namespaceLastError returns the last list or watch error of the informers of the namespace, if any
+func (hm *HandlerManager) namespaceLastError(namespace string) string {
+	for _, status := range hm.informerManager.NamespaceStatuses() {
+		if status.Namespace == namespace {
+			return status.LastError
+		}
+	}
+	return ""
+}
+
+// namespaceLocks serializes the watch changes of each namespace. The zero value is ready to use.
+type namespaceLocks struct {
+	mu    sync.Mutex
+	locks map[string]*namespaceLock
+}
+
+// namespaceLock is held by sending to ch; waiters counts the requests holding or waiting for it
+type namespaceLock struct {
+	ch      chan struct{}
+	waiters int
+}
+
+// lock acquires the lock of the namespace. It returns false when ctx is done first, otherwise the
+// caller must call unlock.
+func (l *namespaceLocks) lock(ctx context.Context, namespace string) (unlock func(), ok bool) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*namespaceLock)
+	}
+	lock, exists := l.locks[namespace]
+	if !exists {
+		lock = &namespaceLock{ch: make(chan struct{}, 1)}
+		l.locks[namespace] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	// release forgets the lock once nobody holds or waits for it, so the map does not grow
+	release := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if lock.waiters--; lock.waiters == 0 {
+			delete(l.locks, namespace)
+		}
+	}
+
+	select {
+	case lock.ch <- struct{}{}:
+		return func() {
+			<-lock.ch
+			release()
+		}, true
+	case <-ctx.Done():
+		release()
+		return func() {}, false
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/vanelin/k8s-controller/pkg/auth"
+)
+
+// namespaceAdminAuthorizer allows updating the listed namespaces and everything on deployments
+type namespaceAdminAuthorizer map[string]bool
+
+func (a namespaceAdminAuthorizer) Authorize(_ context.Context, _ *auth.User, attrs auth.Attributes) (bool, error) {
+	if attrs.Resource == "deployments" {
+		return true, nil
+	}
+	return attrs.Verb == "update" && attrs.Group == "" && attrs.Resource == "namespaces" &&
+		attrs.Namespace == attrs.Name && a[attrs.Name], nil
+}
+
+func watchRequest(handler fasthttp.RequestHandler, method, namespace, authorization string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/v1/namespaces/" + namespace + "/watch")
+	ctx.Request.Header.SetMethod(method)
+	if authorization != "" {
+		ctx.Request.Header.Set("Authorization", authorization)
+	}
+	handler(ctx)
+	return ctx
+}
+
+func decodeWatchResponse(t *testing.T, ctx *fasthttp.RequestCtx) NamespaceWatchResponse {
+	t.Helper()
+	require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+	var response NamespaceWatchResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	return response
+}
+
+func TestHandlerManager_NamespaceWatch(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "locked"}},
+		newTestDeployment("team-a", "api", 1),
+		newTestDeployment("team-b", "billing", 1),
+	)
+	// The server may not list the deployments of the locked namespace
+	clientset.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "locked" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", nil)
+	})
+	informerManager := startFakeInformers(t, clientset, []string{"team-a"})
+
+	opts := DefaultOptions()
+	opts.Authenticator = &fakeAuthenticator{}
+	opts.Authorizer = namespaceAdminAuthorizer{"team-a": true, "team-b": true, "missing": true, "locked": true}
+	opts.Clientset = clientset
+	opts.WatchSyncTimeout = time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	opts.InformerContext = ctx
+	handler := NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler()
+
+	t.Run("watch", func(t *testing.T) {
+		response := decodeWatchResponse(t, watchRequest(handler, "POST", "team-b", "Bearer good"))
+		assert.Equal(t, NamespaceWatchResponse{
+			Namespace: "team-b", Watched: true, Changed: true, Synced: true, Namespaces: []string{"team-a", "team-b"},
+		}, response)
+
+		ctx := listRequest(handler, "/api/v1/deployments/team-b", "Authorization", "Bearer good")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Contains(t, string(ctx.Response.Body()), `"deployments":["billing"]`)
+
+		// Watching again changes nothing
+		response = decodeWatchResponse(t, watchRequest(handler, "POST", "team-b", "Bearer good"))
+		assert.False(t, response.Changed)
+		assert.True(t, response.Synced)
+	})
+
+	t.Run("unwatch", func(t *testing.T) {
+		response := decodeWatchResponse(t, watchRequest(handler, "DELETE", "team-b", "Bearer good"))
+		assert.Equal(t, NamespaceWatchResponse{Namespace: "team-b", Changed: true, Namespaces: []string{"team-a"}}, response)
+		assert.False(t, informerManager.HasInformer("team-b"))
+
+		ctx := listRequest(handler, "/api/v1/deployments/team-b", "Authorization", "Bearer good")
+		assert.Equal(t, 404, ctx.Response.StatusCode())
+		assert.Contains(t, string(listRequest(handler, "/api/v1/deployments", "Authorization", "Bearer good").Response.Body()), `"total_count":1`)
+
+		response = decodeWatchResponse(t, watchRequest(handler, "DELETE", "team-b", "Bearer good"))
+		assert.False(t, response.Changed)
+	})
+
+	t.Run("missing namespace", func(t *testing.T) {
+		ctx := watchRequest(handler, "POST", "missing", "Bearer good")
+		assert.Equal(t, 404, ctx.Response.StatusCode())
+		assert.Contains(t, string(ctx.Response.Body()), CodeKubernetesAPI)
+		assert.False(t, informerManager.HasInformer("missing"))
+	})
+
+	t.Run("namespace that does not sync", func(t *testing.T) {
+		ctx := watchRequest(handler, "POST", "locked", "Bearer good")
+		assert.Equal(t, 504, ctx.Response.StatusCode())
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, CodeNamespaceSyncTimeout, response.Code)
+		assert.Contains(t, response.Message, "forbidden")
+		// The namespace is not left behind to fail readiness
+		assert.False(t, informerManager.HasInformer("locked"))
+		assert.NotContains(t, informerManager.SyncStatus(), "locked")
+	})
+
+	t.Run("concurrent requests for a namespace that does not sync", func(t *testing.T) {
+		// Requests that found the first one's informer used to answer 200 after it was stopped
+		var wg sync.WaitGroup
+		statuses := make([]int, 3)
+		for i := range statuses {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses[i] = watchRequest(handler, "POST", "locked", "Bearer good").Response.StatusCode()
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, []int{504, 504, 504}, statuses)
+		assert.False(t, informerManager.HasInformer("locked"))
+		assert.NotContains(t, informerManager.GetAvailableNamespaces(), "locked")
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		assert.Equal(t, 401, watchRequest(handler, "POST", "team-b", "").Response.StatusCode())
+		assert.Equal(t, 403, watchRequest(handler, "DELETE", "kube-system", "Bearer good").Response.StatusCode())
+
+		// Without authentication nobody is an admin
+		opts := DefaultOptions()
+		opts.Clientset = clientset
+		anonymous := NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler()
+		ctx := watchRequest(anonymous, "DELETE", "team-a", "")
+		assert.Equal(t, 403, ctx.Response.StatusCode())
+		assert.Contains(t, string(ctx.Response.Body()), CodeForbidden)
+		assert.True(t, informerManager.HasInformer("team-a"))
+	})
+}
+
+func TestHandlerManager_NamespaceWatchConfiguredAdmins(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b", "team-c"})
+	handler := func(mutate func(*Options)) fasthttp.RequestHandler {
+		opts := DefaultOptions()
+		opts.Authenticator = &fakeAuthenticator{}
+		mutate(&opts)
+		return NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler()
+	}
+
+	// Without an Authorizer or configured admins, authenticated users are not admins
+	ctx := watchRequest(handler(func(*Options) {}), "DELETE", "team-a", "Bearer good")
+	assert.Equal(t, 403, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), CodeForbidden)
+	ctx = watchRequest(handler(func(o *Options) { o.AdminUsers = []string{"bob"} }), "DELETE", "team-a", "Bearer good")
+	assert.Equal(t, 403, ctx.Response.StatusCode())
+	assert.True(t, informerManager.HasInformer("team-a"))
+
+	response := decodeWatchResponse(t, watchRequest(handler(func(o *Options) { o.AdminUsers = []string{"alice"} }), "DELETE", "team-a", "Bearer good"))
+	assert.True(t, response.Changed)
+	response = decodeWatchResponse(t, watchRequest(handler(func(o *Options) { o.AdminGroups = []string{"developers"} }), "DELETE", "team-b", "Bearer good"))
+	assert.True(t, response.Changed)
+
+	// Configured admins do not need the Authorizer's approval
+	response = decodeWatchResponse(t, watchRequest(handler(func(o *Options) {
+		o.AdminGroups = []string{"developers"}
+		o.Authorizer = &fakeAuthorizer{}
+	}), "DELETE", "team-c", "Bearer good"))
+	assert.Equal(t, []string{}, response.Namespaces)
+}
+
+func TestHandlerManager_NamespaceWatchAPIKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte(`
+keys:
+  - name: ops
+    hash: `+auth.HashAPIKey("ops")+`
+    namespaces: [team-a]
+    permissions: [read, admin]
+  - name: reader
+    hash: `+auth.HashAPIKey("reader")+`
+    namespaces: ["*"]
+    permissions: [read]
+`), 0o600))
+	apiKeys, err := auth.NewAPIKeyStore(keyFile)
+	require.NoError(t, err)
+
+	opts := DefaultOptions()
+	opts.Authenticator = apiKeys
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, []string{"team-a", "team-b"}), "test-version", opts).CreateHandler()
+
+	assert.Equal(t, 403, watchRequest(handler, "DELETE", "team-a", "Bearer reader").Response.StatusCode())
+	assert.Equal(t, 403, watchRequest(handler, "DELETE", "team-b", "Bearer ops").Response.StatusCode())
+	response := decodeWatchResponse(t, watchRequest(handler, "DELETE", "team-a", "Bearer ops"))
+	assert.Equal(t, []string{"team-b"}, response.Namespaces)
+}
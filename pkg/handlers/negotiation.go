@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"sigs.k8s.io/yaml"
+)
+
+// formatKey is the user value under which the negotiated *outputFormat of a request is stored
+const formatKey = "response.format"
+
+// outputFormat is a representation of list and detail responses, selected with the Accept header or ?output=
+type outputFormat struct {
+	// name is the value of the output query parameter
+	name        string
+	contentType string
+	// aliases are other media types accepted for the format
+	aliases []string
+}
+
+// outputFormats in order of preference, the first one is the default
+var outputFormats = []*outputFormat{
+	{name: "json", contentType: "application/json"},
+	{name: "yaml", contentType: "application/yaml", aliases: []string{"application/x-yaml", "text/yaml"}},
+	{name: "table", contentType: "text/plain"},
+	{name: "csv", contentType: "text/csv"},
+}
+
+// outputFormatNames returns the values of the output query parameter
+func outputFormatNames() []string {
+	names := make([]string, 0, len(outputFormats))
+	for _, f := range outputFormats {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// outputContentTypes returns the media types of the output formats
+func outputContentTypes() []string {
+	types := make([]string, 0, len(outputFormats))
+	for _, f := range outputFormats {
+		types = append(types, f.contentType)
+	}
+	return types
+}
+
+// tabular is implemented by list and detail responses, which can also be written as CSV or as an
+// aligned text table like kubectl get prints
+type tabular interface {
+	tableHeader() []string
+	tableRows() [][]string
+}
+
+// negotiatesFormat reports whether the route's response can be written in every output format
+func (rt *route) negotiatesFormat() bool {
+	_, ok := rt.response.(tabular)
+	return ok
+}
+
+// negotiateFormat selects the output format of the request from the output query parameter or the
+// Accept header and stores it for writeResponse. It writes a 406 response and returns false when no
+// supported format is acceptable.
+func (hm *HandlerManager) negotiateFormat(ctx *fasthttp.RequestCtx, logger zerolog.Logger) bool {
+	ctx.Response.Header.Add("Vary", "Accept")
+
+	var format *outputFormat
+	if name := string(ctx.QueryArgs().Peek("output")); name != "" {
+		for _, f := range outputFormats {
+			if f.name == name {
+				format = f
+			}
+		}
+		if format == nil {
+			hm.writeNotAcceptableResponse(ctx, "output", fmt.Sprintf("Unsupported output %q, use one of %s", name, strings.Join(outputFormatNames(), ", ")), logger)
+			return false
+		}
+	} else {
+		format = acceptedFormat(string(ctx.Request.Header.Peek("Accept")))
+		if format == nil {
+			hm.writeNotAcceptableResponse(ctx, "", "None of the accepted media types is supported, use one of "+strings.Join(outputContentTypes(), ", "), logger)
+			return false
+		}
+	}
+
+	ctx.SetUserValue(formatKey, format)
+	return true
+}
+
+// acceptedFormat returns the output format preferred by an Accept header, nil when none is acceptable.
+// Each format gets the quality of the most specific media range matching it; ties go to exact matches,
+// then to the range listed first, then to the order of outputFormats.
+func acceptedFormat(header string) *outputFormat {
+	if strings.TrimSpace(header) == "" {
+		return outputFormats[0]
+	}
+
+	type match struct {
+		quality     float64
+		specificity int
+		position    int
+	}
+	var best *outputFormat
+	var bestMatch match
+	for _, format := range outputFormats {
+		current := match{specificity: -1}
+		for position, part := range strings.Split(header, ",") {
+			mediaType, quality := parseMediaRange(part)
+			specificity := format.matches(mediaType)
+			if specificity > current.specificity {
+				current = match{quality: quality, specificity: specificity, position: position}
+			}
+		}
+		if current.specificity < 0 || current.quality <= 0 {
+			continue
+		}
+		if best == nil || current.quality > bestMatch.quality ||
+			current.quality == bestMatch.quality && (current.specificity > bestMatch.specificity ||
+				current.specificity == bestMatch.specificity && current.position < bestMatch.position) {
+			best, bestMatch = format, current
+		}
+	}
+	return best
+}
+
+// parseMediaRange splits an Accept header element into its lower-cased media range and quality
+func parseMediaRange(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	quality := 1.0
+	for _, param := range params[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(key, "q") {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			quality = q
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(params[0])), quality
+}
+
+// matches returns how specifically the media range matches the format: 2 for a media type, 1 for
+// type/* and 0 for */*. It returns -1 when the range does not match.
+func (f *outputFormat) matches(mediaRange string) int {
+	if mediaRange == "*/*" {
+		return 0
+	}
+	for _, contentType := range append([]string{f.contentType}, f.aliases...) {
+		if mediaRange == contentType {
+			return 2
+		}
+	}
+	// Aliases are not matched by wildcards, text/* means the table rather than YAML
+	mainType, _, _ := strings.Cut(f.contentType, "/")
+	if mediaRange == mainType+"/*" {
+		return 1
+	}
+	return -1
+}
+
+// requestFormat returns the format negotiated for the request, JSON when it was not negotiated
+func requestFormat(ctx *fasthttp.RequestCtx) *outputFormat {
+	if format, ok := ctx.UserValue(formatKey).(*outputFormat); ok {
+		return format
+	}
+	return outputFormats[0]
+}
+
+// writeNotAcceptableResponse writes a 406 response, parameter is set when the output query parameter is unsupported
+func (hm *HandlerManager) writeNotAcceptableResponse(ctx *fasthttp.RequestCtx, parameter, message string, logger zerolog.Logger) {
+	logger.Warn().Str("accept", string(ctx.Request.Header.Peek("Accept"))).Str("output", string(ctx.QueryArgs().Peek("output"))).Msg("No acceptable response format")
+
+	hm.writeProblemResponse(ctx, ErrorResponse{
+		Code:      CodeNotAcceptable,
+		Error:     "Not Acceptable",
+		Message:   message,
+		Parameter: parameter,
+	}, 406, logger)
+}
+
+// writeResponse writes a list or detail response in the format negotiated by negotiateFormat
+func (hm *HandlerManager) writeResponse(ctx *fasthttp.RequestCtx, data tabular, statusCode int, logger zerolog.Logger) {
+	format := requestFormat(ctx)
+
+	var body []byte
+	var err error
+	switch format.name {
+	case "json":
+		hm.writeJSONResponse(ctx, data, statusCode, logger)
+		return
+	case "yaml":
+		body, err = yaml.Marshal(data)
+	case "csv":
+		body, err = renderCSV(data)
+	case "table":
+		body, err = renderTable(data)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("format", format.name).Msg("Failed to render response")
+		hm.writeErrorResponse(ctx, CodeInternal, "Failed to serialize response", 500, logger)
+		return
+	}
+
+	ctx.SetStatusCode(statusCode)
+	ctx.Response.Header.Set("Content-Type", format.contentType+"; charset=utf-8")
+	if _, werr := ctx.Write(body); werr != nil {
+		logger.Error().Err(werr).Msg("Failed to write response")
+	}
+	logger.Info().Int("status_code", statusCode).Str("format", format.name).Msg("Response sent successfully")
+}
+
+// renderCSV writes the header and rows of the response as CSV
+func renderCSV(data tabular) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(data.tableHeader()); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(data.tableRows()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderTable writes the header and rows of the response as columns aligned like kubectl get
+func renderTable(data tabular) ([]byte, error) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 6, 4, 3, ' ', 0)
+	for _, row := range append([][]string{data.tableHeader()}, data.tableRows()...) {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			// Empty cells would shift the columns to their right when read by eye, kubectl prints <none>
+			if cell == "" {
+				cell = "<none>"
+			}
+			cells[i] = tableCellReplacer.Replace(cell)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(cells, "\t")); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tableCellReplacer keeps a cell on its line and in its column
+var tableCellReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+func (r NamespaceResponse) tableHeader() []string {
+	if r.Statuses != nil {
+		return namespaceStatusHeader()
+	}
+	return []string{"NAME"}
+}
+
+func (r NamespaceResponse) tableRows() [][]string {
+	if r.Statuses != nil {
+		return namespaceStatusRows(r.Statuses)
+	}
+	rows := make([][]string, 0, len(r.Namespaces))
+	for _, ns := range r.Namespaces {
+		rows = append(rows, []string{ns})
+	}
+	return rows
+}
+
+func (r DeploymentResponse) tableHeader() []string {
+	return []string{"NAMESPACE", "NAME"}
+}
+
+func (r DeploymentResponse) tableRows() [][]string {
+	rows := make([][]string, 0, len(r.Deployments))
+	for _, name := range r.Deployments {
+		rows = append(rows, []string{r.Namespace, name})
+	}
+	return rows
+}
+
+func (r DeploymentsAllResponse) tableHeader() []string {
+	return DeploymentResponse{}.tableHeader()
+}
+
+func (r DeploymentsAllResponse) tableRows() [][]string {
+	rows := make([][]string, 0, r.TotalCount)
+	for _, ns := range r.Namespaces {
+		rows = append(rows, ns.tableRows()...)
+	}
+	return rows
+}
+
+func (r DeploymentDetailResponse) tableHeader() []string {
+	return []string{"NAMESPACE", "NAME", "READY", "UP-TO-DATE", "AVAILABLE", "AGE", "IMAGES"}
+}
+
+func (r DeploymentDetailResponse) tableRows() [][]string {
+	return [][]string{{
+		r.Namespace,
+		r.Name,
+		fmt.Sprintf("%d/%d", r.Status.ReadyReplicas, r.Spec.Replicas),
+		strconv.Itoa(int(r.Status.UpdatedReplicas)),
+		strconv.Itoa(int(r.Status.AvailableReplicas)),
+		r.Age,
+		strings.Join(r.Images, ","),
+	}}
+}
+
+func (r DeploymentRevisionsResponse) tableHeader() []string {
+	return []string{"REVISION", "REPLICASET", "CURRENT", "READY", "IMAGES", "CHANGE-CAUSE"}
+}
+
+func (r DeploymentRevisionsResponse) tableRows() [][]string {
+	rows := make([][]string, 0, len(r.Revisions))
+	for _, rev := range r.Revisions {
+		rows = append(rows, []string{
+			strconv.FormatInt(rev.Revision, 10),
+			rev.ReplicaSet,
+			strconv.FormatBool(rev.Current),
+			fmt.Sprintf("%d/%d", rev.ReadyReplicas, rev.Replicas),
+			strings.Join(rev.Images, ","),
+			rev.ChangeCause,
+		})
+	}
+	return rows
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"sigs.k8s.io/yaml"
+)
+
+func TestAcceptedFormat(t *testing.T) {
+	tests := map[string]string{
+		"":                                      "json",
+		"*/*":                                   "json",
+		"application/yaml":                      "yaml",
+		"text/yaml":                             "yaml",
+		"text/csv":                              "csv",
+		"text/plain":                            "table",
+		"text/*":                                "table",
+		"text/html, text/csv;q=0.5":             "csv",
+		"text/csv, */*":                         "csv",
+		"application/json;q=0.5, text/csv":      "csv",
+		"text/plain, application/yaml":          "table",
+		"application/json;q=0, */*":             "yaml",
+		"application/YAML; charset=utf-8":       "yaml",
+		"text/csv;q=0.9, application/json;q=.9": "csv",
+		"application/xml":                       "",
+		"text/csv;q=0":                          "",
+	}
+	for header, want := range tests {
+		format := acceptedFormat(header)
+		if want == "" {
+			assert.Nil(t, format, header)
+			continue
+		}
+		require.NotNil(t, format, header)
+		assert.Equal(t, want, format.name, header)
+	}
+}
+
+func TestHandlerManager_NegotiatedFormats(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"},
+		newTestDeployment("team-a", "backend", 1),
+		newTestDeployment("team-a", "frontend", 1),
+		newDetailTestDeployment(),
+		newTestDeployment("team-b", "worker", 2),
+	)
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+	get := func(uri, accept string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.SetMethod("GET")
+		if accept != "" {
+			ctx.Request.Header.Set("Accept", accept)
+		}
+		handler(ctx)
+		return ctx
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		ctx := get("/deployments/team-a", "application/yaml")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Equal(t, "application/yaml; charset=utf-8", string(ctx.Response.Header.ContentType()))
+		assert.Equal(t, "Accept", string(ctx.Response.Header.Peek("Vary")))
+
+		var response DeploymentResponse
+		require.NoError(t, yaml.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, []string{"api", "backend", "frontend"}, response.Deployments)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		ctx := get("/deployments", "text/csv")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Equal(t, "text/csv; charset=utf-8", string(ctx.Response.Header.ContentType()))
+		assert.Equal(t, "NAMESPACE,NAME\nteam-a,api\nteam-a,backend\nteam-a,frontend\nteam-b,worker\n", string(ctx.Response.Body()))
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		ctx := get("/namespaces?output=table", "application/json")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Equal(t, "text/plain; charset=utf-8", string(ctx.Response.Header.ContentType()))
+		assert.Equal(t, "NAME\nteam-a\nteam-b\n", string(ctx.Response.Body()))
+
+		ctx = get("/deployments/team-a/api", "text/plain")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		lines := string(ctx.Response.Body())
+		assert.Regexp(t, `^NAMESPACE   NAME   READY   UP-TO-DATE   AVAILABLE   AGE   IMAGES\n`, lines)
+		assert.Regexp(t, `\nteam-a      api    2/3     0            2           \S+\s+migrate:1\.0,nginx:1\.21\n$`, lines)
+	})
+
+	t.Run("JSONByDefault", func(t *testing.T) {
+		ctx := get("/deployments/team-b", "")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+		var response DeploymentResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, []string{"worker"}, response.Deployments)
+	})
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		ctx := get("/deployments", "application/xml")
+		require.Equal(t, 406, ctx.Response.StatusCode())
+		assert.Equal(t, problemContentType, string(ctx.Response.Header.ContentType()))
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, CodeNotAcceptable, response.Code)
+		assert.Contains(t, response.Message, "application/json, application/yaml, text/plain, text/csv")
+
+		ctx = get("/deployments?output=xml", "")
+		require.Equal(t, 406, ctx.Response.StatusCode())
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, "output", response.Parameter)
+		assert.Contains(t, response.Message, "json, yaml, table, csv")
+	})
+
+	t.Run("ErrorsStayJSON", func(t *testing.T) {
+		ctx := get("/deployments/team-c", "text/csv")
+		require.Equal(t, 404, ctx.Response.StatusCode())
+		assert.Equal(t, problemContentType, string(ctx.Response.Header.ContentType()))
+	})
+}
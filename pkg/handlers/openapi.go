@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the generated document follows
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPIDocument is the subset of an OpenAPI 3 document produced by BuildOpenAPI
+type OpenAPIDocument struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       OpenAPIInfo                     `json:"info"`
+	Paths      map[string]map[string]OpenAPIOp `json:"paths"`
+	Components OpenAPIComponents               `json:"components"`
+}
+
+// OpenAPIInfo describes the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIOp is an operation on a path
+type OpenAPIOp struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a path or query parameter
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody is the body of a request
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse is a response of an operation
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType is the schema of a body in one content type
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIComponents holds the schemas referenced from operations
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas"`
+}
+
+// OpenAPISchema is a JSON schema as used by OpenAPI 3.0
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// queryParam documents a query parameter of a route
+type queryParam struct {
+	name        string
+	kind        string // string, boolean or integer
+	description string
+	enum        []string
+}
+
+// Query parameters shared by several routes
+var (
+	deploymentFilterParams = []queryParam{
+		{name: "labelSelector", kind: "string", description: "Kubernetes label selector"},
+		{name: "fieldSelector", kind: "string", description: "Field selector on metadata.name, metadata.namespace, spec.paused or status.available"},
+		{name: "namePrefix", kind: "string", description: "Only deployments whose name starts with the prefix"},
+		{name: "available", kind: "boolean", description: "Only available (true) or unavailable (false) deployments"},
+		{name: "paused", kind: "boolean", description: "Only paused (true) or running (false) deployments"},
+		{name: "image", kind: "string", description: "Container image, repeatable; an image without tag matches any tag"},
+	}
+	listParams = []queryParam{
+		{name: "limit", kind: "integer", description: "Maximum number of items per page (max 1000)"},
+		{name: "continue", kind: "string", description: "Continue token from the previous page"},
+		{name: "sortBy", kind: "string", enum: []string{sortByName, sortByCreationTimestamp, sortByReadyReplicas}},
+		{name: "order", kind: "string", enum: []string{"asc", "desc"}},
+	}
+	eventParams = []queryParam{
+		{name: "namespace", kind: "string", description: "Comma-separated namespaces to stream, repeatable"},
+		{name: "name", kind: "string", description: "Comma-separated deployment names to stream, repeatable"},
+		{name: "lastEventId", kind: "string", description: "Resume after this event ID, same as the Last-Event-ID header"},
+	}
+	dryRunParams = []queryParam{
+		{name: "dryRun", kind: "boolean", description: "Validate the change on the API server without persisting it"},
+	}
+	deploymentListParams = append(append([]queryParam{}, deploymentFilterParams...), listParams...)
+	rollbackParams       = append([]queryParam{
+		{name: "revision", kind: "integer", description: "Revision to roll back to, the previous one when 0 or unset"},
+	}, dryRunParams...)
+	outputParam = queryParam{
+		name: "output", kind: "string", description: "Response format, overrides the Accept header", enum: outputFormatNames(),
+	}
+	namespaceParams = []queryParam{
+		{name: "status", kind: "boolean", description: "Add the informer sync state and statistics of each namespace"},
+	}
+	summaryParams = []queryParam{
+		{name: "top", kind: "integer", description: "Length of top_unavailable, 10 when unset, at most 100"},
+	}
+	probeParams = []queryParam{
+		{name: "verbose", kind: "string", description: "List every check, even on success"},
+		{name: "exclude", kind: "string", description: "Name of a check to skip, repeatable"},
+	}
+)
+
+// BuildOpenAPI builds the OpenAPI document of the routes served by the handler manager
+func (hm *HandlerManager) BuildOpenAPI() *OpenAPIDocument {
+	return buildOpenAPI(hm.routes(), hm.appVersion)
+}
+
+// buildOpenAPI builds the OpenAPI document for the routes, using their Go request and response types as schemas
+func buildOpenAPI(routes []route, version string) *OpenAPIDocument {
+	schemas := newSchemaRegistry()
+	errorContent := map[string]OpenAPIMediaType{
+		problemContentType: {Schema: schemas.schemaFor(reflect.TypeOf(ErrorResponse{}))},
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       "Kubernetes Controller API",
+			Description: "Read and manage the Deployments watched by k8s-controller. Every path is also served without the " + APIPrefix + " prefix.",
+			Version:     version,
+		},
+		Paths:      make(map[string]map[string]OpenAPIOp),
+		Components: OpenAPIComponents{Schemas: schemas.schemas},
+	}
+
+	for _, rt := range routes {
+		op := OpenAPIOp{
+			OperationID: rt.operationID,
+			Summary:     rt.summary,
+			Responses:   map[string]OpenAPIResponse{"default": {Description: "Error", Content: errorContent}},
+		}
+
+		for _, segment := range splitPath(rt.pattern) {
+			if name, ok := patternParam(segment); ok {
+				op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+			}
+		}
+		query := rt.query
+		if rt.negotiatesFormat() {
+			query = append(append([]queryParam{}, query...), outputParam)
+		}
+		for _, q := range query {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:        q.name,
+				In:          "query",
+				Description: q.description,
+				Schema:      &OpenAPISchema{Type: q.kind, Enum: q.enum},
+			})
+		}
+
+		if rt.conditional {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:        "If-None-Match",
+				In:          "header",
+				Description: "ETag of a previous response, answered with 304 when the response is unchanged",
+				Schema:      &OpenAPISchema{Type: "string"},
+			})
+			op.Responses[strconv.Itoa(fasthttp.StatusNotModified)] = OpenAPIResponse{Description: fasthttp.StatusMessage(fasthttp.StatusNotModified)}
+		}
+
+		if rt.request != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  map[string]OpenAPIMediaType{"application/json": {Schema: schemas.schemaFor(reflect.TypeOf(rt.request))}},
+			}
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = 200
+		}
+		success := OpenAPIResponse{Description: fasthttp.StatusMessage(status)}
+		contentType := rt.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		switch {
+		case rt.negotiatesFormat():
+			// YAML has the same structure as JSON, CSV and table responses hold one row per item
+			schema := schemas.schemaFor(reflect.TypeOf(rt.response))
+			success.Content = make(map[string]OpenAPIMediaType, len(outputFormats))
+			for _, f := range outputFormats {
+				switch f.name {
+				case "json", "yaml":
+					success.Content[f.contentType] = OpenAPIMediaType{Schema: schema}
+				default:
+					success.Content[f.contentType] = OpenAPIMediaType{Schema: &OpenAPISchema{Type: "string"}}
+				}
+			}
+		case rt.response != nil:
+			success.Content = map[string]OpenAPIMediaType{contentType: {Schema: schemas.schemaFor(reflect.TypeOf(rt.response))}}
+		case status != fasthttp.StatusSwitchingProtocols:
+			success.Content = map[string]OpenAPIMediaType{contentType: {Schema: &OpenAPISchema{}}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		for _, message := range rt.messages {
+			schemas.schemaFor(reflect.TypeOf(message))
+		}
+
+		path := APIPrefix + rt.pattern
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]OpenAPIOp)
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+
+	return doc
+}
+
+// patternParam returns the parameter name of a {name} pattern segment
+func patternParam(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// schemaRegistry generates schemas from Go types. Structs become named component schemas.
+type schemaRegistry struct {
+	schemas map[string]*OpenAPISchema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*OpenAPISchema)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the schema of a Go type as encoding/json marshals it
+func (r *schemaRegistry) schemaFor(t reflect.Type) *OpenAPISchema {
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.schemaFor(t.Elem())
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &OpenAPISchema{Type: "array", Items: r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		ref := &OpenAPISchema{Ref: "#/components/schemas/" + t.Name()}
+		if _, exists := r.schemas[t.Name()]; exists {
+			return ref
+		}
+		schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+		// Register before walking the fields, so recursive types terminate
+		r.schemas[t.Name()] = schema
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, omitEmpty, ok := jsonFieldName(field)
+			if !ok {
+				continue
+			}
+			schema.Properties[name] = r.schemaFor(field.Type)
+			if !omitEmpty {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		sort.Strings(schema.Required)
+		return ref
+	default:
+		// interface{} and other dynamic values accept any JSON
+		return &OpenAPISchema{}
+	}
+}
+
+// jsonFieldName returns the JSON name of a struct field and whether it is omitted when empty
+func jsonFieldName(field reflect.StructField) (name string, omitEmpty bool, ok bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, true
+}
+
+// handleOpenAPI handles GET /openapi.json - returns the OpenAPI document of the API
+func (hm *HandlerManager) handleOpenAPI(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	logger.Info().Msg("OpenAPI request received")
+
+	body, err := json.MarshalIndent(hm.BuildOpenAPI(), "", "  ")
+	if err != nil {
+		hm.writeErrorResponse(ctx, CodeInternal, "Failed to build OpenAPI document", 500, logger)
+		return
+	}
+
+	ctx.SetStatusCode(200)
+	ctx.Response.Header.Set("Content-Type", "application/json")
+	ctx.SetBody(body)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var updateOpenAPI = flag.Bool("update-openapi", false, "rewrite api/openapi.json from the route table")
+
+// publishedOpenAPIPath is the document clients are generated from
+var publishedOpenAPIPath = filepath.Join("..", "..", "api", "openapi.json")
+
+// publishedOpenAPIVersion is the info.version of the published document; the server reports its build version
+const publishedOpenAPIVersion = "v1"
+
+func TestOpenAPI_PublishedDocument(t *testing.T) {
+	generated, err := json.MarshalIndent(buildOpenAPI(NewHandlerManager(nil, "").routes(), publishedOpenAPIVersion), "", "  ")
+	require.NoError(t, err)
+	generated = append(generated, '\n')
+
+	if *updateOpenAPI {
+		require.NoError(t, os.MkdirAll(filepath.Dir(publishedOpenAPIPath), 0o755))
+		require.NoError(t, os.WriteFile(publishedOpenAPIPath, generated, 0o644))
+	}
+
+	published, err := os.ReadFile(publishedOpenAPIPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(published), string(generated), "api/openapi.json is out of date, run make openapi")
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	doc := NewHandlerManager(nil, "test-version").BuildOpenAPI()
+
+	operationIDs := make(map[string]bool)
+	for _, rt := range NewHandlerManager(nil, "").routes() {
+		op, ok := doc.Paths[APIPrefix+rt.pattern][strings.ToLower(rt.method)]
+		require.True(t, ok, "%s %s is not documented", rt.method, rt.pattern)
+		assert.NotEmpty(t, op.OperationID, rt.pattern)
+		assert.False(t, operationIDs[op.OperationID], "duplicate operationId %s", op.OperationID)
+		operationIDs[op.OperationID] = true
+	}
+
+	assert.Equal(t, "test-version", doc.Info.Version)
+	for _, name := range []string{"DeploymentResponse", "NamespaceResponse", "DeploymentsAllResponse", "ErrorResponse", "WatchEvent"} {
+		assert.Contains(t, doc.Components.Schemas, name)
+	}
+}
+
+func TestHandlerManager_handleOpenAPI(t *testing.T) {
+	handler := NewHandlerManager(newFakeInformerManager(t, nil), "test-version").CreateHandler()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/v1/openapi.json")
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+
+	assert.Equal(t, 200, ctx.Response.StatusCode())
+	var doc OpenAPIDocument
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &doc))
+	assert.Equal(t, OpenAPIVersion, doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/api/v1/deployments/{namespace}/{name}")
+}
+
+// TestOpenAPI_ResponsesMatchPublishedSchemas calls the handlers and validates their JSON responses
+// against the published document, so a response type change without make openapi fails here.
+func TestOpenAPI_ResponsesMatchPublishedSchemas(t *testing.T) {
+	raw, err := os.ReadFile(publishedOpenAPIPath)
+	require.NoError(t, err)
+	var doc OpenAPIDocument
+	require.NoError(t, json.Unmarshal(raw, &doc))
+
+	deployment := newTestDeployment("team-a", "api", 2)
+	deployment.UID = types.UID("api-uid")
+	deployment.Annotations = map[string]string{"deployment.kubernetes.io/revision": "2"}
+	deployment.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: "True", LastUpdateTime: metav1.Now()}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:              "api-1",
+		Namespace:         "team-a",
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		Annotations:       map[string]string{"deployment.kubernetes.io/revision": "1"},
+		OwnerReferences:   []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+	}, Spec: appsv1.ReplicaSetSpec{Template: deployment.Spec.Template}}
+
+	replicaSet.UID = types.UID("api-1-uid")
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "api-1-x",
+		Namespace:       "team-a",
+		Labels:          deployment.Spec.Selector.MatchLabels,
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(replicaSet, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))},
+	}, Status: corev1.PodStatus{Phase: corev1.PodRunning, StartTime: &metav1.Time{Time: time.Now()}, ContainerStatuses: []corev1.ContainerStatus{{
+		Name: "nginx", RestartCount: 1, LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+	}}}}
+
+	clientset := fake.NewSimpleClientset(deployment, replicaSet, pod)
+	opts := DefaultOptions()
+	opts.EnableMutations = true
+	opts.Clientset = clientset
+	handler := NewHandlerManagerWithOptions(startFakeInformers(t, clientset, []string{"team-a"}), "test-version", opts).CreateHandler()
+
+	requests := []struct {
+		method, pattern, uri, body string
+	}{
+		{"GET", "/", "/api/v1/", ""},
+		{"GET", "/namespaces", "/api/v1/namespaces", ""},
+		{"GET", "/deployments", "/api/v1/deployments", ""},
+		{"GET", "/deployments", "/api/v1/deployments?limit=1", ""},
+		{"GET", "/deployments/{namespace}", "/api/v1/deployments/team-a", ""},
+		{"GET", "/deployments/{namespace}/{name}", "/api/v1/deployments/team-a/api", ""},
+		{"GET", "/deployments/{namespace}/{name}/revisions", "/api/v1/deployments/team-a/api/revisions", ""},
+		{"GET", "/deployments/{namespace}/{name}/pods", "/api/v1/deployments/team-a/api/pods", ""},
+		{"POST", "/deployments/{namespace}/{name}/scale", "/api/v1/deployments/team-a/api/scale", `{"replicas":3}`},
+		{"POST", "/deployments/{namespace}/{name}/rollback", "/api/v1/deployments/team-a/api/rollback?dryRun=true", ""},
+		{"GET", "/deployments/{namespace}", "/api/v1/deployments/team-b", ""},
+		{"GET", "/deployments", "/api/v1/deployments?limit=-1", ""},
+		{"DELETE", "/deployments", "/api/v1/deployments", ""},
+	}
+
+	for _, req := range requests {
+		t.Run(req.method+" "+req.uri, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.SetRequestURI(req.uri)
+			ctx.Request.Header.SetMethod(req.method)
+			ctx.Request.SetBodyString(req.body)
+			handler(ctx)
+
+			pathItem, ok := doc.Paths[APIPrefix+req.pattern]
+			require.True(t, ok, "path %s is not published", req.pattern)
+			op, ok := pathItem[strings.ToLower(req.method)]
+			if !ok {
+				// Undocumented methods are answered with a problem document
+				op = pathItem["get"]
+			}
+			response, ok := op.Responses[strconv.Itoa(ctx.Response.StatusCode())]
+			if !ok {
+				response = op.Responses["default"]
+			}
+
+			contentType := string(ctx.Response.Header.ContentType())
+			media, ok := response.Content[contentType]
+			require.True(t, ok, "status %d with content type %s is not published", ctx.Response.StatusCode(), contentType)
+
+			var body interface{}
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+			assert.Empty(t, validateSchema(&doc, media.Schema, body, "$"))
+		})
+	}
+}
+
+// validateSchema returns the places where value does not conform to the schema
+func validateSchema(doc *OpenAPIDocument, schema *OpenAPISchema, value interface{}, at string) []string {
+	if schema.Ref != "" {
+		resolved, ok := doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, schema.Ref)}
+		}
+		schema = resolved
+	}
+
+	var problems []string
+	mismatch := func() []string {
+		return []string{fmt.Sprintf("%s: expected %s, got %T", at, schema.Type, value)}
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %s", at, name))
+			}
+		}
+		for name, v := range obj {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				problems = append(problems, fmt.Sprintf("%s: unexpected property %s", at, name))
+				continue
+			}
+			problems = append(problems, validateSchema(doc, property, v, at+"."+name)...)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for i, item := range items {
+			problems = append(problems, validateSchema(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return mismatch()
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, s))
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return mismatch()
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return mismatch()
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch()
+		}
+	}
+	return problems
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Sort keys supported by the sortBy query parameter
+const (
+	sortByName              = "name"
+	sortByCreationTimestamp = "creationTimestamp"
+	sortByReadyReplicas     = "readyReplicas"
+)
+
+const (
+	// maxListLimit caps the page size a client can request
+	maxListLimit = 1000
+	// defaultListSnapshotTTL is how long a continue token stays valid
+	defaultListSnapshotTTL = 5 * time.Minute
+	// maxListSnapshots bounds the memory used by outstanding continue tokens
+	maxListSnapshots = 256
+)
+
+// errContinueExpired is returned when the snapshot behind a continue token is gone
+var errContinueExpired = errors.New("continue token has expired, restart the list without it")
+
+// listOptions holds the pagination and sorting query parameters of list endpoints
+type listOptions struct {
+	limit      int
+	continueAt string
+	sortBy     string
+	descending bool
+}
+
+// deploymentItem identifies a deployment in a paginated list
+type deploymentItem struct {
+	Namespace       string
+	Name            string
+	ResourceVersion string
+}
+
+// listPage is a page of deployment items with the token to fetch the next one
+type listPage struct {
+	items      []deploymentItem
+	continueAt string
+	remaining  *int64
+}
+
+// continueToken is the decoded form of the opaque continue query parameter
+type continueToken struct {
+	Snapshot string `json:"s"`
+	Offset   int    `json:"o"`
+}
+
+// listSnapshot is an immutable, sorted result set that continue tokens page through
+type listSnapshot struct {
+	items       []deploymentItem
+	fingerprint string
+	expires     time.Time
+}
+
+// listSnapshotStore keeps list snapshots so that all pages of a list come from the same cache state
+type listSnapshotStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	snapshots map[string]*listSnapshot
+	now       func() time.Time
+}
+
+// newListSnapshotStore creates a snapshot store; a non-positive ttl selects the default
+func newListSnapshotStore(ttl time.Duration) *listSnapshotStore {
+	if ttl <= 0 {
+		ttl = defaultListSnapshotTTL
+	}
+	return &listSnapshotStore{
+		ttl:       ttl,
+		snapshots: make(map[string]*listSnapshot),
+		now:       time.Now,
+	}
+}
+
+// put stores a snapshot and returns its identifier
+func (s *listSnapshotStore) put(items []deploymentItem, fingerprint string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictLocked(now)
+
+	id := newSnapshotID()
+	s.snapshots[id] = &listSnapshot{
+		items:       items,
+		fingerprint: fingerprint,
+		expires:     now.Add(s.ttl),
+	}
+	return id
+}
+
+// get returns a snapshot that has not expired yet
+func (s *listSnapshotStore) get(id string) (*listSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, ok := s.snapshots[id]
+	if !ok || s.now().After(snapshot.expires) {
+		delete(s.snapshots, id)
+		return nil, false
+	}
+	return snapshot, true
+}
+
+// evictLocked drops expired snapshots and, if the store is still full, the one closest to expiry
+func (s *listSnapshotStore) evictLocked(now time.Time) {
+	var oldestID string
+	var oldest time.Time
+	for id, snapshot := range s.snapshots {
+		if now.After(snapshot.expires) {
+			delete(s.snapshots, id)
+			continue
+		}
+		if oldestID == "" || snapshot.expires.Before(oldest) {
+			oldestID, oldest = id, snapshot.expires
+		}
+	}
+	if len(s.snapshots) >= maxListSnapshots {
+		delete(s.snapshots, oldestID)
+	}
+}
+
+// newSnapshotID returns a random, unguessable snapshot identifier
+func newSnapshotID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseListOptions reads the limit, continue, sortBy and order query parameters
+func parseListOptions(args *fasthttp.Args) (listOptions, error) {
+	opts := listOptions{
+		continueAt: string(args.Peek("continue")),
+		sortBy:     sortByName,
+	}
+
+	if raw := string(args.Peek("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return opts, &parameterError{Parameter: "limit", Err: fmt.Errorf("must be a non-negative integer")}
+		}
+		if limit > maxListLimit {
+			return opts, &parameterError{Parameter: "limit", Err: fmt.Errorf("must not exceed %d", maxListLimit)}
+		}
+		opts.limit = limit
+	}
+
+	if raw := string(args.Peek("sortBy")); raw != "" {
+		switch raw {
+		case sortByName, sortByCreationTimestamp, sortByReadyReplicas:
+			opts.sortBy = raw
+		default:
+			return opts, &parameterError{
+				Parameter: "sortBy",
+				Err:       fmt.Errorf("must be one of: %s", strings.Join([]string{sortByName, sortByCreationTimestamp, sortByReadyReplicas}, ", ")),
+			}
+		}
+	}
+
+	switch order := string(args.Peek("order")); order {
+	case "", "asc":
+	case "desc":
+		opts.descending = true
+	default:
+		return opts, &parameterError{Parameter: "order", Err: fmt.Errorf("must be asc or desc")}
+	}
+
+	return opts, nil
+}
+
+// sortDeployments orders deployments by the requested key, breaking ties by name so the order is deterministic
+func sortDeployments(deployments []*appsv1.Deployment, opts listOptions) {
+	less := func(a, b *appsv1.Deployment) bool {
+		switch opts.sortBy {
+		case sortByCreationTimestamp:
+			if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+				return a.CreationTimestamp.Before(&b.CreationTimestamp)
+			}
+		case sortByReadyReplicas:
+			if a.Status.ReadyReplicas != b.Status.ReadyReplicas {
+				return a.Status.ReadyReplicas < b.Status.ReadyReplicas
+			}
+		}
+		return a.Name < b.Name
+	}
+
+	sort.Slice(deployments, func(i, j int) bool {
+		if opts.descending {
+			return less(deployments[j], deployments[i])
+		}
+		return less(deployments[i], deployments[j])
+	})
+}
+
+// listFingerprint identifies the query a continue token belongs to, ignoring the pagination and output parameters
+func listFingerprint(ctx *fasthttp.RequestCtx) string {
+	var params []string
+	ctx.QueryArgs().VisitAll(func(key, value []byte) {
+		k := string(key)
+		if k == "limit" || k == "continue" || k == "output" {
+			return
+		}
+		params = append(params, k+"="+string(value))
+	})
+	sort.Strings(params)
+	// Snapshots only hold what their creator was allowed to see, tie them to that user
+	if user := requestUser(ctx); user != nil {
+		params = append(params, "user="+user.Name)
+	}
+	return string(ctx.Path()) + "?" + strings.Join(params, "&")
+}
+
+// paginate returns the requested page. Without a continue token the items are produced by collect;
+// with one they come from the snapshot taken when the first page was served.
+func (hm *HandlerManager) paginate(opts listOptions, fingerprint string, collect func() []deploymentItem) (listPage, error) {
+	var items []deploymentItem
+	var snapshotID string
+	offset := 0
+
+	if opts.continueAt != "" {
+		token, err := decodeContinueToken(opts.continueAt)
+		if err != nil {
+			return listPage{}, &parameterError{Parameter: "continue", Err: err}
+		}
+		snapshot, ok := hm.listSnapshots.get(token.Snapshot)
+		if !ok {
+			return listPage{}, errContinueExpired
+		}
+		if snapshot.fingerprint != fingerprint {
+			return listPage{}, &parameterError{Parameter: "continue", Err: fmt.Errorf("token does not belong to this query")}
+		}
+		if token.Offset < 0 || token.Offset > len(snapshot.items) {
+			return listPage{}, &parameterError{Parameter: "continue", Err: fmt.Errorf("offset out of range")}
+		}
+		items, offset, snapshotID = snapshot.items, token.Offset, token.Snapshot
+	} else {
+		items = collect()
+	}
+
+	end := len(items)
+	if opts.limit > 0 && offset+opts.limit < len(items) {
+		end = offset + opts.limit
+	}
+
+	page := listPage{items: items[offset:end]}
+	if end < len(items) {
+		if snapshotID == "" {
+			snapshotID = hm.listSnapshots.put(items, fingerprint)
+		}
+		page.continueAt = encodeContinueToken(continueToken{Snapshot: snapshotID, Offset: end})
+		remaining := int64(len(items) - end)
+		page.remaining = &remaining
+	}
+	return page, nil
+}
+
+func encodeContinueToken(token continueToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeContinueToken(raw string) (continueToken, error) {
+	var token continueToken
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return token, fmt.Errorf("malformed token")
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.Snapshot == "" {
+		return token, fmt.Errorf("malformed token")
+	}
+	return token, nil
+}
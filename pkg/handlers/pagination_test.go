@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseListOptions(t *testing.T) {
+	tests := []struct {
+		query     string
+		want      listOptions
+		parameter string
+	}{
+		{query: "", want: listOptions{sortBy: sortByName}},
+		{query: "limit=10&sortBy=readyReplicas&order=desc", want: listOptions{limit: 10, sortBy: sortByReadyReplicas, descending: true}},
+		{query: "continue=abc&sortBy=creationTimestamp&order=asc", want: listOptions{continueAt: "abc", sortBy: sortByCreationTimestamp}},
+		{query: "limit=-1", parameter: "limit"},
+		{query: "limit=ten", parameter: "limit"},
+		{query: "limit=100000", parameter: "limit"},
+		{query: "sortBy=age", parameter: "sortBy"},
+		{query: "order=random", parameter: "order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			args := fasthttp.AcquireArgs()
+			defer fasthttp.ReleaseArgs(args)
+			args.Parse(tt.query)
+
+			opts, err := parseListOptions(args)
+			if tt.parameter != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.parameter, err.(*parameterError).Parameter)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, opts)
+		})
+	}
+}
+
+func TestListSnapshotStore_Expiry(t *testing.T) {
+	store := newListSnapshotStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	id := store.put([]deploymentItem{{Namespace: "default", Name: "a"}}, "fp")
+	_, ok := store.get(id)
+	require.True(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = store.get(id)
+	require.False(t, ok)
+}
+
+func TestListSnapshotStore_Bounded(t *testing.T) {
+	store := newListSnapshotStore(time.Minute)
+	for i := 0; i < maxListSnapshots+10; i++ {
+		store.put(nil, "fp")
+	}
+	assert.LessOrEqual(t, len(store.snapshots), maxListSnapshots)
+}
+
+func TestHandlerManager_PaginatedDeployments(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	a := newTestDeployment("team-a", "alpha", 3)
+	a.CreationTimestamp = metav1.NewTime(base.Add(3 * time.Minute))
+	a.Status.ReadyReplicas = 1
+	b := newTestDeployment("team-a", "bravo", 3)
+	b.CreationTimestamp = metav1.NewTime(base.Add(1 * time.Minute))
+	b.Status.ReadyReplicas = 3
+	c := newTestDeployment("team-a", "charlie", 3)
+	c.CreationTimestamp = metav1.NewTime(base.Add(2 * time.Minute))
+	c.Status.ReadyReplicas = 2
+	d := newTestDeployment("team-b", "delta", 1)
+
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"}, c, a, d, b)
+	handlerManager := NewHandlerManager(informerManager, "test-version")
+	handler := handlerManager.CreateHandler()
+
+	get := func(t *testing.T, uri string, out interface{}) int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+		if out != nil && ctx.Response.StatusCode() == 200 {
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), out))
+		}
+		return ctx.Response.StatusCode()
+	}
+
+	t.Run("DefaultOrderIsByName", func(t *testing.T) {
+		var response DeploymentResponse
+		require.Equal(t, 200, get(t, "/deployments/team-a", &response))
+		assert.Equal(t, []string{"alpha", "bravo", "charlie"}, response.Deployments)
+		assert.Empty(t, response.Continue)
+		assert.Nil(t, response.RemainingItemCount)
+	})
+
+	t.Run("SortBy", func(t *testing.T) {
+		var response DeploymentResponse
+		require.Equal(t, 200, get(t, "/deployments/team-a?sortBy=creationTimestamp", &response))
+		assert.Equal(t, []string{"bravo", "charlie", "alpha"}, response.Deployments)
+
+		require.Equal(t, 200, get(t, "/deployments/team-a?sortBy=readyReplicas&order=desc", &response))
+		assert.Equal(t, []string{"bravo", "charlie", "alpha"}, response.Deployments)
+	})
+
+	t.Run("ContinueToken", func(t *testing.T) {
+		var first DeploymentResponse
+		require.Equal(t, 200, get(t, "/deployments/team-a?limit=2", &first))
+		assert.Equal(t, []string{"alpha", "bravo"}, first.Deployments)
+		require.NotEmpty(t, first.Continue)
+		require.NotNil(t, first.RemainingItemCount)
+		assert.Equal(t, int64(1), *first.RemainingItemCount)
+
+		var second DeploymentResponse
+		require.Equal(t, 200, get(t, "/deployments/team-a?limit=2&continue="+first.Continue, &second))
+		assert.Equal(t, []string{"charlie"}, second.Deployments)
+		assert.Empty(t, second.Continue)
+
+		// A token cannot be replayed against a different query
+		assert.Equal(t, 400, get(t, "/deployments/team-a?limit=2&sortBy=readyReplicas&continue="+first.Continue, nil))
+	})
+
+	t.Run("AllNamespaces", func(t *testing.T) {
+		var first DeploymentsAllResponse
+		require.Equal(t, 200, get(t, "/deployments?limit=3", &first))
+		require.Len(t, first.Namespaces, 1)
+		assert.Equal(t, "team-a", first.Namespaces[0].Namespace)
+		assert.Equal(t, 3, first.TotalCount)
+		require.NotEmpty(t, first.Continue)
+
+		var second DeploymentsAllResponse
+		require.Equal(t, 200, get(t, "/deployments?limit=3&continue="+first.Continue, &second))
+		require.Len(t, second.Namespaces, 1)
+		assert.Equal(t, "team-b", second.Namespaces[0].Namespace)
+		assert.Equal(t, []string{"delta"}, second.Namespaces[0].Deployments)
+	})
+
+	t.Run("ExpiredToken", func(t *testing.T) {
+		token := encodeContinueToken(continueToken{Snapshot: "unknown", Offset: 1})
+		assert.Equal(t, 410, get(t, "/deployments/team-a?continue="+token, nil))
+	})
+
+	t.Run("MalformedToken", func(t *testing.T) {
+		assert.Equal(t, 400, get(t, "/deployments/team-a?continue=%25%25", nil))
+	})
+}
+
+func TestListFingerprint(t *testing.T) {
+	newCtx := func(uri string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		return ctx
+	}
+
+	// Paging parameters and their order do not matter
+	assert.Equal(t,
+		listFingerprint(newCtx("/deployments?limit=1&sortBy=name&order=desc")),
+		listFingerprint(newCtx("/deployments?order=desc&sortBy=name&continue=abc")))
+	assert.NotEqual(t,
+		listFingerprint(newCtx("/deployments?order=desc")),
+		listFingerprint(newCtx("/deployments?order=asc")))
+
+	// Continue tokens of one user cannot be used by another
+	alice, bob := newCtx("/deployments"), newCtx("/deployments")
+	alice.SetUserValue(userKey, &auth.User{Name: "alice"})
+	bob.SetUserValue(userKey, &auth.User{Name: "bob"})
+	assert.NotEqual(t, listFingerprint(alice), listFingerprint(bob))
+}
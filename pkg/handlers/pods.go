@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DeploymentPodsResponse is returned by GET /deployments/{namespace}/{name}/pods
+type DeploymentPodsResponse struct {
+	Namespace string       `json:"namespace"`
+	Name      string       `json:"name"`
+	Pods      []PodSummary `json:"pods"`
+	Count     int          `json:"count"`
+}
+
+// PodSummary describes a pod matching the selector of a deployment
+type PodSummary struct {
+	Name  string `json:"name"`
+	Phase string `json:"phase"`
+	// Ready is the pod's Ready condition; ReadyContainers of Containers are ready
+	Ready           bool       `json:"ready"`
+	ReadyContainers int        `json:"ready_containers"`
+	Containers      int        `json:"containers"`
+	Restarts        int32      `json:"restarts"`
+	Node            string     `json:"node,omitempty"`
+	StartTime       *time.Time `json:"start_time,omitempty"`
+	// ReplicaSet is the controller of the pod and Revision its rollout revision, 0 when the
+	// ReplicaSet does not belong to the deployment
+	ReplicaSet      string                `json:"replica_set,omitempty"`
+	Revision        int64                 `json:"revision,omitempty"`
+	LastTermination *ContainerTermination `json:"last_termination,omitempty"`
+}
+
+// ContainerTermination is the most recent failed exit of a container of a pod
+type ContainerTermination struct {
+	Container  string     `json:"container"`
+	Reason     string     `json:"reason,omitempty"`
+	ExitCode   int32      `json:"exit_code"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// handleGetDeploymentPods handles GET /deployments/{namespace}/{name}/pods - lists the pods selected by a deployment
+func (hm *HandlerManager) handleGetDeploymentPods(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	namespace, name := pathParam(ctx, "namespace"), pathParam(ctx, "name")
+	logger.Info().Str("namespace", namespace).Str("name", name).Msg("Deployment pods request received")
+
+	if !hm.checkAccess(ctx, verbGet, namespace, name, logger) {
+		return
+	}
+	if !hm.informerManager.HasInformer(namespace) {
+		hm.writeErrorResponse(ctx, CodeNamespaceNotWatched, "Namespace not being watched: "+namespace, 404, logger)
+		return
+	}
+	deployment, found := hm.informerManager.GetDeployment(namespace, name)
+	if !found {
+		hm.writeErrorResponse(ctx, CodeDeploymentNotFound, fmt.Sprintf("Deployment not found: %s/%s", namespace, name), 404, logger)
+		return
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid deployment selector")
+		hm.writeErrorResponse(ctx, CodeInternal, "Invalid deployment selector", 500, logger)
+		return
+	}
+
+	revisions := make(map[types.UID]int64)
+	for _, rs := range hm.informerManager.ListReplicaSets(deployment) {
+		revisions[rs.UID] = informer.Revision(rs)
+	}
+
+	pods := hm.informerManager.ListPods(namespace, selector)
+	response := DeploymentPodsResponse{
+		Namespace: namespace,
+		Name:      name,
+		Pods:      make([]PodSummary, 0, len(pods)),
+		Count:     len(pods),
+	}
+	for _, pod := range pods {
+		response.Pods = append(response.Pods, summarizePod(pod, revisions))
+	}
+
+	hm.writeResponse(ctx, response, 200, logger)
+}
+
+// summarizePod builds the PodSummary of a pod, revisions maps the deployment's ReplicaSet UIDs to their revision
+func summarizePod(pod *corev1.Pod, revisions map[types.UID]int64) PodSummary {
+	summary := PodSummary{
+		Name:       pod.Name,
+		Phase:      string(pod.Status.Phase),
+		Containers: len(pod.Spec.Containers),
+		Node:       pod.Spec.NodeName,
+	}
+	if pod.Status.StartTime != nil {
+		summary.StartTime = optionalTime(pod.Status.StartTime.UTC())
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			summary.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	if ref := metav1.GetControllerOf(pod); ref != nil && ref.Kind == "ReplicaSet" {
+		summary.ReplicaSet = ref.Name
+		summary.Revision = revisions[ref.UID]
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Ready {
+			summary.ReadyContainers++
+		}
+	}
+	for _, list := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range list {
+			summary.Restarts += status.RestartCount
+			for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				// Successful exits, like completed init containers, are not failures
+				if terminated == nil || terminated.ExitCode == 0 {
+					continue
+				}
+				if summary.LastTermination == nil || terminated.FinishedAt.After(derefTime(summary.LastTermination.FinishedAt)) {
+					summary.LastTermination = &ContainerTermination{
+						Container:  status.Name,
+						Reason:     terminated.Reason,
+						ExitCode:   terminated.ExitCode,
+						FinishedAt: optionalTime(terminated.FinishedAt.UTC()),
+					}
+				}
+			}
+		}
+	}
+	return summary
+}
+
+// derefTime returns the time t points to, or the zero time
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func (r DeploymentPodsResponse) tableHeader() []string {
+	return []string{"NAME", "READY", "PHASE", "RESTARTS", "NODE", "REVISION", "STARTED", "LAST-TERMINATION"}
+}
+
+func (r DeploymentPodsResponse) tableRows() [][]string {
+	rows := make([][]string, 0, len(r.Pods))
+	for _, pod := range r.Pods {
+		var revision, termination string
+		if pod.Revision != 0 {
+			revision = strconv.FormatInt(pod.Revision, 10)
+		}
+		if pod.LastTermination != nil {
+			termination = pod.LastTermination.Reason
+			if termination == "" {
+				termination = "exit code " + strconv.Itoa(int(pod.LastTermination.ExitCode))
+			}
+		}
+		rows = append(rows, []string{
+			pod.Name,
+			fmt.Sprintf("%d/%d", pod.ReadyContainers, pod.Containers),
+			pod.Phase,
+			strconv.Itoa(int(pod.Restarts)),
+			pod.Node,
+			revision,
+			formatOptionalTime(pod.StartTime),
+			termination,
+		})
+	}
+	return rows
+}
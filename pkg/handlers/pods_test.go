@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestPod builds a pod of the deployment's template controlled by the ReplicaSet, nil for a pod without one
+func newTestPod(d *appsv1.Deployment, rs *appsv1.ReplicaSet, name string, status corev1.PodStatus) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: d.Namespace, Labels: map[string]string{"app": d.Name}},
+		Spec:       corev1.PodSpec{Containers: d.Spec.Template.Spec.Containers},
+		Status:     status,
+	}
+	if rs != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(rs, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))}
+	}
+	return pod
+}
+
+// terminated returns a container state that exited with the given code and reason at finishedAt
+func terminated(exitCode int32, reason string, finishedAt time.Time) corev1.ContainerState {
+	return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+		ExitCode: exitCode, Reason: reason, FinishedAt: metav1.NewTime(finishedAt),
+	}}
+}
+
+func TestHandlerManager_DeploymentPods(t *testing.T) {
+	web := newTestDeployment("team-a", "web", 3)
+	web.UID = types.UID("web-uid")
+	old := newTestReplicaSet(web, "1", "aaa", web.Spec.Template)
+	old.UID = types.UID("web-aaa-uid")
+	current := newTestReplicaSet(web, "2", "bbb", web.Spec.Template)
+	current.UID = types.UID("web-bbb-uid")
+
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	running := newTestPod(web, current, "web-bbb-1", corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		StartTime:  &metav1.Time{Time: started},
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		InitContainerStatuses: []corev1.ContainerStatus{{
+			Name: "migrate", State: terminated(0, "Completed", started.Add(time.Minute)),
+		}},
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "nginx", Ready: true, RestartCount: 2,
+			LastTerminationState: terminated(137, "OOMKilled", started.Add(-time.Minute)),
+		}},
+	})
+	running.Spec.NodeName = "node-1"
+	crashing := newTestPod(web, current, "web-bbb-2", corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		StartTime:  &metav1.Time{Time: started},
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "nginx", RestartCount: 5,
+			State:                terminated(1, "Error", started.Add(2*time.Minute)),
+			LastTerminationState: terminated(137, "OOMKilled", started.Add(time.Minute)),
+		}},
+	})
+	pending := newTestPod(web, old, "web-aaa-1", corev1.PodStatus{Phase: corev1.PodPending})
+	stray := newTestPod(web, nil, "web-manual", corev1.PodStatus{Phase: corev1.PodRunning})
+	other := newTestPod(newTestDeployment("team-a", "api", 1), nil, "api-1", corev1.PodStatus{Phase: corev1.PodRunning})
+
+	clientset := fake.NewSimpleClientset(web, old, current, running, crashing, pending, stray, other)
+	handler := NewHandlerManager(startFakeInformers(t, clientset, []string{"team-a"}), "test-version").CreateHandler()
+
+	ctx := listRequest(handler, "/api/v1/deployments/team-a/web/pods")
+	require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+	var response DeploymentPodsResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+
+	oomKilledAt, erroredAt := started.Add(-time.Minute), started.Add(2*time.Minute)
+	assert.Equal(t, DeploymentPodsResponse{
+		Namespace: "team-a",
+		Name:      "web",
+		Count:     4,
+		Pods: []PodSummary{
+			{Name: "web-aaa-1", Phase: "Pending", Containers: 1, ReplicaSet: "web-aaa", Revision: 1},
+			{Name: "web-bbb-1", Phase: "Running", Ready: true, ReadyContainers: 1, Containers: 1, Restarts: 2, Node: "node-1",
+				StartTime: &started, ReplicaSet: "web-bbb", Revision: 2,
+				LastTermination: &ContainerTermination{Container: "nginx", Reason: "OOMKilled", ExitCode: 137, FinishedAt: &oomKilledAt}},
+			{Name: "web-bbb-2", Phase: "Running", Containers: 1, Restarts: 5, StartTime: &started, ReplicaSet: "web-bbb", Revision: 2,
+				LastTermination: &ContainerTermination{Container: "nginx", Reason: "Error", ExitCode: 1, FinishedAt: &erroredAt}},
+			{Name: "web-manual", Phase: "Running", Containers: 1},
+		},
+	}, response)
+
+	t.Run("table", func(t *testing.T) {
+		ctx := listRequest(handler, "/api/v1/deployments/team-a/web/pods?output=csv")
+		assert.Equal(t, "NAME,READY,PHASE,RESTARTS,NODE,REVISION,STARTED,LAST-TERMINATION\n"+
+			"web-aaa-1,0/1,Pending,0,,1,,\n"+
+			"web-bbb-1,1/1,Running,2,node-1,2,2024-05-01T12:00:00Z,OOMKilled\n"+
+			"web-bbb-2,0/1,Running,5,,2,2024-05-01T12:00:00Z,Error\n"+
+			"web-manual,0/1,Running,0,,,,\n", string(ctx.Response.Body()))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		ctx := listRequest(handler, "/api/v1/deployments/team-a/missing/pods")
+		assert.Equal(t, 404, ctx.Response.StatusCode())
+		assert.Contains(t, string(ctx.Response.Body()), CodeDeploymentNotFound)
+
+		ctx = listRequest(handler, "/api/v1/deployments/team-b/web/pods")
+		assert.Equal(t, 404, ctx.Response.StatusCode())
+		assert.Contains(t, string(ctx.Response.Body()), CodeNamespaceNotWatched)
+	})
+}
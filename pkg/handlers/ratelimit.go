@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitSweepInterval is how often clients whose bucket has refilled are forgotten
+const rateLimitSweepInterval = time.Minute
+
+// clientRateLimiter is a token bucket per client: each client may send burst requests at once and
+// limit requests per second after that
+type clientRateLimiter struct {
+	limit rate.Limit
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*clientBucket
+	lastSweep time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimitResult describes the bucket of a client after a request, for the X-RateLimit-* headers
+type rateLimitResult struct {
+	allowed   bool
+	remaining int
+	// retryAfter is how long until the next request is allowed, when it was not
+	retryAfter time.Duration
+	// reset is how long until the bucket is full again
+	reset time.Duration
+}
+
+// newClientRateLimiter returns nil when perSecond is not positive, meaning no limit. A burst below 1
+// defaults to one second worth of requests.
+func newClientRateLimiter(perSecond float64, burst int) *clientRateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(perSecond))
+	}
+	return &clientRateLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		now:     time.Now,
+		clients: make(map[string]*clientBucket),
+	}
+}
+
+// allow takes a token from the client's bucket if one is available
+func (l *clientRateLimiter) allow(client string) rateLimitResult {
+	return l.check(client, true)
+}
+
+// peek reports whether the client's bucket has a token, without taking it
+func (l *clientRateLimiter) peek(client string) rateLimitResult {
+	return l.check(client, false)
+}
+
+// check reports the state of the client's bucket, taking a token when take is true and one is available
+func (l *clientRateLimiter) check(client string, take bool) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &clientBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = bucket
+	}
+	bucket.lastSeen = now
+
+	var result rateLimitResult
+	if take {
+		result.allowed = bucket.limiter.AllowN(now, 1)
+	} else {
+		result.allowed = bucket.limiter.TokensAt(now) >= 1
+	}
+	tokens := bucket.limiter.TokensAt(now)
+	if !result.allowed {
+		result.retryAfter = l.refillTime(1 - tokens)
+	}
+	result.remaining = int(math.Max(0, math.Floor(tokens)))
+	result.reset = l.refillTime(float64(l.burst) - tokens)
+	return result
+}
+
+// refillTime returns how long it takes to add the tokens to a bucket
+func (l *clientRateLimiter) refillTime(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / float64(l.limit) * float64(time.Second))
+}
+
+// sweep forgets clients whose bucket is full again, they are the same as a new client
+func (l *clientRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	full := l.refillTime(float64(l.burst))
+	for client, bucket := range l.clients {
+		if now.Sub(bucket.lastSeen) > full {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// rateLimitClient identifies the client a request counts against: the authenticated user, or the remote IP
+func rateLimitClient(ctx *fasthttp.RequestCtx) string {
+	if user := requestUser(ctx); user != nil {
+		return "user:" + user.Name
+	}
+	return remoteIPClient(ctx)
+}
+
+// remoteIPClient identifies the client by the remote IP, failed authentications count against it
+func remoteIPClient(ctx *fasthttp.RequestCtx) string {
+	return "ip:" + ctx.RemoteIP().String()
+}
+
+// limiterFor returns the read or write limiter of the request method, nil when it is not limited
+func (hm *HandlerManager) limiterFor(method string) *clientRateLimiter {
+	if isWriteRequest(method) {
+		return hm.writeLimiter
+	}
+	return hm.readLimiter
+}
+
+// isWriteRequest reports whether the request is limited by the write rate instead of the read rate
+func isWriteRequest(method string) bool {
+	return method != fasthttp.MethodGet && method != fasthttp.MethodHead && method != fasthttp.MethodOptions
+}
+
+// checkRateLimit takes a token for the request's client from the read or write bucket and sets the
+// X-RateLimit-* headers. It writes a 429 response and returns false when the client is throttled.
+func (hm *HandlerManager) checkRateLimit(ctx *fasthttp.RequestCtx, method string, logger zerolog.Logger) bool {
+	limiter := hm.limiterFor(method)
+	if limiter == nil {
+		return true
+	}
+	client := rateLimitClient(ctx)
+	return hm.writeRateLimitResult(ctx, limiter, client, limiter.allow(client), logger)
+}
+
+// checkAuthFailureLimit throttles, before authentication, remote IPs that used up their bucket with
+// failed authentications, so a client trying tokens does not cost a TokenReview per attempt. It writes
+// a 429 response and returns false when the client is throttled.
+func (hm *HandlerManager) checkAuthFailureLimit(ctx *fasthttp.RequestCtx, method, path string, logger zerolog.Logger) bool {
+	limiter := hm.limiterFor(method)
+	if limiter == nil || !hm.authenticationEnabled() || hm.isPublicPath(path) {
+		return true
+	}
+	client := remoteIPClient(ctx)
+	result := limiter.peek(client)
+	if result.allowed {
+		return true
+	}
+	return hm.writeRateLimitResult(ctx, limiter, client, result, logger)
+}
+
+// chargeAuthFailure takes a token from the remote IP's bucket for a request that failed authentication
+func (hm *HandlerManager) chargeAuthFailure(ctx *fasthttp.RequestCtx, method string) {
+	if limiter := hm.limiterFor(method); limiter != nil {
+		limiter.allow(remoteIPClient(ctx))
+	}
+}
+
+// writeRateLimitResult sets the X-RateLimit-* headers of the client's bucket. It writes a 429 response
+// and returns false when the request was not allowed.
+func (hm *HandlerManager) writeRateLimitResult(ctx *fasthttp.RequestCtx, limiter *clientRateLimiter, client string, result rateLimitResult, logger zerolog.Logger) bool {
+	ctx.Response.Header.Set("X-RateLimit-Limit", strconv.Itoa(limiter.burst))
+	ctx.Response.Header.Set("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+	ctx.Response.Header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+	if result.allowed {
+		return true
+	}
+
+	logger.Warn().Str("client", client).Str("path", string(ctx.Path())).Msg("Request rate limited")
+	ctx.Response.Header.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.retryAfter))))
+	hm.writeErrorResponse(ctx, CodeRateLimited, "Too many requests, retry later", 429, logger)
+	return false
+}
+
+// acquireInFlight counts the request against MaxInFlight. It writes a 429 response and returns false
+// when the server is at the limit; otherwise the caller must call releaseInFlight.
+func (hm *HandlerManager) acquireInFlight(ctx *fasthttp.RequestCtx, logger zerolog.Logger) bool {
+	if hm.options.MaxInFlight <= 0 {
+		return true
+	}
+	if hm.inFlight.Add(1) > int64(hm.options.MaxInFlight) {
+		hm.inFlight.Add(-1)
+		logger.Warn().Int("max_in_flight", hm.options.MaxInFlight).Str("path", string(ctx.Path())).Msg("Too many requests in flight")
+		ctx.Response.Header.Set("Retry-After", "1")
+		hm.writeErrorResponse(ctx, CodeTooManyRequests, "The server is handling too many requests, retry later", 429, logger)
+		return false
+	}
+	return true
+}
+
+func (hm *HandlerManager) releaseInFlight() {
+	if hm.options.MaxInFlight > 0 {
+		hm.inFlight.Add(-1)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	assert.Nil(t, newClientRateLimiter(0, 10))
+
+	limiter := newClientRateLimiter(2, 3)
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	for want := 2; want >= 0; want-- {
+		result := limiter.allow("alice")
+		require.True(t, result.allowed)
+		assert.Equal(t, want, result.remaining)
+	}
+
+	result := limiter.allow("alice")
+	assert.False(t, result.allowed)
+	assert.Equal(t, 500*time.Millisecond, result.retryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.reset)
+
+	// Clients have their own bucket
+	assert.True(t, limiter.allow("bob").allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.allow("alice").allowed)
+
+	// Idle clients are forgotten once their bucket is full again
+	now = now.Add(rateLimitSweepInterval)
+	limiter.allow("carol")
+	assert.Len(t, limiter.clients, 1)
+
+	assert.Equal(t, 5, newClientRateLimiter(4.5, 0).burst)
+}
+
+// rateLimitRequest sends a request from the remote IP
+func rateLimitRequest(handler fasthttp.RequestHandler, method, path, remoteIP string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	req.SetRequestURI(path)
+	req.Header.SetMethod(method)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(remoteIP), Port: 40000}, nil)
+	handler(ctx)
+	return ctx
+}
+
+func TestHandlerManager_RateLimit(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ReadRateLimit = 0.001
+	opts.ReadRateBurst = 2
+	opts.WriteRateLimit = 0.001
+	opts.WriteRateBurst = 1
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, []string{"default"}), "test-version", opts).CreateHandler()
+
+	ctx := rateLimitRequest(handler, "GET", "/namespaces", "10.0.0.1")
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Equal(t, "2", string(ctx.Response.Header.Peek("X-RateLimit-Limit")))
+	assert.Equal(t, "1", string(ctx.Response.Header.Peek("X-RateLimit-Remaining")))
+	assert.NotEmpty(t, ctx.Response.Header.Peek("X-RateLimit-Reset"))
+
+	require.Equal(t, 200, rateLimitRequest(handler, "GET", "/namespaces", "10.0.0.1").Response.StatusCode())
+	ctx = rateLimitRequest(handler, "GET", "/deployments", "10.0.0.1")
+	require.Equal(t, 429, ctx.Response.StatusCode())
+	assert.Equal(t, "0", string(ctx.Response.Header.Peek("X-RateLimit-Remaining")))
+	assert.NotEmpty(t, ctx.Response.Header.Peek("Retry-After"))
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, CodeRateLimited, response.Code)
+
+	// Writes have their own bucket, other clients and probes are not affected
+	assert.Equal(t, 403, rateLimitRequest(handler, "POST", "/deployments/default/web/restart", "10.0.0.1").Response.StatusCode())
+	assert.Equal(t, 429, rateLimitRequest(handler, "POST", "/deployments/default/web/restart", "10.0.0.1").Response.StatusCode())
+	assert.Equal(t, 200, rateLimitRequest(handler, "GET", "/namespaces", "10.0.0.2").Response.StatusCode())
+	ctx = rateLimitRequest(handler, "GET", "/livez", "10.0.0.1")
+	assert.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek("X-RateLimit-Limit"))
+}
+
+func TestHandlerManager_RateLimitByUser(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Authenticator = &fakeAuthenticator{}
+	opts.ReadRateLimit = 0.001
+	opts.ReadRateBurst = 1
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, []string{"default"}), "test-version", opts).CreateHandler()
+
+	send := func(remoteIP string) int {
+		var req fasthttp.Request
+		req.SetRequestURI("/namespaces")
+		req.Header.Set("Authorization", "Bearer good")
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(remoteIP)}, nil)
+		handler(ctx)
+		return ctx.Response.StatusCode()
+	}
+	// The authenticated user is limited across addresses
+	assert.Equal(t, 200, send("10.0.0.1"))
+	assert.Equal(t, 429, send("10.0.0.2"))
+}
+
+func TestHandlerManager_RateLimitFailedAuthentication(t *testing.T) {
+	authenticator := &fakeAuthenticator{}
+	opts := DefaultOptions()
+	opts.Authenticator = authenticator
+	opts.ReadRateLimit = 0.001
+	opts.ReadRateBurst = 3
+	handler := NewHandlerManagerWithOptions(newFakeInformerManager(t, []string{"default"}), "test-version", opts).CreateHandler()
+
+	send := func(remoteIP, token string) int {
+		var req fasthttp.Request
+		req.SetRequestURI("/namespaces")
+		req.Header.Set("Authorization", "Bearer "+token)
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(remoteIP)}, nil)
+		handler(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	// Every bad token costs a review until the address has used up its bucket
+	for i := 0; i < 3; i++ {
+		require.Equal(t, 401, send("10.0.0.1", fmt.Sprintf("bad-%d", i)))
+	}
+	assert.Equal(t, 429, send("10.0.0.1", "bad-3"))
+	assert.Equal(t, int32(3), authenticator.calls.Load())
+
+	// Valid tokens from the address are throttled too, other addresses and users are not affected
+	assert.Equal(t, 429, send("10.0.0.1", "good"))
+	assert.Equal(t, 401, send("10.0.0.2", "bad"))
+	assert.Equal(t, 200, send("10.0.0.2", "good"))
+}
+
+func TestHandlerManager_MaxInFlight(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxInFlight = 1
+	hm := NewHandlerManagerWithOptions(newFakeInformerManager(t, []string{"default"}), "test-version", opts)
+	handler := hm.CreateHandler()
+
+	require.Equal(t, 200, rateLimitRequest(handler, "GET", "/namespaces", "10.0.0.1").Response.StatusCode())
+	assert.Zero(t, hm.inFlight.Load())
+
+	// A request is already being handled
+	hm.inFlight.Store(1)
+	ctx := rateLimitRequest(handler, "GET", "/namespaces", "10.0.0.1")
+	require.Equal(t, 429, ctx.Response.StatusCode())
+	assert.Equal(t, "1", string(ctx.Response.Header.Peek("Retry-After")))
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, CodeTooManyRequests, response.Code)
+	assert.Equal(t, int64(1), hm.inFlight.Load())
+
+	assert.Equal(t, 200, rateLimitRequest(handler, "GET", "/readyz", "10.0.0.1").Response.StatusCode())
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// changeCauseAnnotation records why a revision was created
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+	// podTemplateHashLabel is added to pod templates by the Deployment controller
+	podTemplateHashLabel = "pod-template-hash"
+)
+
+// rollbackAnnotationsToSkip are the Deployment annotations a rollback keeps instead of copying them from
+// the ReplicaSet, matching kubectl rollout undo
+var rollbackAnnotationsToSkip = map[string]bool{
+	corev1.LastAppliedConfigAnnotation:          true,
+	informer.RevisionAnnotation:                 true,
+	"deployment.kubernetes.io/revision-history": true,
+	"deployment.kubernetes.io/desired-replicas": true,
+	"deployment.kubernetes.io/max-replicas":     true,
+	"deprecated.deployment.rollback.to":         true,
+}
+
+// DeploymentRevisionsResponse is returned by GET /deployments/{namespace}/{name}/revisions
+type DeploymentRevisionsResponse struct {
+	Namespace       string            `json:"namespace"`
+	Name            string            `json:"name"`
+	CurrentRevision int64             `json:"current_revision"`
+	Revisions       []RevisionSummary `json:"revisions"`
+	Count           int               `json:"count"`
+}
+
+// RevisionSummary describes a revision of a deployment, backed by one of its ReplicaSets
+type RevisionSummary struct {
+	Revision      int64         `json:"revision"`
+	ReplicaSet    string        `json:"replica_set"`
+	Current       bool          `json:"current"`
+	CreatedAt     time.Time     `json:"created_at"`
+	Replicas      int32         `json:"replicas"`
+	ReadyReplicas int32         `json:"ready_replicas"`
+	ChangeCause   string        `json:"change_cause,omitempty"`
+	Images        []string      `json:"images"`
+	Changes       *RevisionDiff `json:"changes,omitempty"`
+}
+
+// RevisionDiff lists the image and env changes of a revision compared with the previous one
+type RevisionDiff struct {
+	Images []ImageChange `json:"images,omitempty"`
+	Env    []EnvChange   `json:"env,omitempty"`
+}
+
+// ImageChange is a container image that differs between two revisions
+type ImageChange struct {
+	Container string `json:"container"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+}
+
+// EnvChange is a container env var that was added, removed or changed between two revisions
+type EnvChange struct {
+	Container string `json:"container"`
+	Name      string `json:"name"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+}
+
+// RollbackResponse is returned by POST /deployments/{namespace}/{name}/rollback
+type RollbackResponse struct {
+	Action     string            `json:"action"`
+	DryRun     bool              `json:"dry_run"`
+	Revision   int64             `json:"revision"`
+	Skipped    bool              `json:"skipped"`
+	Message    string            `json:"message,omitempty"`
+	Deployment DeploymentSummary `json:"deployment"`
+}
+
+// handleGetDeploymentRevisions handles GET /deployments/{namespace}/{name}/revisions - lists the rollout history
+func (hm *HandlerManager) handleGetDeploymentRevisions(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	namespace, name := pathParam(ctx, "namespace"), pathParam(ctx, "name")
+	logger.Info().Str("namespace", namespace).Str("name", name).Msg("Deployment revisions request received")
+
+	if !hm.checkAccess(ctx, verbGet, namespace, name, logger) {
+		return
+	}
+	if !hm.informerManager.HasInformer(namespace) {
+		hm.writeErrorResponse(ctx, CodeNamespaceNotWatched, "Namespace not being watched: "+namespace, 404, logger)
+		return
+	}
+	deployment, found := hm.informerManager.GetDeployment(namespace, name)
+	if !found {
+		hm.writeErrorResponse(ctx, CodeDeploymentNotFound, fmt.Sprintf("Deployment not found: %s/%s", namespace, name), 404, logger)
+		return
+	}
+
+	response := DeploymentRevisionsResponse{
+		Namespace: namespace,
+		Name:      name,
+		Revisions: []RevisionSummary{},
+	}
+
+	var previous *appsv1.ReplicaSet
+	for _, rs := range hm.informerManager.ListReplicaSets(deployment) {
+		revision := informer.Revision(rs)
+		if revision == 0 {
+			continue
+		}
+
+		summary := RevisionSummary{
+			Revision:      revision,
+			ReplicaSet:    rs.Name,
+			Current:       templatesEqualIgnoringHash(&rs.Spec.Template, &deployment.Spec.Template),
+			CreatedAt:     rs.CreationTimestamp.UTC(),
+			Replicas:      rs.Status.Replicas,
+			ReadyReplicas: rs.Status.ReadyReplicas,
+			ChangeCause:   rs.Annotations[changeCauseAnnotation],
+			Images:        make([]string, 0, len(rs.Spec.Template.Spec.Containers)),
+		}
+		for _, c := range rs.Spec.Template.Spec.Containers {
+			summary.Images = append(summary.Images, c.Image)
+		}
+		if previous != nil {
+			summary.Changes = hm.diffPodTemplates(&previous.Spec.Template, &rs.Spec.Template)
+		}
+		if summary.Current {
+			response.CurrentRevision = revision
+		}
+
+		response.Revisions = append(response.Revisions, summary)
+		previous = rs
+	}
+	response.Count = len(response.Revisions)
+
+	hm.writeResponse(ctx, response, 200, logger)
+}
+
+// rollbackDeployment restores the pod template of an earlier revision, the same way kubectl rollout undo does.
+// The revision query parameter selects the revision; 0 or no value selects the previous one.
+func (hm *HandlerManager) rollbackDeployment(ctx *fasthttp.RequestCtx, deployment *appsv1.Deployment, dryRun bool, logger zerolog.Logger) {
+	var toRevision int64
+	if raw := string(ctx.QueryArgs().Peek("revision")); raw != "" {
+		var err error
+		if toRevision, err = strconv.ParseInt(raw, 10, 64); err != nil || toRevision < 0 {
+			hm.writeParameterErrorResponse(ctx, &parameterError{Parameter: "revision", Err: fmt.Errorf("must be a non-negative integer")}, logger)
+			return
+		}
+	}
+
+	if deployment.Spec.Paused {
+		hm.writeErrorResponse(ctx, CodeDeploymentPaused, "Cannot roll back a paused deployment, resume it first", 409, logger)
+		return
+	}
+
+	rs, err := replicaSetForRevision(hm.informerManager.ListReplicaSets(deployment), toRevision)
+	if err != nil {
+		hm.writeErrorResponse(ctx, CodeRevisionNotFound, err.Error(), 404, logger)
+		return
+	}
+	revision := informer.Revision(rs)
+	logger = logger.With().Int64("revision", revision).Logger()
+
+	if templatesEqualIgnoringHash(&rs.Spec.Template, &deployment.Spec.Template) {
+		logger.Info().Msg("Rollback skipped, template already matches revision")
+		hm.writeJSONResponse(ctx, RollbackResponse{
+			Action:     actionRollback,
+			DryRun:     dryRun,
+			Revision:   revision,
+			Skipped:    true,
+			Message:    fmt.Sprintf("current template already matches revision %d", revision),
+			Deployment: summarizeDeployment(deployment),
+		}, 200, logger)
+		return
+	}
+
+	patch, err := buildRollbackPatch(deployment, rs)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to build rollback patch")
+		hm.writeErrorResponse(ctx, CodeInternal, "Failed to build rollback patch", 500, logger)
+		return
+	}
+
+	updated, ok := hm.patchDeployment(ctx, deployment, types.JSONPatchType, patch, dryRun, logger)
+	if !ok {
+		return
+	}
+
+	hm.writeJSONResponse(ctx, RollbackResponse{
+		Action:     actionRollback,
+		DryRun:     dryRun,
+		Revision:   revision,
+		Deployment: summarizeDeployment(updated),
+	}, 200, logger)
+}
+
+// replicaSetForRevision returns the ReplicaSet of the given revision, or of the previous revision when toRevision is 0
+func replicaSetForRevision(replicaSets []*appsv1.ReplicaSet, toRevision int64) (*appsv1.ReplicaSet, error) {
+	if toRevision > 0 {
+		for _, rs := range replicaSets {
+			if informer.Revision(rs) == toRevision {
+				return rs, nil
+			}
+		}
+		return nil, fmt.Errorf("unable to find revision %d in rollout history", toRevision)
+	}
+
+	var latest, previous *appsv1.ReplicaSet
+	var maxRevision, previousRevision int64
+	for _, rs := range replicaSets {
+		switch v := informer.Revision(rs); {
+		case v > maxRevision:
+			previous, previousRevision = latest, maxRevision
+			latest, maxRevision = rs, v
+		case v > previousRevision:
+			previous, previousRevision = rs, v
+		}
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("no previous revision found in rollout history")
+	}
+	return previous, nil
+}
+
+// buildRollbackPatch returns a JSON patch that copies the ReplicaSet's pod template and annotations into the deployment
+func buildRollbackPatch(deployment *appsv1.Deployment, rs *appsv1.ReplicaSet) ([]byte, error) {
+	template := rs.Spec.Template.DeepCopy()
+	delete(template.Labels, podTemplateHashLabel)
+
+	annotations := map[string]string{}
+	for k := range rollbackAnnotationsToSkip {
+		if v, ok := deployment.Annotations[k]; ok {
+			annotations[k] = v
+		}
+	}
+	for k, v := range rs.Annotations {
+		if !rollbackAnnotationsToSkip[k] {
+			annotations[k] = v
+		}
+	}
+
+	return json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/spec/template", "value": template},
+		{"op": "replace", "path": "/metadata/annotations", "value": annotations},
+	})
+}
+
+// templatesEqualIgnoringHash compares two pod templates, ignoring the pod-template-hash label
+func templatesEqualIgnoringHash(a, b *corev1.PodTemplateSpec) bool {
+	a, b = a.DeepCopy(), b.DeepCopy()
+	delete(a.Labels, podTemplateHashLabel)
+	delete(b.Labels, podTemplateHashLabel)
+	return apiequality.Semantic.DeepEqual(a, b)
+}
+
+// diffPodTemplates lists the image and env changes between two pod templates, applying redaction options
+func (hm *HandlerManager) diffPodTemplates(from, to *corev1.PodTemplateSpec) *RevisionDiff {
+	fromContainers := podContainers(from)
+	toContainers := podContainers(to)
+	diff := &RevisionDiff{}
+
+	names := make([]string, 0, len(fromContainers)+len(toContainers))
+	seen := make(map[string]bool)
+	for _, list := range [][]corev1.Container{from.Spec.InitContainers, from.Spec.Containers, to.Spec.InitContainers, to.Spec.Containers} {
+		for _, c := range list {
+			if !seen[c.Name] {
+				seen[c.Name] = true
+				names = append(names, c.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		oldC, newC := fromContainers[name], toContainers[name]
+		if oldC.Image != newC.Image {
+			diff.Images = append(diff.Images, ImageChange{Container: name, From: oldC.Image, To: newC.Image})
+		}
+		diff.Env = append(diff.Env, hm.diffEnv(name, oldC.Env, newC.Env)...)
+	}
+
+	if len(diff.Images) == 0 && len(diff.Env) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// diffEnv lists the env vars of a container that differ between two revisions
+func (hm *HandlerManager) diffEnv(container string, from, to []corev1.EnvVar) []EnvChange {
+	fromVars := make(map[string]corev1.EnvVar, len(from))
+	for _, env := range from {
+		fromVars[env.Name] = env
+	}
+
+	var changes []EnvChange
+	for _, env := range to {
+		old, existed := fromVars[env.Name]
+		delete(fromVars, env.Name)
+		if existed && apiequality.Semantic.DeepEqual(old, env) {
+			continue
+		}
+		change := EnvChange{Container: container, Name: env.Name, To: hm.displayEnvValue(env)}
+		if existed {
+			change.From = hm.displayEnvValue(old)
+		}
+		changes = append(changes, change)
+	}
+	// Whatever is left was removed; keep the order of the old container
+	for _, env := range from {
+		if _, removed := fromVars[env.Name]; removed {
+			changes = append(changes, EnvChange{Container: container, Name: env.Name, From: hm.displayEnvValue(env)})
+		}
+	}
+	return changes
+}
+
+// displayEnvValue renders an env var value for API responses, applying redaction options
+func (hm *HandlerManager) displayEnvValue(env corev1.EnvVar) string {
+	switch {
+	case env.ValueFrom != nil:
+		return hm.describeEnvSource(env.ValueFrom)
+	case hm.options.RedactEnvValues && env.Value != "":
+		return redactedValue
+	default:
+		return env.Value
+	}
+}
+
+// podContainers indexes the init and regular containers of a pod template by name
+func podContainers(template *corev1.PodTemplateSpec) map[string]corev1.Container {
+	containers := make(map[string]corev1.Container, len(template.Spec.InitContainers)+len(template.Spec.Containers))
+	for _, list := range [][]corev1.Container{template.Spec.InitContainers, template.Spec.Containers} {
+		for _, c := range list {
+			containers[c.Name] = c
+		}
+	}
+	return containers
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestReplicaSet builds a ReplicaSet owned by the deployment with the given revision and pod template
+func newTestReplicaSet(d *appsv1.Deployment, revision, hash string, template corev1.PodTemplateSpec) *appsv1.ReplicaSet {
+	template = *template.DeepCopy()
+	template.Labels = map[string]string{"app": d.Name, podTemplateHashLabel: hash}
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              d.Name + "-" + hash,
+			Namespace:         d.Namespace,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Annotations: map[string]string{
+				"deployment.kubernetes.io/revision": revision,
+				changeCauseAnnotation:               "deploy " + revision,
+			},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: template},
+	}
+}
+
+// newRevisionTestHandler returns a handler for a deployment at revision 3 whose history changed image and env
+func newRevisionTestHandler(t *testing.T, mutate func(*appsv1.Deployment)) (fasthttp.RequestHandler, *fake.Clientset) {
+	t.Helper()
+	web := newTestDeployment("team-a", "web", 2)
+	web.UID = types.UID("web-uid")
+	web.Annotations = map[string]string{"deployment.kubernetes.io/revision": "3"}
+
+	v1 := *web.Spec.Template.DeepCopy()
+	v1.Spec.Containers[0].Image = "nginx:1.19"
+	v1.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "MODE", Value: "slow"}, {Name: "OLD", Value: "x"}}
+
+	v2 := *v1.DeepCopy()
+	v2.Spec.Containers[0].Image = "nginx:1.20"
+	v2.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "MODE", Value: "fast"}}
+
+	web.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "MODE", Value: "fast"}}
+	if mutate != nil {
+		mutate(web)
+	}
+
+	clientset := fake.NewSimpleClientset(web,
+		newTestReplicaSet(web, "1", "aaa", v1),
+		newTestReplicaSet(web, "2", "bbb", v2),
+		newTestReplicaSet(web, "3", "ccc", web.Spec.Template),
+	)
+	informerManager := startFakeInformers(t, clientset, []string{"team-a"})
+
+	opts := DefaultOptions()
+	opts.EnableMutations = true
+	opts.Clientset = clientset
+	return NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler(), clientset
+}
+
+func TestHandlerManager_DeploymentRevisions(t *testing.T) {
+	handler, _ := newRevisionTestHandler(t, nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/deployments/team-a/web/revisions")
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+
+	require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+
+	var response DeploymentRevisionsResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, int64(3), response.CurrentRevision)
+	require.Equal(t, 3, response.Count)
+
+	first, second, third := response.Revisions[0], response.Revisions[1], response.Revisions[2]
+	assert.Equal(t, int64(1), first.Revision)
+	assert.Equal(t, "web-aaa", first.ReplicaSet)
+	assert.Equal(t, "deploy 1", first.ChangeCause)
+	assert.Nil(t, first.Changes)
+
+	require.NotNil(t, second.Changes)
+	assert.Equal(t, []ImageChange{{Container: "nginx", From: "nginx:1.19", To: "nginx:1.20"}}, second.Changes.Images)
+	// Env values are redacted by default, but the change is still reported
+	assert.Equal(t, []EnvChange{
+		{Container: "nginx", Name: "MODE", From: redactedValue, To: redactedValue},
+		{Container: "nginx", Name: "OLD", From: redactedValue},
+	}, second.Changes.Env)
+	assert.False(t, second.Current)
+
+	require.NotNil(t, third.Changes)
+	assert.Equal(t, []ImageChange{{Container: "nginx", From: "nginx:1.20", To: "nginx:1.21"}}, third.Changes.Images)
+	assert.Empty(t, third.Changes.Env)
+	assert.True(t, third.Current)
+}
+
+func TestHandlerManager_DeploymentRollback(t *testing.T) {
+	t.Run("PreviousRevision", func(t *testing.T) {
+		handler, clientset := newRevisionTestHandler(t, nil)
+
+		ctx := postAction(handler, "/deployments/team-a/web/rollback", "")
+		require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+
+		var response RollbackResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, int64(2), response.Revision)
+		assert.False(t, response.Skipped)
+		assert.Equal(t, []string{"nginx:1.20"}, response.Deployment.Images)
+
+		d, err := clientset.AppsV1().Deployments("team-a").Get(t.Context(), "web", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.NotContains(t, d.Spec.Template.Labels, podTemplateHashLabel)
+		assert.Equal(t, "deploy 2", d.Annotations[changeCauseAnnotation])
+		assert.Equal(t, "3", d.Annotations["deployment.kubernetes.io/revision"])
+	})
+
+	t.Run("SpecificRevision", func(t *testing.T) {
+		handler, _ := newRevisionTestHandler(t, nil)
+
+		ctx := postAction(handler, "/deployments/team-a/web/rollback?revision=1&dryRun=true", "")
+		require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+
+		var response RollbackResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, int64(1), response.Revision)
+		assert.True(t, response.DryRun)
+	})
+
+	t.Run("CurrentRevisionIsSkipped", func(t *testing.T) {
+		handler, clientset := newRevisionTestHandler(t, nil)
+		clientset.ClearActions()
+
+		ctx := postAction(handler, "/deployments/team-a/web/rollback?revision=3", "")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+
+		var response RollbackResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.True(t, response.Skipped)
+		for _, action := range clientset.Actions() {
+			assert.NotEqual(t, "patch", action.GetVerb())
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		handler, _ := newRevisionTestHandler(t, nil)
+		assert.Equal(t, 404, postAction(handler, "/deployments/team-a/web/rollback?revision=9", "").Response.StatusCode())
+		assert.Equal(t, 400, postAction(handler, "/deployments/team-a/web/rollback?revision=-1", "").Response.StatusCode())
+
+		paused, _ := newRevisionTestHandler(t, func(d *appsv1.Deployment) { d.Spec.Paused = true })
+		assert.Equal(t, 409, postAction(paused, "/deployments/team-a/web/rollback", "").Response.StatusCode())
+	})
+}
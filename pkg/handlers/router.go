@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+// APIPrefix is the versioned prefix all routes are mounted under. The unprefixed paths are kept as aliases.
+const APIPrefix = "/api/v1"
+
+// routeHandler serves a matched route. Path parameters are available through pathParam.
+type routeHandler func(ctx *fasthttp.RequestCtx, logger zerolog.Logger)
+
+// route maps a method and a path pattern to a handler. Pattern segments in braces, e.g. {namespace},
+// match any non-empty path segment and are stored as user values of the request.
+type route struct {
+	method  string
+	pattern string
+	handler routeHandler
+	// quiet routes, such as probes, only log warnings and errors
+	quiet bool
+
+	// Documentation used to build the OpenAPI document
+	operationID string
+	summary     string
+	query       []queryParam
+	// request and response are values of the Go types of the JSON request and response bodies
+	request  interface{}
+	response interface{}
+	// contentType of the successful response, application/json when empty
+	contentType string
+	// status of the successful response, 200 when zero
+	status int
+	// messages are the Go types of stream messages, published as component schemas
+	messages []interface{}
+	// conditional responses carry an ETag and are answered with 304 when If-None-Match names it
+	conditional bool
+
+	segments []string
+}
+
+// router dispatches requests to the first route whose pattern and method match
+type router struct {
+	routes []route
+}
+
+// newRouter creates a router for the given routes, matched in order
+func newRouter(routes []route) *router {
+	for i := range routes {
+		routes[i].segments = splitPath(routes[i].pattern)
+	}
+	return &router{routes: routes}
+}
+
+// routes returns the route table of the API, relative to APIPrefix
+func (hm *HandlerManager) routes() []route {
+	action := func(action string) routeHandler {
+		return func(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+			hm.handleDeploymentAction(ctx, action, logger)
+		}
+	}
+
+	return []route{
+		{method: "GET", pattern: "/", handler: hm.handleRoot,
+			operationID: "getRoot", summary: "API version and endpoints", response: RootResponse{}},
+		{method: "GET", pattern: "/openapi.json", handler: hm.handleOpenAPI,
+			operationID: "getOpenAPI", summary: "This OpenAPI document"},
+		{method: "GET", pattern: "/healthz", handler: hm.handleHealthz, quiet: true,
+			operationID: "getHealthz", summary: "Health checks", query: probeParams, response: "", contentType: "text/plain"},
+		{method: "GET", pattern: "/readyz", handler: hm.handleReadyz, quiet: true,
+			operationID: "getReadyz", summary: "Readiness checks", query: probeParams, response: "", contentType: "text/plain"},
+		{method: "GET", pattern: "/livez", handler: hm.handleLivez, quiet: true,
+			operationID: "getLivez", summary: "Liveness checks", query: probeParams, response: "", contentType: "text/plain"},
+		{method: "GET", pattern: "/namespaces", handler: hm.handleGetNamespaces,
+			operationID: "listNamespaces", summary: "List watched namespaces, with their informer state when status=true",
+			query: namespaceParams, response: NamespaceResponse{}, conditional: true},
+		{method: "POST", pattern: "/namespaces/{namespace}/watch", handler: hm.handleWatchNamespace,
+			operationID: "watchNamespace", summary: "Start watching a namespace (admin)", response: NamespaceWatchResponse{}},
+		{method: "DELETE", pattern: "/namespaces/{namespace}/watch", handler: hm.handleUnwatchNamespace,
+			operationID: "unwatchNamespace", summary: "Stop watching a namespace (admin)", response: NamespaceWatchResponse{}},
+		{method: "GET", pattern: "/deployments", handler: hm.handleGetDeployments,
+			operationID: "listDeployments", summary: "List deployments in all watched namespaces",
+			query: deploymentListParams, response: DeploymentsAllResponse{}, conditional: true},
+		{method: "GET", pattern: "/summary", handler: hm.handleGetSummary,
+			operationID: "getSummary", summary: "Deployment health counts per namespace and overall", query: summaryParams, response: SummaryResponse{}},
+		{method: "GET", pattern: "/deployments/{namespace}", handler: hm.handleGetDeploymentsByNamespace,
+			operationID: "listNamespacedDeployments", summary: "List deployments in a namespace",
+			query: deploymentListParams, response: DeploymentResponse{}, conditional: true},
+		{method: "GET", pattern: "/deployments/{namespace}/{name}", handler: hm.handleGetDeploymentDetail,
+			operationID: "getDeployment", summary: "Get a cached deployment", response: DeploymentDetailResponse{}},
+		{method: "GET", pattern: "/deployments/{namespace}/{name}/revisions", handler: hm.handleGetDeploymentRevisions,
+			operationID: "listDeploymentRevisions", summary: "Rollout history of a deployment", response: DeploymentRevisionsResponse{}},
+		{method: "GET", pattern: "/deployments/{namespace}/{name}/pods", handler: hm.handleGetDeploymentPods,
+			operationID: "listDeploymentPods", summary: "Pods selected by a deployment", response: DeploymentPodsResponse{}},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/scale", handler: action(actionScale),
+			operationID: "scaleDeployment", summary: "Set the replica count", query: dryRunParams, request: ScaleRequest{}, response: DeploymentActionResponse{}},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/restart", handler: action(actionRestart),
+			operationID: "restartDeployment", summary: "Restart the pods like kubectl rollout restart", query: dryRunParams, response: DeploymentActionResponse{}},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/pause", handler: action(actionPause),
+			operationID: "pauseDeployment", summary: "Pause the rollout", query: dryRunParams, response: DeploymentActionResponse{}},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/resume", handler: action(actionResume),
+			operationID: "resumeDeployment", summary: "Resume the rollout", query: dryRunParams, response: DeploymentActionResponse{}},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/image", handler: action(actionImage),
+			operationID: "setDeploymentImage", summary: "Set container images", query: dryRunParams, request: SetImageRequest{}, response: DeploymentActionResponse{}},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/rollback", handler: action(actionRollback),
+			operationID: "rollbackDeployment", summary: "Roll back to an earlier revision",
+			query: rollbackParams, response: RollbackResponse{}},
+		{method: "GET", pattern: "/events/deployments", handler: hm.handleDeploymentEvents,
+			operationID: "streamDeploymentEvents", summary: "Server-Sent Events stream of deployment changes; each data field is a DeploymentEventResponse",
+			query: eventParams, response: DeploymentEventResponse{}, contentType: "text/event-stream", messages: []interface{}{StreamErrorResponse{}}},
+		{method: "GET", pattern: "/watch/deployments", handler: hm.handleDeploymentWatch,
+			operationID: "watchDeployments", summary: "WebSocket watch of deployment changes, see WatchRequest and WatchEvent",
+			status: fasthttp.StatusSwitchingProtocols, messages: []interface{}{WatchRequest{}, WatchEvent{}}},
+	}
+}
+
+// match finds the route for the request path and method. When no route has the method, allowed lists
+// the methods of the routes matching the path, so the caller can tell a 405 from a 404.
+func (r *router) match(ctx *fasthttp.RequestCtx, path, method string) (matched *route, allowed []string) {
+	if path == APIPrefix || strings.HasPrefix(path, APIPrefix+"/") {
+		path = strings.TrimPrefix(path, APIPrefix)
+	}
+	segments := splitPath(path)
+
+	for i := range r.routes {
+		rt := &r.routes[i]
+		params, ok := matchSegments(rt.segments, segments)
+		if !ok {
+			continue
+		}
+		if rt.method != method {
+			allowed = appendUnique(allowed, rt.method)
+			continue
+		}
+		for name, value := range params {
+			ctx.SetUserValue(name, value)
+		}
+		return rt, nil
+	}
+
+	sort.Strings(allowed)
+	return nil, allowed
+}
+
+// matchSegments matches path segments against pattern segments and returns the path parameters
+func matchSegments(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, p := range pattern {
+		if name, ok := patternParam(p); ok {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// splitPath splits a path into its segments; "/" and "" have a single empty segment
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// pathParam returns a path parameter of the matched route, or "" if it is not set
+func pathParam(ctx *fasthttp.RequestCtx, name string) string {
+	value, _ := ctx.UserValue(name).(string)
+	return value
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
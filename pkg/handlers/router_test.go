@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouter_Match(t *testing.T) {
+	noop := func(*fasthttp.RequestCtx, zerolog.Logger) {}
+	r := newRouter([]route{
+		{method: "GET", pattern: "/", handler: noop},
+		{method: "GET", pattern: "/deployments", handler: noop},
+		{method: "GET", pattern: "/deployments/{namespace}/{name}", handler: noop},
+		{method: "GET", pattern: "/deployments/{namespace}/{name}/revisions", handler: noop},
+		{method: "POST", pattern: "/deployments/{namespace}/{name}/scale", handler: noop},
+	})
+
+	tests := []struct {
+		method      string
+		path        string
+		wantPattern string
+		wantAllowed []string
+		wantParams  map[string]string
+	}{
+		{method: "GET", path: "/", wantPattern: "/"},
+		{method: "GET", path: "/api/v1", wantPattern: "/"},
+		{method: "GET", path: "/api/v1/", wantPattern: "/"},
+		{method: "GET", path: "/deployments", wantPattern: "/deployments"},
+		{method: "GET", path: "/api/v1/deployments", wantPattern: "/deployments"},
+		{method: "GET", path: "/deployments/team-a/api", wantPattern: "/deployments/{namespace}/{name}",
+			wantParams: map[string]string{"namespace": "team-a", "name": "api"}},
+		{method: "GET", path: "/api/v1/deployments/team-a/api/revisions", wantPattern: "/deployments/{namespace}/{name}/revisions",
+			wantParams: map[string]string{"namespace": "team-a", "name": "api"}},
+		{method: "POST", path: "/deployments/team-a/api/scale", wantPattern: "/deployments/{namespace}/{name}/scale"},
+		{method: "GET", path: "/deployments/team-a/api/scale", wantAllowed: []string{"POST"}},
+		{method: "DELETE", path: "/deployments/team-a/api", wantAllowed: []string{"GET"}},
+		{method: "GET", path: "/deployments/team-a/", wantAllowed: nil},
+		{method: "GET", path: "/deployments/team-a/api/extra", wantAllowed: nil},
+		{method: "GET", path: "/api/v2/deployments", wantAllowed: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			matched, allowed := r.match(ctx, tt.path, tt.method)
+			if tt.wantPattern == "" {
+				assert.Nil(t, matched)
+				assert.Equal(t, tt.wantAllowed, allowed)
+				return
+			}
+			require.NotNil(t, matched)
+			assert.Equal(t, tt.wantPattern, matched.pattern)
+			for name, value := range tt.wantParams {
+				assert.Equal(t, value, pathParam(ctx, name))
+			}
+		})
+	}
+}
+
+func TestHandlerManager_MethodNotAllowed(t *testing.T) {
+	handler := NewHandlerManager(newFakeInformerManager(t, nil), "test-version").CreateHandler()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/v1/deployments/team-a/api/revisions")
+	ctx.Request.Header.SetMethod("POST")
+	handler(ctx)
+
+	assert.Equal(t, 405, ctx.Response.StatusCode())
+	assert.Equal(t, "GET", string(ctx.Response.Header.Peek("Allow")))
+	assert.Equal(t, "application/problem+json", string(ctx.Response.Header.ContentType()))
+
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, CodeMethodNotAllowed, response.Code)
+	assert.Equal(t, "Method Not Allowed", response.Title)
+	assert.Equal(t, 405, response.Status)
+	assert.Equal(t, "/api/v1/deployments/team-a/api/revisions", response.Instance)
+}
+
+func TestHandlerManager_VersionedAliases(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a"}, newTestDeployment("team-a", "api", 1))
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+
+	get := func(uri string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+		return ctx
+	}
+
+	for _, path := range []string{"/deployments/team-a/api", "/namespaces", "/readyz"} {
+		legacy, versioned := get(path), get(APIPrefix+path)
+		assert.Equal(t, 200, legacy.Response.StatusCode(), path)
+		assert.Equal(t, string(legacy.Response.Body()), string(versioned.Response.Body()), path)
+	}
+
+	ctx := get(APIPrefix + "/deployments/team-b")
+	assert.Equal(t, 404, ctx.Response.StatusCode())
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+	assert.Equal(t, CodeNamespaceNotWatched, response.Code)
+	assert.Equal(t, "Namespace not being watched: team-b", response.Detail)
+	assert.Equal(t, "Request Error", response.Error)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+)
+
+const (
+	// defaultStreamHeartbeatInterval is how often an idle event stream sends a keep-alive comment
+	defaultStreamHeartbeatInterval = 15 * time.Second
+	// sseRetryMillis tells EventSource clients how long to wait before reconnecting
+	sseRetryMillis = 3000
+)
+
+// DeploymentEventResponse is the payload of a streamed deployment event
+type DeploymentEventResponse struct {
+	Type   string            `json:"type"`
+	Change string            `json:"change,omitempty"`
+	Time   time.Time         `json:"time"`
+	Object DeploymentSummary `json:"object"`
+}
+
+// StreamErrorResponse mirrors the Status object Kubernetes sends in ERROR watch events
+type StreamErrorResponse struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// newDeploymentEventResponse converts a broadcaster event into its API representation
+func newDeploymentEventResponse(event informer.DeploymentEvent) DeploymentEventResponse {
+	return DeploymentEventResponse{
+		Type:   event.Type,
+		Change: event.Change,
+		Time:   event.Time,
+		Object: summarizeDeployment(event.Deployment),
+	}
+}
+
+// parseEventFilter builds a broadcaster filter from the comma-separated namespace and name query parameters
+func parseEventFilter(args *fasthttp.Args) informer.EventFilter {
+	namespaces := splitQueryList(args, "namespace")
+	names := splitQueryList(args, "name")
+	if len(namespaces) == 0 && len(names) == 0 {
+		return nil
+	}
+	return func(event informer.DeploymentEvent) bool {
+		if len(namespaces) > 0 && !namespaces[event.Namespace] {
+			return false
+		}
+		if len(names) > 0 && !names[event.Name] {
+			return false
+		}
+		return true
+	}
+}
+
+// restrictEventFilter narrows a filter, which may be nil, to events in the given namespaces
+func restrictEventFilter(filter informer.EventFilter, namespaces []string) informer.EventFilter {
+	allowed := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		allowed[ns] = true
+	}
+	return func(event informer.DeploymentEvent) bool {
+		return allowed[event.Namespace] && (filter == nil || filter(event))
+	}
+}
+
+// splitQueryList collects the values of a repeatable, comma-separated query parameter
+func splitQueryList(args *fasthttp.Args, key string) map[string]bool {
+	values := make(map[string]bool)
+	for _, raw := range args.PeekMulti(key) {
+		for _, v := range strings.Split(string(raw), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values[v] = true
+			}
+		}
+	}
+	return values
+}
+
+// parseLastEventID reads the resume position from the Last-Event-ID header or the lastEventId query parameter
+func parseLastEventID(ctx *fasthttp.RequestCtx) (id uint64, resume bool, err error) {
+	raw := string(ctx.Request.Header.Peek("Last-Event-ID"))
+	if raw == "" {
+		raw = string(ctx.QueryArgs().Peek("lastEventId"))
+	}
+	if raw == "" {
+		return 0, false, nil
+	}
+	id, err = strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, false, &parameterError{Parameter: "Last-Event-ID", Err: fmt.Errorf("must be a non-negative integer")}
+	}
+	return id, true, nil
+}
+
+// handleDeploymentEvents handles GET /events/deployments - streams deployment changes as Server-Sent Events
+func (hm *HandlerManager) handleDeploymentEvents(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	lastEventID, resume, err := parseLastEventID(ctx)
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return
+	}
+
+	filter := parseEventFilter(ctx.QueryArgs())
+	if user := requestUser(ctx); hm.accessRestricted(user) {
+		// The visible namespaces are fixed when the stream opens
+		visible, err := hm.visibleNamespaces(ctx, user, verbWatch, hm.informerManager.GetAvailableNamespaces())
+		if err != nil {
+			hm.writeAuthorizationErrorResponse(ctx, err, logger)
+			return
+		}
+		filter = restrictEventFilter(filter, visible)
+	}
+
+	broadcaster := hm.informerManager.Events()
+	sub, replay, complete := broadcaster.Subscribe(filter, hm.options.StreamBufferSize, resume, lastEventID)
+
+	logger.Info().
+		Bool("resume", resume).
+		Uint64("last_event_id", lastEventID).
+		Int("replay", len(replay)).
+		Msg("Deployment event stream opened")
+
+	ctx.SetStatusCode(200)
+	ctx.Response.Header.Set("Content-Type", "text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Connection", "keep-alive")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer broadcaster.Unsubscribe(sub)
+		hm.streamDeploymentEvents(w, sub, replay, complete, logger)
+		logger.Info().Msg("Deployment event stream closed")
+	})
+}
+
+// streamDeploymentEvents writes replayed and live events to an SSE stream until the client goes away
+// or the subscription ends. Heartbeat comments keep idle connections open through proxies.
+func (hm *HandlerManager) streamDeploymentEvents(w *bufio.Writer, sub *informer.Subscription, replay []informer.DeploymentEvent, complete bool, logger zerolog.Logger) {
+	interval := hm.options.StreamHeartbeatInterval
+	if interval <= 0 {
+		interval = defaultStreamHeartbeatInterval
+	}
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis); err != nil {
+		return
+	}
+	if !complete {
+		writeSSEError(w, StreamErrorResponse{
+			Code:    410,
+			Reason:  "Expired",
+			Message: "requested events are no longer available, re-list deployments before resuming",
+		})
+	}
+	for _, event := range replay {
+		if err := writeSSEEvent(w, event); err != nil {
+			logger.Error().Err(err).Msg("Failed to write replayed deployment event")
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				if errors.Is(sub.Err(), informer.ErrSlowSubscriber) {
+					logger.Warn().Msg("Dropping slow deployment event subscriber")
+					writeSSEError(w, StreamErrorResponse{
+						Code:    429,
+						Reason:  "TooSlow",
+						Message: "client did not keep up with events, reconnect with Last-Event-ID to resume",
+					})
+					_ = w.Flush()
+				}
+				return
+			}
+			if err := writeSSEEvent(w, event); err != nil {
+				logger.Error().Err(err).Msg("Failed to write deployment event")
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeSSEEvent writes a deployment event as an SSE message whose id allows resumption
+func writeSSEEvent(w *bufio.Writer, event informer.DeploymentEvent) error {
+	data, err := json.Marshal(newDeploymentEventResponse(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// writeSSEError writes an ERROR message, like the ERROR events of a Kubernetes watch
+func writeSSEError(w *bufio.Writer, status StreamErrorResponse) {
+	data, _ := json.Marshal(status)
+	_, _ = fmt.Fprintf(w, "event: ERROR\ndata: %s\n\n", data)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+)
+
+// startTestStream runs streamDeploymentEvents against a pipe and returns a reader for its output
+func startTestStream(t *testing.T, hm *HandlerManager, sub *informer.Subscription, replay []informer.DeploymentEvent, complete bool) *bufio.Reader {
+	t.Helper()
+	pr, pw := io.Pipe()
+	t.Cleanup(func() { _ = pr.Close() })
+	go func() {
+		hm.streamDeploymentEvents(bufio.NewWriter(pw), sub, replay, complete, zerolog.Nop())
+		_ = pw.Close()
+	}()
+	return bufio.NewReader(pr)
+}
+
+// readSSEFrame reads the next message of an SSE stream, optionally skipping heartbeat comments
+func readSSEFrame(t *testing.T, r *bufio.Reader, skipComments bool) string {
+	t.Helper()
+	for {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				break
+			}
+			lines = append(lines, line)
+		}
+		frame := strings.Join(lines, "\n")
+		if skipComments && strings.HasPrefix(frame, ":") {
+			continue
+		}
+		return frame
+	}
+}
+
+// sseData extracts the data field of an SSE message
+func sseData(t *testing.T, frame string) []byte {
+	t.Helper()
+	for _, line := range strings.Split(frame, "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			return []byte(data)
+		}
+	}
+	t.Fatalf("no data in frame %q", frame)
+	return nil
+}
+
+func TestParseEventFilter(t *testing.T) {
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.Parse("namespace=team-a,team-b&name=web")
+
+	filter := parseEventFilter(args)
+	require.NotNil(t, filter)
+	assert.True(t, filter(informer.DeploymentEvent{Namespace: "team-b", Name: "web"}))
+	assert.False(t, filter(informer.DeploymentEvent{Namespace: "team-c", Name: "web"}))
+	assert.False(t, filter(informer.DeploymentEvent{Namespace: "team-a", Name: "api"}))
+
+	args.Reset()
+	assert.Nil(t, parseEventFilter(args))
+}
+
+func TestStreamDeploymentEvents(t *testing.T) {
+	b := informer.NewBroadcaster(16)
+	// IDs are counted from the broadcaster's epoch
+	epoch := b.LastEventID()
+	b.Publish(informer.DeploymentEvent{Type: informer.EventAdded, Namespace: "team-a", Name: "web", Deployment: newTestDeployment("team-a", "web", 2)})
+	b.Publish(informer.DeploymentEvent{Type: informer.EventAdded, Namespace: "team-b", Name: "api", Deployment: newTestDeployment("team-b", "api", 1)})
+
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.Parse("namespace=team-a")
+	sub, replay, complete := b.Subscribe(parseEventFilter(args), 16, true, 0)
+	require.True(t, complete)
+
+	hm := NewHandlerManagerWithOptions(nil, "test-version", Options{StreamHeartbeatInterval: 20 * time.Millisecond})
+	r := startTestStream(t, hm, sub, replay, complete)
+
+	assert.Equal(t, "retry: 3000", readSSEFrame(t, r, true))
+
+	frame := readSSEFrame(t, r, true)
+	assert.True(t, strings.HasPrefix(frame, fmt.Sprintf("id: %d\nevent: ADDED\n", epoch+1)), frame)
+
+	var event DeploymentEventResponse
+	require.NoError(t, json.Unmarshal(sseData(t, frame), &event))
+	assert.Equal(t, "ADDED", event.Type)
+	assert.Equal(t, "team-a", event.Object.Namespace)
+	assert.Equal(t, "web", event.Object.Name)
+	assert.Equal(t, int32(2), event.Object.Replicas)
+
+	scaled := newTestDeployment("team-a", "web", 3)
+	b.Publish(informer.DeploymentEvent{Type: informer.EventModified, Change: "spec_replicas", Namespace: "team-a", Name: "web", Deployment: scaled})
+
+	frame = readSSEFrame(t, r, true)
+	assert.True(t, strings.HasPrefix(frame, fmt.Sprintf("id: %d\nevent: MODIFIED\n", epoch+3)), frame)
+	require.NoError(t, json.Unmarshal(sseData(t, frame), &event))
+	assert.Equal(t, "spec_replicas", event.Change)
+
+	assert.True(t, strings.HasPrefix(readSSEFrame(t, r, false), ": heartbeat"))
+
+	b.Close()
+	for {
+		if _, err := r.ReadString('\n'); err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			break
+		}
+	}
+}
+
+func TestStreamDeploymentEvents_Errors(t *testing.T) {
+	hm := NewHandlerManager(nil, "test-version")
+
+	t.Run("SlowSubscriber", func(t *testing.T) {
+		b := informer.NewBroadcaster(16)
+		epoch := b.LastEventID()
+		sub, _, _ := b.Subscribe(nil, 1, false, 0)
+		b.Publish(informer.DeploymentEvent{Type: informer.EventAdded, Namespace: "team-a", Name: "one", Deployment: newTestDeployment("team-a", "one", 1)})
+		b.Publish(informer.DeploymentEvent{Type: informer.EventAdded, Namespace: "team-a", Name: "two", Deployment: newTestDeployment("team-a", "two", 1)})
+
+		r := startTestStream(t, hm, sub, nil, true)
+		readSSEFrame(t, r, true)
+		assert.True(t, strings.HasPrefix(readSSEFrame(t, r, true), fmt.Sprintf("id: %d\n", epoch+1)))
+
+		frame := readSSEFrame(t, r, true)
+		assert.True(t, strings.HasPrefix(frame, "event: ERROR\n"), frame)
+		var status StreamErrorResponse
+		require.NoError(t, json.Unmarshal(sseData(t, frame), &status))
+		assert.Equal(t, 429, status.Code)
+	})
+
+	t.Run("HistoryExpired", func(t *testing.T) {
+		b := informer.NewBroadcaster(1)
+		epoch := b.LastEventID()
+		b.Publish(informer.DeploymentEvent{Type: informer.EventAdded, Namespace: "team-a", Name: "one", Deployment: newTestDeployment("team-a", "one", 1)})
+		b.Publish(informer.DeploymentEvent{Type: informer.EventAdded, Namespace: "team-a", Name: "two", Deployment: newTestDeployment("team-a", "two", 1)})
+		sub, replay, complete := b.Subscribe(nil, 16, true, 0)
+		require.False(t, complete)
+
+		r := startTestStream(t, hm, sub, replay, complete)
+		readSSEFrame(t, r, true)
+
+		frame := readSSEFrame(t, r, true)
+		var status StreamErrorResponse
+		require.NoError(t, json.Unmarshal(sseData(t, frame), &status))
+		assert.Equal(t, 410, status.Code)
+		assert.Equal(t, "Expired", status.Reason)
+
+		assert.True(t, strings.HasPrefix(readSSEFrame(t, r, true), fmt.Sprintf("id: %d\n", epoch+2)))
+		b.Close()
+	})
+}
+
+func TestHandlerManager_DeploymentEvents(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a"}, newTestDeployment("team-a", "web", 1))
+	t.Cleanup(informerManager.Events().Close)
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+
+	t.Run("Stream", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/events/deployments?namespace=team-a")
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.Header.Set("Last-Event-ID", "0")
+		handler(ctx)
+
+		assert.Equal(t, 200, ctx.Response.StatusCode())
+		assert.Equal(t, "text/event-stream", string(ctx.Response.Header.ContentType()))
+		assert.Equal(t, "no-cache", string(ctx.Response.Header.Peek("Cache-Control")))
+		assert.True(t, ctx.Response.IsBodyStream())
+	})
+
+	t.Run("InvalidLastEventID", func(t *testing.T) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/events/deployments?lastEventId=abc")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		require.Equal(t, 400, ctx.Response.StatusCode())
+
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, "Last-Event-ID", response.Parameter)
+	})
+}
+
+func TestHandlerManager_DeploymentEventsAPIKeyScope(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"},
+		newTestDeployment("team-a", "web", 1), newTestDeployment("team-b", "api", 1))
+	t.Cleanup(informerManager.Events().Close)
+	// Without an Authorizer, the stream is limited by the scope of the key
+	handler := NewHandlerManagerWithOptions(informerManager, "test-version", newScopedKeyOptions(t)).CreateHandler()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/events/deployments")
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("Authorization", "Bearer key-a")
+	ctx.Request.Header.Set("Last-Event-ID", "0")
+	handler(ctx)
+	require.Equal(t, 200, ctx.Response.StatusCode())
+
+	r := bufio.NewReader(ctx.Response.BodyStream())
+	nextEvent := func() DeploymentEventResponse {
+		var event DeploymentEventResponse
+		require.NoError(t, json.Unmarshal(sseData(t, readSSEFrame(t, r, true)), &event))
+		return event
+	}
+	assert.True(t, strings.HasPrefix(readSSEFrame(t, r, true), "retry: "))
+
+	// Replayed events of other namespaces are left out
+	assert.Equal(t, "team-a", nextEvent().Object.Namespace)
+
+	informerManager.Events().Publish(informer.DeploymentEvent{Type: informer.EventModified, Namespace: "team-b", Name: "api", Deployment: newTestDeployment("team-b", "api", 2)})
+	informerManager.Events().Publish(informer.DeploymentEvent{Type: informer.EventModified, Namespace: "team-a", Name: "web", Deployment: newTestDeployment("team-a", "web", 2)})
+	event := nextEvent()
+	assert.Equal(t, "MODIFIED", event.Type)
+	assert.Equal(t, "team-a", event.Object.Namespace)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultSummaryTop and maxSummaryTop bound the top_unavailable list of GET /summary
+	defaultSummaryTop = 10
+	maxSummaryTop     = 100
+
+	// summaryTotalRow names the overall counts in table and CSV output; namespaces are lowercase
+	summaryTotalRow = "TOTAL"
+)
+
+// Health states of a deployment in GET /summary. Every deployment is in exactly one.
+const (
+	healthHealthy      = "healthy"
+	healthProgressing  = "progressing"
+	healthDegraded     = "degraded"
+	healthPaused       = "paused"
+	healthScaledToZero = "scaled_to_zero"
+)
+
+// Deployment condition reasons set by the deployment controller on the Progressing condition
+const (
+	reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	reasonNewReplicaSetAvailable   = "NewReplicaSetAvailable"
+)
+
+// SummaryResponse is returned by GET /summary
+type SummaryResponse struct {
+	Total      DeploymentHealthCounts `json:"total"`
+	Namespaces []NamespaceSummary     `json:"namespaces"`
+	// TopUnavailable are the deployments missing the most available replicas, most first
+	TopUnavailable []UnavailableDeployment `json:"top_unavailable"`
+}
+
+// NamespaceSummary holds the deployment health counts of a namespace
+type NamespaceSummary struct {
+	Namespace string                 `json:"namespace"`
+	Counts    DeploymentHealthCounts `json:"counts"`
+}
+
+// DeploymentHealthCounts counts deployments by health state. Healthy, progressing, degraded, paused
+// and scaled-to-zero add up to Deployments; ProgressDeadlineExceeded overlaps with them.
+type DeploymentHealthCounts struct {
+	Deployments int `json:"deployments"`
+	// Healthy deployments have all desired replicas available
+	Healthy int `json:"healthy"`
+	// Progressing deployments are rolling out within their progress deadline
+	Progressing int `json:"progressing"`
+	// Degraded deployments miss available replicas and are not making progress
+	Degraded     int `json:"degraded"`
+	Paused       int `json:"paused"`
+	ScaledToZero int `json:"scaled_to_zero"`
+	// ProgressDeadlineExceeded deployments have a Progressing condition with reason ProgressDeadlineExceeded
+	ProgressDeadlineExceeded int `json:"progress_deadline_exceeded"`
+	UnavailableReplicas      int `json:"unavailable_replicas"`
+}
+
+// UnavailableDeployment is a deployment missing available replicas
+type UnavailableDeployment struct {
+	Namespace           string `json:"namespace"`
+	Name                string `json:"name"`
+	Health              string `json:"health"`
+	Replicas            int32  `json:"replicas"`
+	AvailableReplicas   int32  `json:"available_replicas"`
+	UnavailableReplicas int32  `json:"unavailable_replicas"`
+	// Reason and Message are those of the Progressing condition
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleGetSummary handles GET /summary - returns deployment health counts per namespace and overall
+func (hm *HandlerManager) handleGetSummary(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	logger.Info().Msg("Summary request received")
+
+	top, err := parseSummaryTop(ctx.QueryArgs())
+	if err != nil {
+		hm.writeParameterErrorResponse(ctx, err, logger)
+		return
+	}
+
+	snapshot := hm.informerManager.Snapshot()
+	namespaces, err := hm.visibleNamespaces(ctx, requestUser(ctx), verbList, snapshot.Namespaces())
+	if err != nil {
+		hm.writeAuthorizationErrorResponse(ctx, err, logger)
+		return
+	}
+
+	response := SummaryResponse{
+		Namespaces:     make([]NamespaceSummary, 0, len(namespaces)),
+		TopUnavailable: []UnavailableDeployment{},
+	}
+	for _, namespace := range namespaces {
+		ns, _ := snapshot.Namespace(namespace)
+		summary := NamespaceSummary{Namespace: namespace}
+		for _, d := range ns.Deployments {
+			health := deploymentHealth(d)
+			summary.Counts.add(d, health)
+			response.Total.add(d, health)
+			if unavailable := desiredReplicas(d) - d.Status.AvailableReplicas; unavailable > 0 {
+				progressing := progressingCondition(d)
+				response.TopUnavailable = append(response.TopUnavailable, UnavailableDeployment{
+					Namespace:           d.Namespace,
+					Name:                d.Name,
+					Health:              health,
+					Replicas:            desiredReplicas(d),
+					AvailableReplicas:   d.Status.AvailableReplicas,
+					UnavailableReplicas: unavailable,
+					Reason:              progressing.Reason,
+					Message:             progressing.Message,
+				})
+			}
+		}
+		response.Namespaces = append(response.Namespaces, summary)
+	}
+
+	sort.Slice(response.TopUnavailable, func(i, j int) bool {
+		a, b := response.TopUnavailable[i], response.TopUnavailable[j]
+		if a.UnavailableReplicas != b.UnavailableReplicas {
+			return a.UnavailableReplicas > b.UnavailableReplicas
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	if len(response.TopUnavailable) > top {
+		response.TopUnavailable = response.TopUnavailable[:top]
+	}
+
+	hm.writeResponse(ctx, response, 200, logger)
+}
+
+// parseSummaryTop parses the top parameter, the length of the top_unavailable list
+func parseSummaryTop(args *fasthttp.Args) (int, error) {
+	raw := string(args.Peek("top"))
+	if raw == "" {
+		return defaultSummaryTop, nil
+	}
+	top, err := strconv.Atoi(raw)
+	if err != nil || top < 0 {
+		return 0, &parameterError{Parameter: "top", Err: fmt.Errorf("must be a non-negative integer")}
+	}
+	if top > maxSummaryTop {
+		return 0, &parameterError{Parameter: "top", Err: fmt.Errorf("must not exceed %d", maxSummaryTop)}
+	}
+	return top, nil
+}
+
+// deploymentHealth returns the health state of a deployment. Paused and scaled-to-zero deployments
+// are not expected to have replicas available, so they come first.
+func deploymentHealth(d *appsv1.Deployment) string {
+	switch {
+	case d.Spec.Paused:
+		return healthPaused
+	case desiredReplicas(d) == 0:
+		return healthScaledToZero
+	case isFullyAvailable(d):
+		return healthHealthy
+	}
+
+	// Without a Progressing condition the deployment controller has not acted on it yet
+	progressing := progressingCondition(d)
+	if progressing.Type == "" || (progressing.Status == corev1.ConditionTrue && progressing.Reason != reasonNewReplicaSetAvailable) {
+		return healthProgressing
+	}
+	return healthDegraded
+}
+
+// progressingCondition returns the Progressing condition of the deployment, empty when it has none
+func progressingCondition(d *appsv1.Deployment) appsv1.DeploymentCondition {
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing {
+			return c
+		}
+	}
+	return appsv1.DeploymentCondition{}
+}
+
+// add counts a deployment in its health state
+func (c *DeploymentHealthCounts) add(d *appsv1.Deployment, health string) {
+	c.Deployments++
+	switch health {
+	case healthHealthy:
+		c.Healthy++
+	case healthProgressing:
+		c.Progressing++
+	case healthDegraded:
+		c.Degraded++
+	case healthPaused:
+		c.Paused++
+	case healthScaledToZero:
+		c.ScaledToZero++
+	}
+	if progressingCondition(d).Reason == reasonProgressDeadlineExceeded {
+		c.ProgressDeadlineExceeded++
+	}
+	if unavailable := desiredReplicas(d) - d.Status.AvailableReplicas; unavailable > 0 {
+		c.UnavailableReplicas += int(unavailable)
+	}
+}
+
+func (r SummaryResponse) tableHeader() []string {
+	return []string{"NAMESPACE", "DEPLOYMENTS", "HEALTHY", "PROGRESSING", "DEGRADED", "PAUSED", "SCALED-TO-ZERO", "DEADLINE-EXCEEDED", "UNAVAILABLE-REPLICAS"}
+}
+
+// tableRows lists the namespaces and then the total; the top offenders are only in JSON and YAML
+func (r SummaryResponse) tableRows() [][]string {
+	rows := make([][]string, 0, len(r.Namespaces)+1)
+	for _, ns := range r.Namespaces {
+		rows = append(rows, ns.Counts.tableRow(ns.Namespace))
+	}
+	return append(rows, r.Total.tableRow(summaryTotalRow))
+}
+
+func (c DeploymentHealthCounts) tableRow(name string) []string {
+	return []string{
+		name,
+		strconv.Itoa(c.Deployments),
+		strconv.Itoa(c.Healthy),
+		strconv.Itoa(c.Progressing),
+		strconv.Itoa(c.Degraded),
+		strconv.Itoa(c.Paused),
+		strconv.Itoa(c.ScaledToZero),
+		strconv.Itoa(c.ProgressDeadlineExceeded),
+		strconv.Itoa(c.UnavailableReplicas),
+	}
+}
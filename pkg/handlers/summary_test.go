@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// withStatus sets the available replicas and the Progressing condition of a test deployment
+func withStatus(d *appsv1.Deployment, available int32, status corev1.ConditionStatus, reason string) *appsv1.Deployment {
+	d.Status.AvailableReplicas = available
+	if reason != "" {
+		d.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type: appsv1.DeploymentProgressing, Status: status, Reason: reason, Message: reason + " message",
+		}}
+	}
+	return d
+}
+
+func TestDeploymentHealth(t *testing.T) {
+	paused := newTestDeployment("team-a", "paused", 2)
+	paused.Spec.Paused = true
+
+	tests := map[string]struct {
+		deployment *appsv1.Deployment
+		want       string
+	}{
+		"available":          {withStatus(newTestDeployment("team-a", "web", 2), 2, corev1.ConditionTrue, reasonNewReplicaSetAvailable), healthHealthy},
+		"rolling out":        {withStatus(newTestDeployment("team-a", "web", 2), 1, corev1.ConditionTrue, "ReplicaSetUpdated"), healthProgressing},
+		"not yet seen":       {newTestDeployment("team-a", "web", 2), healthProgressing},
+		"deadline exceeded":  {withStatus(newTestDeployment("team-a", "web", 2), 1, corev1.ConditionFalse, reasonProgressDeadlineExceeded), healthDegraded},
+		"lost pods":          {withStatus(newTestDeployment("team-a", "web", 2), 1, corev1.ConditionTrue, reasonNewReplicaSetAvailable), healthDegraded},
+		"paused":             {paused, healthPaused},
+		"scaled to zero":     {newTestDeployment("team-a", "web", 0), healthScaledToZero},
+		"surge above wanted": {withStatus(newTestDeployment("team-a", "web", 2), 3, corev1.ConditionTrue, "ReplicaSetUpdated"), healthHealthy},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, deploymentHealth(tt.deployment))
+		})
+	}
+}
+
+func TestHandlerManager_Summary(t *testing.T) {
+	paused := withStatus(newTestDeployment("team-a", "batch", 3), 0, corev1.ConditionFalse, reasonProgressDeadlineExceeded)
+	paused.Spec.Paused = true
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b", "team-c"},
+		withStatus(newTestDeployment("team-a", "web", 2), 2, corev1.ConditionTrue, reasonNewReplicaSetAvailable),
+		withStatus(newTestDeployment("team-a", "api", 4), 1, corev1.ConditionFalse, reasonProgressDeadlineExceeded),
+		paused,
+		withStatus(newTestDeployment("team-b", "worker", 3), 1, corev1.ConditionTrue, "ReplicaSetUpdated"),
+		newTestDeployment("team-b", "cron", 0),
+	)
+	handler := NewHandlerManager(informerManager, "test-version").CreateHandler()
+
+	ctx := listRequest(handler, "/api/v1/summary")
+	require.Equal(t, 200, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+	var response SummaryResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+
+	assert.Equal(t, DeploymentHealthCounts{
+		Deployments: 5, Healthy: 1, Progressing: 1, Degraded: 1, Paused: 1, ScaledToZero: 1,
+		ProgressDeadlineExceeded: 2, UnavailableReplicas: 8,
+	}, response.Total)
+	assert.Equal(t, []NamespaceSummary{
+		{Namespace: "team-a", Counts: DeploymentHealthCounts{Deployments: 3, Healthy: 1, Degraded: 1, Paused: 1, ProgressDeadlineExceeded: 2, UnavailableReplicas: 6}},
+		{Namespace: "team-b", Counts: DeploymentHealthCounts{Deployments: 2, Progressing: 1, ScaledToZero: 1, UnavailableReplicas: 2}},
+		{Namespace: "team-c"},
+	}, response.Namespaces)
+	assert.Equal(t, []UnavailableDeployment{
+		{Namespace: "team-a", Name: "api", Health: healthDegraded, Replicas: 4, AvailableReplicas: 1, UnavailableReplicas: 3,
+			Reason: reasonProgressDeadlineExceeded, Message: reasonProgressDeadlineExceeded + " message"},
+		{Namespace: "team-a", Name: "batch", Health: healthPaused, Replicas: 3, UnavailableReplicas: 3,
+			Reason: reasonProgressDeadlineExceeded, Message: reasonProgressDeadlineExceeded + " message"},
+		{Namespace: "team-b", Name: "worker", Health: healthProgressing, Replicas: 3, AvailableReplicas: 1, UnavailableReplicas: 2,
+			Reason: "ReplicaSetUpdated", Message: "ReplicaSetUpdated message"},
+	}, response.TopUnavailable)
+
+	t.Run("top", func(t *testing.T) {
+		ctx := listRequest(handler, "/api/v1/summary?top=1")
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		require.Len(t, response.TopUnavailable, 1)
+		assert.Equal(t, "api", response.TopUnavailable[0].Name)
+
+		for _, top := range []string{"-1", "many", "101"} {
+			ctx := listRequest(handler, "/api/v1/summary?top="+top)
+			assert.Equal(t, 400, ctx.Response.StatusCode(), top)
+			assert.Contains(t, string(ctx.Response.Body()), `"parameter":"top"`, top)
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		ctx := listRequest(handler, "/api/v1/summary?output=csv")
+		assert.Equal(t, "NAMESPACE,DEPLOYMENTS,HEALTHY,PROGRESSING,DEGRADED,PAUSED,SCALED-TO-ZERO,DEADLINE-EXCEEDED,UNAVAILABLE-REPLICAS\n"+
+			"team-a,3,1,0,1,1,0,2,6\nteam-b,2,0,1,0,0,1,0,2\nteam-c,0,0,0,0,0,0,0,0\nTOTAL,5,1,1,1,1,1,2,8\n", string(ctx.Response.Body()))
+	})
+
+	t.Run("authorization", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Authenticator = &fakeAuthenticator{}
+		opts.Authorizer = &fakeAuthorizer{allowed: map[string][]string{"team-b": {"list"}}}
+		handler := NewHandlerManagerWithOptions(informerManager, "test-version", opts).CreateHandler()
+
+		ctx := authRequest(handler, "/api/v1/summary", "Bearer good")
+		require.Equal(t, 200, ctx.Response.StatusCode())
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &response))
+		assert.Equal(t, 2, response.Total.Deployments)
+		require.Len(t, response.Namespaces, 1)
+		assert.Equal(t, "team-b", response.Namespaces[0].Namespace)
+		require.Len(t, response.TopUnavailable, 1)
+		assert.Equal(t, "worker", response.TopUnavailable[0].Name)
+	})
+}
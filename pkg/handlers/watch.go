@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/vanelin/k8s-controller/pkg/auth"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Operations a WebSocket watch client can send
+const (
+	watchOpSubscribe   = "subscribe"
+	watchOpUnsubscribe = "unsubscribe"
+)
+
+// Message types sent to WebSocket watch clients in addition to ADDED, MODIFIED and DELETED
+const (
+	watchTypeSubscribed   = "SUBSCRIBED"
+	watchTypeUnsubscribed = "UNSUBSCRIBED"
+	watchTypeError        = "ERROR"
+)
+
+// errWatchForbidden is returned by authorizeSubscription when the user may not watch the namespace
+var errWatchForbidden = errors.New("forbidden")
+
+const (
+	// watchWriteTimeout bounds every write to a watch client; a client that stops reading is disconnected
+	watchWriteTimeout = 10 * time.Second
+	// maxWatchSubscriptions limits the subscriptions a single connection may hold
+	maxWatchSubscriptions = 32
+	// maxWatchRequestSize limits the size of messages accepted from watch clients
+	maxWatchRequestSize = 4096
+)
+
+// watchUpgrader upgrades watch requests; cross-origin browser connections are rejected
+var watchUpgrader = websocket.FastHTTPUpgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// WatchRequest is a message sent by a WebSocket watch client
+type WatchRequest struct {
+	Op            string `json:"op"`
+	ID            string `json:"id"`
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"label_selector,omitempty"`
+}
+
+// WatchEvent is a message sent to a WebSocket watch client
+type WatchEvent struct {
+	Type            string               `json:"type"`
+	Subscription    string               `json:"subscription,omitempty"`
+	Change          string               `json:"change,omitempty"`
+	ResourceVersion string               `json:"resource_version,omitempty"`
+	Object          *DeploymentSummary   `json:"object,omitempty"`
+	Status          *StreamErrorResponse `json:"status,omitempty"`
+}
+
+// watchSubscription is a namespace and label selector a watch client subscribed to
+type watchSubscription struct {
+	id        string
+	namespace string
+	selector  labels.Selector
+	// allowed restricts a subscription to all namespaces to the ones the user may watch; nil allows all
+	allowed map[string]bool
+}
+
+// matches reports whether a deployment falls within the subscription
+func (s watchSubscription) matches(d *appsv1.Deployment) bool {
+	if s.namespace != "" && s.namespace != d.Namespace {
+		return false
+	}
+	if s.allowed != nil && !s.allowed[d.Namespace] {
+		return false
+	}
+	return s.selector.Matches(labels.Set(d.Labels))
+}
+
+// watchSession holds the state of one WebSocket watch connection
+type watchSession struct {
+	hm     *HandlerManager
+	conn   *websocket.Conn
+	logger zerolog.Logger
+	// user is the authenticated caller, nil when authentication is off
+	user *auth.User
+	// subscriptions is replaced, never modified, so the broadcaster can read it without locking
+	subscriptions atomic.Pointer[[]watchSubscription]
+}
+
+// handleDeploymentWatch handles GET /watch/deployments - a WebSocket watch of deployment changes
+func (hm *HandlerManager) handleDeploymentWatch(ctx *fasthttp.RequestCtx, logger zerolog.Logger) {
+	// The request context is released once the connection is hijacked, read the user now
+	user := requestUser(ctx)
+	err := watchUpgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+		session := &watchSession{hm: hm, conn: conn, logger: logger, user: user}
+		session.subscriptions.Store(&[]watchSubscription{})
+		session.serve()
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("WebSocket upgrade failed")
+	}
+}
+
+// serve runs the watch protocol until the client disconnects or the event subscription ends.
+// All writes happen on this goroutine; a separate goroutine reads client requests.
+func (s *watchSession) serve() {
+	broadcaster := s.hm.informerManager.Events()
+	sub, _, _ := broadcaster.Subscribe(s.wants, s.hm.options.StreamBufferSize, false, 0)
+	defer broadcaster.Unsubscribe(sub)
+
+	pingInterval := s.hm.options.StreamHeartbeatInterval
+	if pingInterval <= 0 {
+		pingInterval = defaultStreamHeartbeatInterval
+	}
+	pongWait := 2 * pingInterval
+
+	s.logger.Info().Msg("Deployment watch connection opened")
+	defer s.logger.Info().Msg("Deployment watch connection closed")
+
+	requests := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	readerDone := make(chan struct{})
+
+	s.conn.SetReadLimit(maxWatchRequestSize)
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go func() {
+		defer close(readerDone)
+		for {
+			_, message, err := s.conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+			select {
+			case requests <- message:
+			case <-done:
+				return
+			}
+		}
+	}()
+	// fasthttp recycles the hijacked connection once serve returns, so the reader must have exited by then
+	defer func() {
+		_ = s.conn.Close()
+		<-readerDone
+	}()
+	defer close(done)
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case message := <-requests:
+			if err := s.handleRequest(message); err != nil {
+				s.logger.Warn().Err(err).Msg("Failed to answer watch request")
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				s.closeWith(sub.Err())
+				return
+			}
+			if err := s.dispatch(event); err != nil {
+				s.logger.Warn().Err(err).Msg("Failed to write watch event")
+				return
+			}
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchWriteTimeout)); err != nil {
+				return
+			}
+		case err := <-readErr:
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.Debug().Err(err).Msg("Watch connection read failed")
+			}
+			return
+		}
+	}
+}
+
+// wants is the broadcaster filter selecting events that match any current subscription
+func (s *watchSession) wants(event informer.DeploymentEvent) bool {
+	for _, sub := range *s.subscriptions.Load() {
+		if sub.matches(event.Deployment) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch sends an event once for every subscription it matches
+func (s *watchSession) dispatch(event informer.DeploymentEvent) error {
+	for _, sub := range *s.subscriptions.Load() {
+		if !sub.matches(event.Deployment) {
+			continue
+		}
+		summary := summarizeDeployment(event.Deployment)
+		err := s.write(WatchEvent{
+			Type:            event.Type,
+			Subscription:    sub.id,
+			Change:          event.Change,
+			ResourceVersion: summary.ResourceVersion,
+			Object:          &summary,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleRequest applies a subscribe or unsubscribe request. Invalid requests are answered with an
+// ERROR message; only write failures are returned.
+func (s *watchSession) handleRequest(message []byte) error {
+	var req WatchRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		return s.writeError("", 400, "BadRequest", "request must be a JSON object")
+	}
+
+	switch req.Op {
+	case watchOpSubscribe:
+		return s.subscribe(req)
+	case watchOpUnsubscribe:
+		return s.unsubscribe(req)
+	default:
+		return s.writeError(req.ID, 400, "BadRequest", fmt.Sprintf("unknown op %q, use %s or %s", req.Op, watchOpSubscribe, watchOpUnsubscribe))
+	}
+}
+
+// subscribe adds a subscription and sends an ADDED event for every cached deployment it matches,
+// like a Kubernetes watch started without a resourceVersion
+func (s *watchSession) subscribe(req WatchRequest) error {
+	current := *s.subscriptions.Load()
+	switch {
+	case req.ID == "":
+		return s.writeError("", 400, "BadRequest", "id is required")
+	case findWatchSubscription(current, req.ID) >= 0:
+		return s.writeError(req.ID, 409, "AlreadyExists", "subscription already exists: "+req.ID)
+	case len(current) >= maxWatchSubscriptions:
+		return s.writeError(req.ID, 429, "TooManySubscriptions", fmt.Sprintf("at most %d subscriptions per connection", maxWatchSubscriptions))
+	case req.Namespace != "" && !s.hm.informerManager.HasInformer(req.Namespace):
+		return s.writeError(req.ID, 404, "NotFound", "Namespace not being watched: "+req.Namespace)
+	}
+
+	selector := labels.Everything()
+	if req.LabelSelector != "" {
+		var err error
+		if selector, err = labels.Parse(req.LabelSelector); err != nil {
+			return s.writeError(req.ID, 400, "BadRequest", "invalid label_selector: "+err.Error())
+		}
+	}
+
+	sub := watchSubscription{id: req.ID, namespace: req.Namespace, selector: selector}
+	if err := s.authorizeSubscription(&sub); err != nil {
+		if errors.Is(err, errWatchForbidden) {
+			return s.writeError(req.ID, 403, "Forbidden", "Not allowed to watch deployments in namespace "+req.Namespace)
+		}
+		s.logger.Error().Err(err).Msg("Failed to authorize watch subscription")
+		return s.writeError(req.ID, 503, "ServiceUnavailable", "Authorization is temporarily unavailable")
+	}
+	updated := append(append([]watchSubscription{}, current...), sub)
+	s.subscriptions.Store(&updated)
+
+	if err := s.write(WatchEvent{Type: watchTypeSubscribed, Subscription: sub.id}); err != nil {
+		return err
+	}
+
+	namespaces := []string{sub.namespace}
+	if sub.namespace == "" {
+		namespaces = s.hm.informerManager.GetAvailableNamespaces()
+	}
+	for _, ns := range namespaces {
+		for _, d := range s.hm.informerManager.ListDeployments(ns) {
+			if !sub.matches(d) {
+				continue
+			}
+			summary := summarizeDeployment(d)
+			err := s.write(WatchEvent{
+				Type:            informer.EventAdded,
+				Subscription:    sub.id,
+				ResourceVersion: summary.ResourceVersion,
+				Object:          &summary,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeSubscription checks that the user may watch the namespace of the subscription. A subscription
+// to all namespaces is limited to the namespaces the user may watch when it is created.
+func (s *watchSession) authorizeSubscription(sub *watchSubscription) error {
+	if !s.hm.accessRestricted(s.user) {
+		return nil
+	}
+
+	ctx := context.Background()
+	if sub.namespace != "" {
+		allowed, err := s.hm.authorize(ctx, s.user, verbWatch, sub.namespace, "")
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errWatchForbidden
+		}
+		return nil
+	}
+
+	visible, err := s.hm.visibleNamespaces(ctx, s.user, verbWatch, s.hm.informerManager.GetAvailableNamespaces())
+	if err != nil {
+		return err
+	}
+	sub.allowed = make(map[string]bool, len(visible))
+	for _, ns := range visible {
+		sub.allowed[ns] = true
+	}
+	return nil
+}
+
+// unsubscribe removes a subscription
+func (s *watchSession) unsubscribe(req WatchRequest) error {
+	current := *s.subscriptions.Load()
+	i := findWatchSubscription(current, req.ID)
+	if i < 0 {
+		return s.writeError(req.ID, 404, "NotFound", "subscription not found: "+req.ID)
+	}
+
+	updated := append(append([]watchSubscription{}, current[:i]...), current[i+1:]...)
+	s.subscriptions.Store(&updated)
+	return s.write(WatchEvent{Type: watchTypeUnsubscribed, Subscription: req.ID})
+}
+
+func findWatchSubscription(subs []watchSubscription, id string) int {
+	for i, sub := range subs {
+		if sub.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// closeWith tells the client why its event subscription ended and closes the connection
+func (s *watchSession) closeWith(reason error) {
+	code, text := websocket.CloseGoingAway, "server shutting down"
+	if errors.Is(reason, informer.ErrSlowSubscriber) {
+		s.logger.Warn().Msg("Dropping slow deployment watch client")
+		code, text = websocket.CloseTryAgainLater, "client did not keep up with events, reconnect and resubscribe"
+	}
+	_ = s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(watchWriteTimeout))
+}
+
+func (s *watchSession) writeError(id string, code int, reason, message string) error {
+	return s.write(WatchEvent{
+		Type:         watchTypeError,
+		Subscription: id,
+		Status:       &StreamErrorResponse{Code: code, Reason: reason, Message: message},
+	})
+}
+
+func (s *watchSession) write(event WatchEvent) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(watchWriteTimeout)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(event)
+}
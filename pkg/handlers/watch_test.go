@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/vanelin/k8s-controller/pkg/informer"
+)
+
+// dialTestWatch serves the handler on an in-memory listener and opens a watch connection to it
+func dialTestWatch(t *testing.T, hm *HandlerManager) *websocket.Conn {
+	t.Helper()
+	return dialTestWatchWithHeader(t, hm, nil)
+}
+
+// dialTestWatchWithHeader opens a watch connection sending the given request headers
+func dialTestWatchWithHeader(t *testing.T, hm *HandlerManager, header http.Header) *websocket.Conn {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: hm.CreateHandler()}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	dialer := websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	conn, _, err := dialer.Dial("ws://test/watch/deployments", header)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// readWatchEvent reads the next message from a watch connection
+func readWatchEvent(t *testing.T, conn *websocket.Conn) WatchEvent {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var event WatchEvent
+	require.NoError(t, conn.ReadJSON(&event))
+	return event
+}
+
+func TestDeploymentWatch_SubscribeAndEvents(t *testing.T) {
+	web := newTestDeployment("team-a", "web", 2)
+	web.Labels["tier"] = "frontend"
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"},
+		web,
+		newTestDeployment("team-a", "worker", 1),
+		newTestDeployment("team-b", "api", 1),
+	)
+	conn := dialTestWatch(t, NewHandlerManager(informerManager, "test-version"))
+
+	require.NoError(t, conn.WriteJSON(WatchRequest{Op: "subscribe", ID: "frontend", Namespace: "team-a", LabelSelector: "tier=frontend"}))
+
+	event := readWatchEvent(t, conn)
+	assert.Equal(t, "SUBSCRIBED", event.Type)
+	assert.Equal(t, "frontend", event.Subscription)
+
+	event = readWatchEvent(t, conn)
+	assert.Equal(t, "ADDED", event.Type)
+	require.NotNil(t, event.Object)
+	assert.Equal(t, "web", event.Object.Name)
+	assert.Equal(t, "1", event.ResourceVersion)
+
+	scaled := newTestDeployment("team-a", "web", 5)
+	scaled.Labels["tier"] = "frontend"
+	informerManager.Events().Publish(informer.DeploymentEvent{Type: informer.EventModified, Change: "spec_replicas", Namespace: "team-a", Name: "web", Deployment: scaled})
+	informerManager.Events().Publish(informer.DeploymentEvent{Type: informer.EventModified, Namespace: "team-b", Name: "api", Deployment: newTestDeployment("team-b", "api", 2)})
+
+	event = readWatchEvent(t, conn)
+	assert.Equal(t, "MODIFIED", event.Type)
+	assert.Equal(t, "spec_replicas", event.Change)
+	assert.Equal(t, int32(5), event.Object.Replicas)
+
+	require.NoError(t, conn.WriteJSON(WatchRequest{Op: "unsubscribe", ID: "frontend"}))
+	event = readWatchEvent(t, conn)
+	assert.Equal(t, "UNSUBSCRIBED", event.Type)
+
+	require.NoError(t, conn.WriteJSON(WatchRequest{Op: "subscribe", ID: "all-b", Namespace: "team-b"}))
+	assert.Equal(t, "SUBSCRIBED", readWatchEvent(t, conn).Type)
+	event = readWatchEvent(t, conn)
+	assert.Equal(t, "ADDED", event.Type)
+	assert.Equal(t, "api", event.Object.Name)
+}
+
+func TestDeploymentWatch_APIKeyScope(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a", "team-b"},
+		newTestDeployment("team-a", "web", 1),
+		newTestDeployment("team-b", "api", 1),
+	)
+	// Without an Authorizer, subscriptions are limited by the scope of the key
+	hm := NewHandlerManagerWithOptions(informerManager, "test-version", newScopedKeyOptions(t))
+	conn := dialTestWatchWithHeader(t, hm, http.Header{"Authorization": {"Bearer key-a"}})
+
+	require.NoError(t, conn.WriteJSON(WatchRequest{Op: "subscribe", ID: "b", Namespace: "team-b"}))
+	event := readWatchEvent(t, conn)
+	assert.Equal(t, "ERROR", event.Type)
+	require.NotNil(t, event.Status)
+	assert.Equal(t, 403, event.Status.Code)
+
+	require.NoError(t, conn.WriteJSON(WatchRequest{Op: "subscribe", ID: "all"}))
+	assert.Equal(t, "SUBSCRIBED", readWatchEvent(t, conn).Type)
+	event = readWatchEvent(t, conn)
+	assert.Equal(t, "ADDED", event.Type)
+	assert.Equal(t, "team-a", event.Object.Namespace)
+
+	informerManager.Events().Publish(informer.DeploymentEvent{Type: informer.EventModified, Namespace: "team-b", Name: "api", Deployment: newTestDeployment("team-b", "api", 2)})
+	informerManager.Events().Publish(informer.DeploymentEvent{Type: informer.EventModified, Namespace: "team-a", Name: "web", Deployment: newTestDeployment("team-a", "web", 2)})
+	event = readWatchEvent(t, conn)
+	assert.Equal(t, "MODIFIED", event.Type)
+	assert.Equal(t, "team-a", event.Object.Namespace)
+}
+
+func TestDeploymentWatch_InvalidRequests(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a"})
+	conn := dialTestWatch(t, NewHandlerManager(informerManager, "test-version"))
+
+	tests := []struct {
+		name    string
+		message string
+		code    int
+	}{
+		{"malformed", `{"op":`, 400},
+		{"unknown op", `{"op":"list","id":"x"}`, 400},
+		{"missing id", `{"op":"subscribe"}`, 400},
+		{"unwatched namespace", `{"op":"subscribe","id":"x","namespace":"other"}`, 404},
+		{"invalid selector", `{"op":"subscribe","id":"x","label_selector":"=="}`, 400},
+		{"unknown subscription", `{"op":"unsubscribe","id":"x"}`, 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tt.message)))
+			event := readWatchEvent(t, conn)
+			assert.Equal(t, "ERROR", event.Type)
+			require.NotNil(t, event.Status)
+			assert.Equal(t, tt.code, event.Status.Code)
+		})
+	}
+
+	t.Run("duplicate subscription", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(WatchRequest{Op: "subscribe", ID: "dup"}))
+		assert.Equal(t, "SUBSCRIBED", readWatchEvent(t, conn).Type)
+		require.NoError(t, conn.WriteJSON(WatchRequest{Op: "subscribe", ID: "dup"}))
+		assert.Equal(t, 409, readWatchEvent(t, conn).Status.Code)
+	})
+}
+
+func TestDeploymentWatch_PingAndClose(t *testing.T) {
+	informerManager := newFakeInformerManager(t, []string{"team-a"})
+	hm := NewHandlerManagerWithOptions(informerManager, "test-version", Options{StreamHeartbeatInterval: 20 * time.Millisecond})
+	conn := dialTestWatch(t, hm)
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	// Reading drives the ping handler; the server closes the connection on shutdown
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ping received")
+	}
+
+	informerManager.Events().Close()
+	select {
+	case err := <-closed:
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+}
+
+func TestHandlerManager_DeploymentWatchRequiresUpgrade(t *testing.T) {
+	handler := NewHandlerManager(newFakeInformerManager(t, nil), "test-version").CreateHandler()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/watch/deployments")
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+
+	assert.Equal(t, 400, ctx.Response.StatusCode())
+}
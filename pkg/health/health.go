@@ -0,0 +1,182 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Names of the checks reported by the probe endpoints
+const (
+	CheckPing           = "ping"
+	CheckShutdown       = "shutdown"
+	CheckInformerSync   = "informer-sync"
+	CheckManagerCache   = "manager-cache"
+	CheckLeaderElection = "leader-election"
+)
+
+// Leader election roles reported by the leader-election check
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+	RoleDisabled = "disabled"
+)
+
+// Result is the outcome of a single probe check
+type Result struct {
+	Name string
+	// Err is nil when the check passed
+	Err error
+	// Info adds context to a passing check, e.g. the leader election role
+	Info string
+}
+
+// State tracks the conditions reported by the liveness and readiness probes. It is safe for concurrent use.
+type State struct {
+	mu             sync.RWMutex
+	shuttingDown   bool
+	leaderElection bool
+	leader         bool
+	managerCache   bool
+	managerSynced  bool
+	informerStatus func() map[string]bool
+}
+
+// NewState creates a state for a process that is not shutting down and has no components to wait for
+func NewState() *State {
+	return &State{}
+}
+
+// SetShuttingDown marks the process as shutting down, which fails readiness from then on
+func (s *State) SetShuttingDown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shuttingDown = true
+}
+
+// ShuttingDown reports whether shutdown has started
+func (s *State) ShuttingDown() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shuttingDown
+}
+
+// EnableLeaderElection records that the process takes part in leader election and starts as a follower
+func (s *State) EnableLeaderElection() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leaderElection = true
+}
+
+// SetLeader records that this process won the leader election
+func (s *State) SetLeader() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leader = true
+}
+
+// Role returns the leader election role of the process
+func (s *State) Role() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch {
+	case !s.leaderElection:
+		return RoleDisabled
+	case s.leader:
+		return RoleLeader
+	default:
+		return RoleFollower
+	}
+}
+
+// ExpectManagerCache makes readiness wait until SetManagerCacheSynced is called
+func (s *State) ExpectManagerCache() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.managerCache = true
+}
+
+// SetManagerCacheSynced records that the controller-runtime manager cache has synced
+func (s *State) SetManagerCacheSynced() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.managerSynced = true
+}
+
+// SetInformerStatus sets the function reporting whether the informers of each namespace have synced
+func (s *State) SetInformerStatus(status func() map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.informerStatus = status
+}
+
+// Live returns the liveness checks. They only fail if the process can no longer serve requests,
+// so a shutdown in progress does not restart the container.
+func (s *State) Live() []Result {
+	return []Result{{Name: CheckPing}}
+}
+
+// Ready returns the readiness checks: shutdown, informer sync per namespace, manager cache sync and
+// the leader election role. Followers are ready because they serve the API from their own informers.
+func (s *State) Ready() []Result {
+	s.mu.RLock()
+	shuttingDown := s.shuttingDown
+	s.mu.RUnlock()
+
+	results := []Result{{Name: CheckShutdown}}
+	if shuttingDown {
+		results[0].Err = errors.New("shutdown in progress")
+	}
+	return append(results, s.componentResults()...)
+}
+
+// Healthy returns the checks of the legacy /healthz endpoint: all readiness checks except shutdown
+func (s *State) Healthy() []Result {
+	return append(s.Live(), s.componentResults()...)
+}
+
+// componentResults returns the informer, manager cache and leader election checks
+func (s *State) componentResults() []Result {
+	s.mu.RLock()
+	informerStatus := s.informerStatus
+	managerCache, managerSynced := s.managerCache, s.managerSynced
+	s.mu.RUnlock()
+
+	var results []Result
+	if informerStatus != nil {
+		status := informerStatus()
+		namespaces := make([]string, 0, len(status))
+		for ns := range status {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		for _, ns := range namespaces {
+			result := Result{Name: CheckInformerSync + ":" + ns}
+			if !status[ns] {
+				result.Err = fmt.Errorf("informers for namespace %s have not synced", ns)
+			}
+			results = append(results, result)
+		}
+	}
+
+	if managerCache {
+		result := Result{Name: CheckManagerCache}
+		if !managerSynced {
+			result.Err = errors.New("controller-runtime manager cache has not synced")
+		}
+		results = append(results, result)
+	}
+
+	return append(results, Result{Name: CheckLeaderElection, Info: s.Role()})
+}
+
+// FirstError returns the error of the first failing check, or nil if all passed
+func FirstError(results []Result) error {
+	for _, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("%s: %w", r.Name, r.Err)
+		}
+	}
+	return nil
+}
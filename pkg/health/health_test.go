@@ -0,0 +1,75 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resultNames(results []Result) []string {
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestState_ReadyByDefault(t *testing.T) {
+	s := NewState()
+
+	assert.NoError(t, FirstError(s.Live()))
+	assert.NoError(t, FirstError(s.Ready()))
+	assert.NoError(t, FirstError(s.Healthy()))
+	assert.Equal(t, RoleDisabled, s.Role())
+	assert.Equal(t, []string{CheckShutdown, CheckLeaderElection}, resultNames(s.Ready()))
+}
+
+func TestState_ShuttingDownFailsReadinessOnly(t *testing.T) {
+	s := NewState()
+	s.SetShuttingDown()
+
+	assert.True(t, s.ShuttingDown())
+	err := FirstError(s.Ready())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), CheckShutdown)
+	assert.NoError(t, FirstError(s.Live()))
+	assert.NoError(t, FirstError(s.Healthy()))
+}
+
+func TestState_InformerSync(t *testing.T) {
+	s := NewState()
+	status := map[string]bool{"team-b": true, "team-a": false}
+	s.SetInformerStatus(func() map[string]bool { return status })
+
+	results := s.Ready()
+	assert.Equal(t, []string{CheckShutdown, "informer-sync:team-a", "informer-sync:team-b", CheckLeaderElection}, resultNames(results))
+	err := FirstError(results)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "informer-sync:team-a")
+
+	status["team-a"] = true
+	assert.NoError(t, FirstError(s.Ready()))
+}
+
+func TestState_ManagerCache(t *testing.T) {
+	s := NewState()
+	s.ExpectManagerCache()
+	require.Error(t, FirstError(s.Healthy()))
+
+	s.SetManagerCacheSynced()
+	assert.NoError(t, FirstError(s.Healthy()))
+}
+
+func TestState_LeaderElection(t *testing.T) {
+	s := NewState()
+	s.EnableLeaderElection()
+	assert.Equal(t, RoleFollower, s.Role())
+	// Followers serve the API from their own informers, so they stay ready
+	assert.NoError(t, FirstError(s.Ready()))
+
+	s.SetLeader()
+	assert.Equal(t, RoleLeader, s.Role())
+	results := s.Ready()
+	assert.Equal(t, RoleLeader, results[len(results)-1].Info)
+}
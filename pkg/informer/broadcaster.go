@@ -0,0 +1,213 @@
+package informer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Event types published for Deployment changes, matching Kubernetes watch event types
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+)
+
+const (
+	// DefaultEventHistorySize is the number of recent events kept for resuming subscribers
+	DefaultEventHistorySize = 1024
+	// DefaultSubscriberBuffer is the number of undelivered events a subscriber may queue
+	DefaultSubscriberBuffer = 256
+)
+
+var (
+	// ErrSlowSubscriber is reported when a subscription was dropped because its buffer was full
+	ErrSlowSubscriber = errors.New("subscriber was too slow to receive events")
+	// ErrBroadcasterClosed is reported when a subscription ended because the broadcaster shut down
+	ErrBroadcasterClosed = errors.New("event broadcaster closed")
+)
+
+// DeploymentEvent describes a Deployment change observed by an informer
+type DeploymentEvent struct {
+	// ID increases monotonically and can be used to resume a subscription. IDs start after the
+	// broadcaster's epoch, so IDs of an earlier process are not mistaken for recent ones.
+	ID        uint64
+	Type      string
+	Change    string
+	Namespace string
+	Name      string
+	Time      time.Time
+	// Deployment is the object after the change (the last known state for deletions).
+	// It is shared with the informer cache and must not be modified.
+	Deployment *appsv1.Deployment
+}
+
+// EventFilter selects the events delivered to a subscription
+type EventFilter func(event DeploymentEvent) bool
+
+// Subscription receives events from a Broadcaster until it is unsubscribed or dropped
+type Subscription struct {
+	events chan DeploymentEvent
+	filter EventFilter
+	err    error
+}
+
+// Events returns the channel events are delivered on. It is closed when the subscription ends.
+func (s *Subscription) Events() <-chan DeploymentEvent {
+	return s.events
+}
+
+// Err returns why the subscription ended. It is only meaningful after Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Broadcaster fans out Deployment events to many subscribers. Publishing never blocks:
+// a subscriber whose buffer is full is dropped and can resume from the event history.
+type Broadcaster struct {
+	mu sync.Mutex
+	// epoch is the creation time in microseconds since the Unix epoch; IDs are counted from it
+	epoch       uint64
+	lastID      uint64
+	history     []DeploymentEvent
+	historySize int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroadcaster creates a broadcaster that keeps historySize recent events for resumption
+func NewBroadcaster(historySize int) *Broadcaster {
+	if historySize <= 0 {
+		historySize = DefaultEventHistorySize
+	}
+	epoch := uint64(time.Now().UnixMicro())
+	return &Broadcaster{
+		epoch:       epoch,
+		lastID:      epoch,
+		historySize: historySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next ID to the event, records it in the history and delivers it to matching subscribers
+func (b *Broadcaster) Publish(event DeploymentEvent) DeploymentEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return event
+	}
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if len(b.history) == b.historySize {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:len(b.history)-1]
+	}
+	b.history = append(b.history, event)
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.dropLocked(sub, ErrSlowSubscriber)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscription with the given buffer size. When resume is true, the matching
+// events published after afterID are returned for replay, 0 replays the whole history; complete is
+// false if some of them are no longer in the history, or afterID was issued by another process.
+// Replayed events are never delivered on the subscription channel.
+func (b *Broadcaster) Subscribe(filter EventFilter, buffer int, resume bool, afterID uint64) (sub *Subscription, replay []DeploymentEvent, complete bool) {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		events: make(chan DeploymentEvent, buffer),
+		filter: filter,
+	}
+	complete = true
+
+	if b.closed {
+		sub.err = ErrBroadcasterClosed
+		close(sub.events)
+		return sub, nil, complete
+	}
+
+	if resume && afterID == 0 {
+		afterID = b.epoch
+	}
+	if resume && (afterID < b.epoch || afterID > b.lastID) {
+		// The ID comes from before a restart, the events since then cannot be told apart
+		complete = false
+	} else if resume && afterID < b.lastID {
+		if len(b.history) == 0 || b.history[0].ID > afterID+1 {
+			complete = false
+		}
+		for _, event := range b.history {
+			if event.ID > afterID && (filter == nil || filter(event)) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub, replay, complete
+}
+
+// Unsubscribe ends a subscription. It is safe to call more than once.
+func (b *Broadcaster) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropLocked(sub, nil)
+}
+
+// LastEventID returns the ID of the most recently published event
+func (b *Broadcaster) LastEventID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// SubscriberCount returns the number of active subscriptions
+func (b *Broadcaster) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close ends all subscriptions and rejects new ones, letting streaming clients finish before shutdown
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.dropLocked(sub, ErrBroadcasterClosed)
+	}
+}
+
+// dropLocked removes a subscription and closes its channel, recording the reason
+func (b *Broadcaster) dropLocked(sub *Subscription, reason error) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	sub.err = reason
+	close(sub.events)
+}
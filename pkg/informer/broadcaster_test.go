@@ -0,0 +1,161 @@
+package informer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newEvent(eventType, namespace, name string) DeploymentEvent {
+	return DeploymentEvent{
+		Type:      eventType,
+		Namespace: namespace,
+		Name:      name,
+		Deployment: &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		},
+	}
+}
+
+func TestBroadcaster_PublishFiltersAndOrders(t *testing.T) {
+	b := NewBroadcaster(10)
+
+	all, _, _ := b.Subscribe(nil, 10, false, 0)
+	onlyA, _, _ := b.Subscribe(func(e DeploymentEvent) bool { return e.Namespace == "a" }, 10, false, 0)
+
+	b.Publish(newEvent(EventAdded, "a", "web"))
+	b.Publish(newEvent(EventAdded, "b", "api"))
+	b.Publish(newEvent(EventModified, "a", "web"))
+
+	require.Len(t, all.Events(), 3)
+	require.Len(t, onlyA.Events(), 2)
+
+	first := <-all.Events()
+	second := <-all.Events()
+	assert.Equal(t, b.epoch+1, first.ID)
+	assert.Equal(t, b.epoch+2, second.ID)
+	assert.False(t, first.Time.IsZero())
+
+	e := <-onlyA.Events()
+	assert.Equal(t, "web", e.Name)
+	assert.Equal(t, b.epoch+3, b.LastEventID())
+}
+
+func TestBroadcaster_SlowSubscriberIsDropped(t *testing.T) {
+	b := NewBroadcaster(10)
+	slow, _, _ := b.Subscribe(nil, 1, false, 0)
+	fast, _, _ := b.Subscribe(nil, 10, false, 0)
+
+	b.Publish(newEvent(EventAdded, "a", "one"))
+	b.Publish(newEvent(EventAdded, "a", "two"))
+
+	<-slow.Events()
+	_, ok := <-slow.Events()
+	assert.False(t, ok, "slow subscriber channel should be closed")
+	assert.ErrorIs(t, slow.Err(), ErrSlowSubscriber)
+
+	assert.Len(t, fast.Events(), 2)
+	assert.Equal(t, 1, b.SubscriberCount())
+}
+
+func TestBroadcaster_ResumeFromHistory(t *testing.T) {
+	b := NewBroadcaster(3)
+	for _, name := range []string{"one", "two", "three", "four"} {
+		b.Publish(newEvent(EventAdded, "a", name))
+	}
+
+	t.Run("WithinHistory", func(t *testing.T) {
+		sub, replay, complete := b.Subscribe(nil, 10, true, b.epoch+2)
+		defer b.Unsubscribe(sub)
+		assert.True(t, complete)
+		require.Len(t, replay, 2)
+		assert.Equal(t, "three", replay[0].Name)
+		assert.Equal(t, "four", replay[1].Name)
+	})
+
+	t.Run("UpToDate", func(t *testing.T) {
+		sub, replay, complete := b.Subscribe(nil, 10, true, b.epoch+4)
+		defer b.Unsubscribe(sub)
+		assert.True(t, complete)
+		assert.Empty(t, replay)
+	})
+
+	t.Run("HistoryLost", func(t *testing.T) {
+		sub, replay, complete := b.Subscribe(nil, 10, true, 0)
+		defer b.Unsubscribe(sub)
+		assert.False(t, complete)
+		assert.Len(t, replay, 3)
+	})
+
+	t.Run("AheadAfterRestart", func(t *testing.T) {
+		sub, replay, complete := b.Subscribe(nil, 10, true, b.epoch+42)
+		defer b.Unsubscribe(sub)
+		assert.False(t, complete)
+		assert.Empty(t, replay)
+	})
+}
+
+func TestBroadcaster_ResumeAfterRestart(t *testing.T) {
+	previous := NewBroadcaster(10)
+	for _, name := range []string{"one", "two"} {
+		previous.Publish(newEvent(EventAdded, "a", name))
+	}
+	lastSeen := previous.LastEventID()
+
+	// The restarted process publishes its initial sync, more events than the client had seen
+	time.Sleep(time.Millisecond)
+	b := NewBroadcaster(10)
+	for _, name := range []string{"one", "two", "three", "four"} {
+		b.Publish(newEvent(EventAdded, "a", name))
+	}
+	require.Greater(t, b.epoch, lastSeen)
+
+	sub, replay, complete := b.Subscribe(nil, 10, true, lastSeen)
+	defer b.Unsubscribe(sub)
+	assert.False(t, complete)
+	assert.Empty(t, replay)
+
+	// 0 still replays the whole history of this process
+	sub, replay, complete = b.Subscribe(nil, 10, true, 0)
+	defer b.Unsubscribe(sub)
+	assert.True(t, complete)
+	assert.Len(t, replay, 4)
+}
+
+func TestBroadcaster_Close(t *testing.T) {
+	b := NewBroadcaster(10)
+	sub, _, _ := b.Subscribe(nil, 10, false, 0)
+
+	b.Close()
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.ErrorIs(t, sub.Err(), ErrBroadcasterClosed)
+
+	late, _, _ := b.Subscribe(nil, 10, false, 0)
+	_, ok = <-late.Events()
+	assert.False(t, ok)
+
+	// Unsubscribing an ended subscription and publishing after close are no-ops
+	b.Unsubscribe(sub)
+	b.Publish(newEvent(EventAdded, "a", "web"))
+	assert.Equal(t, b.epoch, b.LastEventID())
+}
+
+func TestClassifyChange(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+	base := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: replicas(2)}}
+
+	scaled := base.DeepCopy()
+	scaled.Spec.Replicas = replicas(3)
+	assert.Equal(t, "spec_replicas", ClassifyChange(base, scaled))
+
+	ready := base.DeepCopy()
+	ready.Status.ReadyReplicas = 2
+	assert.Equal(t, "ready_replicas", ClassifyChange(base, ready))
+
+	assert.Equal(t, "status_only", ClassifyChange(base, base.DeepCopy()))
+}
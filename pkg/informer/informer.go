@@ -2,7 +2,9 @@ package informer
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog/log"
 	appsv1 "k8s.io/api/apps/v1"
@@ -15,22 +17,84 @@ import (
 
 // DeploymentInformerManager manages multiple deployment informers for different namespaces
 type DeploymentInformerManager struct {
-	mu        sync.RWMutex
-	informers map[string]cache.SharedIndexInformer
-	clientset *kubernetes.Clientset
+	mu                  sync.RWMutex
+	informers           map[string]cache.SharedIndexInformer
+	replicaSetInformers map[string]cache.SharedIndexInformer
+	podInformers        map[string]cache.SharedIndexInformer
+	// cancels stop the informers of each namespace
+	cancels map[string]context.CancelFunc
+	// states record list, event and error times of each namespace
+	states      map[string]*namespaceState
+	clientset   kubernetes.Interface
+	broadcaster *Broadcaster
+	// namespaceListeners are told the watched namespaces whenever an informer is started or stopped
+	namespaceListeners []func(namespaces []string)
+
+	// snapshotMu serializes the event handlers updating the snapshot and publishing their events,
+	// readers load the snapshot without locking
+	snapshotMu         sync.Mutex
+	snapshot           atomic.Pointer[Snapshot]
+	snapshotGeneration uint64
 }
 
 // NewDeploymentInformerManager creates a new informer manager
-func NewDeploymentInformerManager(clientset *kubernetes.Clientset) *DeploymentInformerManager {
-	return &DeploymentInformerManager{
-		informers: make(map[string]cache.SharedIndexInformer),
-		clientset: clientset,
+func NewDeploymentInformerManager(clientset kubernetes.Interface) *DeploymentInformerManager {
+	m := &DeploymentInformerManager{
+		informers:           make(map[string]cache.SharedIndexInformer),
+		replicaSetInformers: make(map[string]cache.SharedIndexInformer),
+		podInformers:        make(map[string]cache.SharedIndexInformer),
+		cancels:             make(map[string]context.CancelFunc),
+		states:              make(map[string]*namespaceState),
+		clientset:           clientset,
+		broadcaster:         NewBroadcaster(DefaultEventHistorySize),
+	}
+	m.snapshot.Store(emptySnapshot)
+	return m
+}
+
+// Events returns the broadcaster that publishes the Deployment changes seen by all informers
+func (m *DeploymentInformerManager) Events() *Broadcaster {
+	return m.broadcaster
+}
+
+// publish forwards an informer event to the broadcaster
+func (m *DeploymentInformerManager) publish(eventType, change string, deployment *appsv1.Deployment) {
+	m.broadcaster.Publish(DeploymentEvent{
+		Type:       eventType,
+		Change:     change,
+		Namespace:  deployment.Namespace,
+		Name:       deployment.Name,
+		Deployment: deployment,
+	})
+}
+
+// ClassifyChange describes which part of a Deployment changed between two versions
+func ClassifyChange(oldDeployment, newDeployment *appsv1.Deployment) string {
+	switch {
+	case replicasOf(oldDeployment) != replicasOf(newDeployment):
+		return "spec_replicas"
+	case oldDeployment.Status.Replicas != newDeployment.Status.Replicas:
+		return "status_replicas"
+	case oldDeployment.Status.ReadyReplicas != newDeployment.Status.ReadyReplicas:
+		return "ready_replicas"
+	case oldDeployment.Status.AvailableReplicas != newDeployment.Status.AvailableReplicas:
+		return "available_replicas"
+	default:
+		return "status_only"
 	}
 }
 
+// replicasOf returns the desired replicas of a Deployment, treating an unset value as the API default of 1
+func replicasOf(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
 // StartDeploymentInformer starts a shared informer for Deployments in the specified namespace.
 // This function is kept for backward compatibility.
-func StartDeploymentInformer(ctx context.Context, clientset *kubernetes.Clientset, namespace string) {
+func StartDeploymentInformer(ctx context.Context, clientset kubernetes.Interface, namespace string) {
 	manager := NewDeploymentInformerManager(clientset)
 	manager.StartInformer(ctx, namespace)
 
@@ -39,24 +103,49 @@ func StartDeploymentInformer(ctx context.Context, clientset *kubernetes.Clientse
 	log.Info().Msg("Deployment informer shutting down")
 }
 
-// StartInformer starts an informer for a specific namespace
+// StartInformer starts an informer for a specific namespace and waits for its cache to sync. The
+// informer runs until ctx is done or StopInformer is called.
 func (m *DeploymentInformerManager) StartInformer(ctx context.Context, namespace string) {
+	if !m.StartInformerAsync(ctx, namespace) {
+		return
+	}
+
+	// Wait for the informers to sync
+	if !m.WaitForSync(ctx, namespace) {
+		log.Error().Msg("Failed to sync informer cache")
+		return
+	}
+
+	log.Info().Msg("Deployment informer started successfully")
+}
+
+// StartInformerAsync starts an informer for a specific namespace without waiting for its cache to
+// sync. It returns false when the namespace already has an informer.
+func (m *DeploymentInformerManager) StartInformerAsync(ctx context.Context, namespace string) bool {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	// Check if informer already exists for this namespace
 	if _, exists := m.informers[namespace]; exists {
+		m.mu.Unlock()
 		log.Info().Str("namespace", namespace).Msg("Deployment informer already exists for namespace")
-		return
+		return false
 	}
 
 	log.Info().Str("namespace", namespace).Msg("Starting Deployment informer")
 
+	// The informers of the namespace are stopped on their own by StopInformer
+	ctx, cancel := context.WithCancel(ctx)
+	state := &namespaceState{}
+
 	// Create informer factory
 	informerFactory := cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return m.clientset.AppsV1().Deployments(namespace).List(ctx, options)
+				list, err := m.clientset.AppsV1().Deployments(namespace).List(ctx, options)
+				if err == nil {
+					state.listed()
+				}
+				return list, err
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 				return m.clientset.AppsV1().Deployments(namespace).Watch(ctx, options)
@@ -68,72 +157,176 @@ func (m *DeploymentInformerManager) StartInformer(ctx context.Context, namespace
 	)
 
 	// Add event handlers
-	_, err := informerFactory.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	_, err := informerFactory.AddEventHandler(m.eventHandler(ctx, state))
+	if err != nil {
+		m.mu.Unlock()
+		cancel()
+		log.Error().Err(err).Msg("Failed to add event handlers to informer")
+		return false
+	}
+
+	// ReplicaSets provide the revision history of the Deployments
+	replicaSetInformer := newReplicaSetInformer(ctx, m.clientset, namespace)
+	// Pods are listed per deployment by their selector
+	podInformer := newPodInformer(ctx, m.clientset, namespace)
+
+	// Errors are recorded for NamespaceStatuses, the informers have not been started yet so this cannot fail
+	_ = informerFactory.SetWatchErrorHandlerWithContext(state.watchErrorHandler)
+	_ = replicaSetInformer.SetWatchErrorHandlerWithContext(state.watchErrorHandler)
+	_ = podInformer.SetWatchErrorHandlerWithContext(state.watchErrorHandler)
+
+	// Store the informers
+	m.informers[namespace] = informerFactory
+	m.replicaSetInformers[namespace] = replicaSetInformer
+	m.podInformers[namespace] = podInformer
+	m.cancels[namespace] = cancel
+	m.states[namespace] = state
+	m.addSnapshotNamespace(namespace)
+	m.notifyNamespaceListeners()
+	// Release the lock before the sync, so readers and health probes are not blocked by it
+	m.mu.Unlock()
+
+	// Start the informers
+	go informerFactory.Run(ctx.Done())
+	go replicaSetInformer.Run(ctx.Done())
+	go podInformer.Run(ctx.Done())
+	return true
+}
+
+// eventHandler returns the Deployment event handlers of the informer of a namespace running with ctx
+func (m *DeploymentInformerManager) eventHandler(ctx context.Context, state *namespaceState) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			deployment := obj.(*appsv1.Deployment)
 			log.Info().
-				Str("event", "ADDED").
+				Str("event", EventAdded).
 				Str("namespace", deployment.Namespace).
 				Str("name", deployment.Name).
-				Int32("replicas", *deployment.Spec.Replicas).
+				Int32("replicas", replicasOf(deployment)).
 				Msg("Deployment added")
+			state.eventSeen()
+			m.applyEvent(ctx, EventAdded, "", deployment)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			oldDeployment := oldObj.(*appsv1.Deployment)
 			newDeployment := newObj.(*appsv1.Deployment)
 
 			// Determine what type of change occurred
-			var changeType string
-			if *oldDeployment.Spec.Replicas != *newDeployment.Spec.Replicas {
-				changeType = "spec_replicas"
-			} else if oldDeployment.Status.Replicas != newDeployment.Status.Replicas {
-				changeType = "status_replicas"
-			} else if oldDeployment.Status.ReadyReplicas != newDeployment.Status.ReadyReplicas {
-				changeType = "ready_replicas"
-			} else if oldDeployment.Status.AvailableReplicas != newDeployment.Status.AvailableReplicas {
-				changeType = "available_replicas"
-			} else {
-				changeType = "status_only"
-			}
+			changeType := ClassifyChange(oldDeployment, newDeployment)
 
 			log.Info().
-				Str("event", "MODIFIED").
+				Str("event", EventModified).
 				Str("namespace", newDeployment.Namespace).
 				Str("name", newDeployment.Name).
-				Int32("replicas", *newDeployment.Spec.Replicas).
+				Int32("replicas", replicasOf(newDeployment)).
 				Str("change", changeType).
 				Msg("Deployment updated")
+			state.eventSeen()
+			m.applyEvent(ctx, EventModified, changeType, newDeployment)
 		},
 		DeleteFunc: func(obj interface{}) {
-			deployment := obj.(*appsv1.Deployment)
+			// The object is a tombstone when the delete was missed while the watch was down
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			deployment, ok := obj.(*appsv1.Deployment)
+			if !ok {
+				log.Warn().Str("name", getDeploymentName(obj)).Msg("Unexpected object in Deployment delete event")
+				return
+			}
 			log.Info().
-				Str("event", "DELETED").
+				Str("event", EventDeleted).
 				Str("namespace", deployment.Namespace).
 				Str("name", deployment.Name).
 				Msg("Deployment deleted")
+			state.eventSeen()
+			m.applyEvent(ctx, EventDeleted, "", deployment)
 		},
-	})
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to add event handlers to informer")
+	}
+}
+
+// applyEvent updates the snapshot with a Deployment event of the informer running with ctx and publishes
+// it. The informer may still deliver events after StopInformer cancelled ctx; they are dropped, so a
+// stopped informer cannot change the snapshot or the events of the namespace once it is watched again.
+func (m *DeploymentInformerManager) applyEvent(ctx context.Context, eventType, change string, deployment *appsv1.Deployment) {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	// StopInformer cancels ctx before it removes the namespace from the snapshot, so with snapshotMu
+	// held an event passing this check is applied before the namespace is removed or added again
+	if ctx.Err() != nil {
+		log.Debug().
+			Str("event", eventType).
+			Str("namespace", deployment.Namespace).
+			Str("name", deployment.Name).
+			Msg("Dropping event of a stopped Deployment informer")
 		return
 	}
+	if eventType == EventDeleted {
+		m.deleteSnapshotDeploymentLocked(deployment)
+	} else {
+		m.setSnapshotDeploymentLocked(deployment)
+	}
+	m.publish(eventType, change, deployment)
+}
 
-	// Store the informer
-	m.informers[namespace] = informerFactory
+// WaitForSync waits until the informers of the namespace have synced. It returns false when ctx is
+// done first or the namespace has no informer.
+func (m *DeploymentInformerManager) WaitForSync(ctx context.Context, namespace string) bool {
+	m.mu.RLock()
+	informer, exists := m.informers[namespace]
+	replicaSetInformer := m.replicaSetInformers[namespace]
+	podInformer := m.podInformers[namespace]
+	m.mu.RUnlock()
+	if !exists {
+		return false
+	}
+	return cache.WaitForCacheSync(ctx.Done(), informer.HasSynced, replicaSetInformer.HasSynced, podInformer.HasSynced)
+}
 
-	// Start the informer
-	go informerFactory.Run(ctx.Done())
+// StopInformer stops the informers of a namespace and drops their cache. It returns false when the
+// namespace has no informer.
+func (m *DeploymentInformerManager) StopInformer(namespace string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Wait for the informer to sync
-	if !cache.WaitForCacheSync(ctx.Done(), informerFactory.HasSynced) {
-		log.Error().Msg("Failed to sync informer cache")
-		return
+	cancel, exists := m.cancels[namespace]
+	if !exists {
+		return false
 	}
 
-	log.Info().Msg("Deployment informer started successfully")
+	log.Info().Str("namespace", namespace).Msg("Stopping Deployment informer")
+	cancel()
+	delete(m.informers, namespace)
+	delete(m.replicaSetInformers, namespace)
+	delete(m.podInformers, namespace)
+	delete(m.cancels, namespace)
+	delete(m.states, namespace)
+	m.removeSnapshotNamespace(namespace)
+	m.notifyNamespaceListeners()
+	return true
+}
+
+// OnNamespacesChanged registers fn to be called with the sorted watched namespaces whenever an informer
+// is started or stopped. fn is called with the manager's lock held and must not call back into it.
+func (m *DeploymentInformerManager) OnNamespacesChanged(fn func(namespaces []string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.namespaceListeners = append(m.namespaceListeners, fn)
 }
 
-// GetDeploymentNames returns a slice of deployment names from the informer's cache for a specific namespace.
+// notifyNamespaceListeners passes the watched namespaces to the listeners, the caller holds m.mu
+func (m *DeploymentInformerManager) notifyNamespaceListeners() {
+	if len(m.namespaceListeners) == 0 {
+		return
+	}
+	namespaces := m.namespacesLocked()
+	for _, fn := range m.namespaceListeners {
+		fn(namespaces)
+	}
+}
+
+// GetDeploymentNames returns a sorted slice of deployment names from the informer's cache for a specific namespace.
 func (m *DeploymentInformerManager) GetDeploymentNames(namespace string) []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -149,9 +342,51 @@ func (m *DeploymentInformerManager) GetDeploymentNames(namespace string) []strin
 			names = append(names, d.Name)
 		}
 	}
+	// Store iteration order is random, sort for stable responses
+	sort.Strings(names)
 	return names
 }
 
+// ListDeployments returns the cached Deployments from the informer's store for a specific namespace.
+// The returned objects are shared with the cache and must not be modified.
+func (m *DeploymentInformerManager) ListDeployments(namespace string) []*appsv1.Deployment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	informer, exists := m.informers[namespace]
+	if !exists {
+		return []*appsv1.Deployment{}
+	}
+
+	objs := informer.GetStore().List()
+	deployments := make([]*appsv1.Deployment, 0, len(objs))
+	for _, obj := range objs {
+		if d, ok := obj.(*appsv1.Deployment); ok {
+			deployments = append(deployments, d)
+		}
+	}
+	return deployments
+}
+
+// GetDeployment returns the cached Deployment with the given name from the informer's store for a specific namespace.
+// The returned object is shared with the cache and must not be modified.
+func (m *DeploymentInformerManager) GetDeployment(namespace, name string) (*appsv1.Deployment, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	informer, exists := m.informers[namespace]
+	if !exists {
+		return nil, false
+	}
+
+	obj, found, err := informer.GetStore().GetByKey(namespace + "/" + name)
+	if err != nil || !found {
+		return nil, false
+	}
+	d, ok := obj.(*appsv1.Deployment)
+	return d, ok
+}
+
 // GetDeploymentNamesFromDefault returns deployment names from the default namespace informer.
 // This function is kept for backward compatibility.
 func GetDeploymentNames() []string {
@@ -159,18 +394,45 @@ func GetDeploymentNames() []string {
 	return []string{}
 }
 
-// GetAvailableNamespaces returns a list of namespaces that have active informers
+// GetAvailableNamespaces returns a sorted list of namespaces that have active informers
 func (m *DeploymentInformerManager) GetAvailableNamespaces() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return m.namespacesLocked()
+}
 
+// namespacesLocked returns the sorted namespaces with informers, the caller holds m.mu
+func (m *DeploymentInformerManager) namespacesLocked() []string {
 	namespaces := make([]string, 0, len(m.informers))
 	for namespace := range m.informers {
 		namespaces = append(namespaces, namespace)
 	}
+	sort.Strings(namespaces)
 	return namespaces
 }
 
+// SyncStatus reports, for each watched namespace, whether its Deployment, ReplicaSet and Pod informers have synced
+func (m *DeploymentInformerManager) SyncStatus() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := make(map[string]bool, len(m.informers))
+	for namespace := range m.informers {
+		status[namespace] = m.syncedLocked(namespace)
+	}
+	return status
+}
+
+// syncedLocked reports whether all informers of the namespace have synced, the caller holds m.mu
+func (m *DeploymentInformerManager) syncedLocked(namespace string) bool {
+	for _, informers := range []map[string]cache.SharedIndexInformer{m.informers, m.replicaSetInformers, m.podInformers} {
+		if informer, exists := informers[namespace]; exists && !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
 // HasInformer checks if an informer exists for the given namespace
 func (m *DeploymentInformerManager) HasInformer(namespace string) bool {
 	m.mu.RLock()
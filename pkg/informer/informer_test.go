@@ -8,8 +8,11 @@ import (
 
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/cache"
 
 	testutil "github.com/vanelin/k8s-controller/pkg/testutil"
@@ -136,3 +139,223 @@ func TestStartDeploymentInformer_CoversFunction(t *testing.T) {
 	time.Sleep(1 * time.Second)
 	cancel()
 }
+
+func TestDeploymentInformerManager_GetDeployment(t *testing.T) {
+	replicas := int32(2)
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewDeploymentInformerManager(clientset)
+	manager.StartInformer(ctx, "team-a")
+
+	d, found := manager.GetDeployment("team-a", "api")
+	require.True(t, found)
+	require.Equal(t, int32(2), *d.Spec.Replicas)
+
+	_, found = manager.GetDeployment("team-a", "missing")
+	require.False(t, found)
+
+	_, found = manager.GetDeployment("team-b", "api")
+	require.False(t, found)
+}
+
+func TestDeploymentInformerManager_PublishesEvents(t *testing.T) {
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+	clientset := fake.NewSimpleClientset(deployment)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewDeploymentInformerManager(clientset)
+	sub, _, _ := manager.Events().Subscribe(nil, 10, false, 0)
+	defer manager.Events().Unsubscribe(sub)
+	manager.StartInformer(ctx, "team-a")
+
+	next := func() DeploymentEvent {
+		select {
+		case e := <-sub.Events():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return DeploymentEvent{}
+		}
+	}
+
+	e := next()
+	require.Equal(t, EventAdded, e.Type)
+	require.Equal(t, "api", e.Name)
+
+	scaled := deployment.DeepCopy()
+	scaledReplicas := int32(3)
+	scaled.Spec.Replicas = &scaledReplicas
+	scaled.ResourceVersion = "2"
+	_, err := clientset.AppsV1().Deployments("team-a").Update(ctx, scaled, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	e = next()
+	require.Equal(t, EventModified, e.Type)
+	require.Equal(t, "spec_replicas", e.Change)
+
+	require.NoError(t, clientset.AppsV1().Deployments("team-a").Delete(ctx, "api", metav1.DeleteOptions{}))
+	e = next()
+	require.Equal(t, EventDeleted, e.Type)
+}
+
+func TestDeploymentInformerManager_ListReplicaSets(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a", UID: "api-uid"}}
+	owned := func(name, revision string) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "team-a",
+			Annotations:     map[string]string{RevisionAnnotation: revision},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		}}
+	}
+	orphan := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: "team-a"}}
+	clientset := fake.NewSimpleClientset(deployment, owned("api-2", "10"), owned("api-1", "9"), orphan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewDeploymentInformerManager(clientset)
+	manager.StartInformer(ctx, "team-a")
+
+	replicaSets := manager.ListReplicaSets(deployment)
+	require.Len(t, replicaSets, 2)
+	require.Equal(t, "api-1", replicaSets[0].Name)
+	require.Equal(t, int64(10), Revision(replicaSets[1]))
+
+	require.Empty(t, manager.ListReplicaSets(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-b", UID: "api-uid"}}))
+}
+
+func TestDeploymentInformerManager_ListPods(t *testing.T) {
+	pod := func(name, app string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:          name,
+			Namespace:     "team-a",
+			Labels:        map[string]string{"app": app},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet"}},
+		}}
+	}
+	clientset := fake.NewSimpleClientset(pod("api-b", "api"), pod("api-a", "api"), pod("web-a", "web"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewDeploymentInformerManager(clientset)
+	manager.StartInformer(ctx, "team-a")
+
+	pods := manager.ListPods("team-a", labels.SelectorFromSet(labels.Set{"app": "api"}))
+	require.Len(t, pods, 2)
+	require.Equal(t, "api-a", pods[0].Name)
+	require.Equal(t, "api-b", pods[1].Name)
+	require.Nil(t, pods[0].ManagedFields)
+
+	require.Len(t, manager.ListPods("team-a", labels.Everything()), 3)
+	require.Empty(t, manager.ListPods("team-b", labels.Everything()))
+}
+
+func TestDeploymentInformerManager_SyncStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewDeploymentInformerManager(fake.NewSimpleClientset())
+	require.Empty(t, manager.SyncStatus())
+
+	manager.StartInformer(ctx, "team-a")
+	manager.StartInformer(ctx, "team-b")
+	require.Equal(t, map[string]bool{"team-a": true, "team-b": true}, manager.SyncStatus())
+}
+
+func TestDeploymentInformerManager_StopInformer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-b"}},
+	)
+	manager := NewDeploymentInformerManager(clientset)
+	var changes [][]string
+	manager.OnNamespacesChanged(func(namespaces []string) { changes = append(changes, namespaces) })
+
+	manager.StartInformer(ctx, "team-a")
+	require.True(t, manager.StartInformerAsync(ctx, "team-b"))
+	require.False(t, manager.StartInformerAsync(ctx, "team-b"))
+	require.True(t, manager.WaitForSync(ctx, "team-b"))
+	require.Equal(t, []string{"web"}, manager.GetDeploymentNames("team-b"))
+
+	require.True(t, manager.StopInformer("team-b"))
+	require.False(t, manager.StopInformer("team-b"))
+	require.False(t, manager.HasInformer("team-b"))
+	require.Empty(t, manager.GetDeploymentNames("team-b"))
+	require.Equal(t, []string{"team-a"}, manager.GetAvailableNamespaces())
+	require.Equal(t, []string{"team-a"}, manager.Snapshot().Namespaces())
+	require.Equal(t, map[string]bool{"team-a": true}, manager.SyncStatus())
+	require.False(t, manager.WaitForSync(ctx, "team-b"))
+
+	// Events of a stopped namespace are not cached anymore
+	_, err := clientset.AppsV1().Deployments("team-b").Create(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "team-b"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, watched := manager.Snapshot().Namespace("team-b")
+	require.False(t, watched)
+
+	// A namespace can be watched again
+	manager.StartInformer(ctx, "team-b")
+	require.Equal(t, []string{"cache", "web"}, manager.GetDeploymentNames("team-b"))
+	require.Equal(t, [][]string{{"team-a"}, {"team-a", "team-b"}, {"team-a"}, {"team-a", "team-b"}}, changes)
+}
+
+func TestDeploymentInformerManager_StoppedInformerEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	web := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-b"}}
+	manager := NewDeploymentInformerManager(fake.NewSimpleClientset(web))
+
+	// The handlers of the first informer of team-b, its context is cancelled by StopInformer
+	informerCtx, stopInformer := context.WithCancel(ctx)
+	late := manager.eventHandler(informerCtx, &namespaceState{})
+	manager.StartInformer(informerCtx, "team-b")
+	require.True(t, manager.StopInformer("team-b"))
+	stopInformer()
+
+	// The namespace is watched again before the stopped informer delivers its last events
+	manager.StartInformer(ctx, "team-b")
+	sub, _, _ := manager.Events().Subscribe(nil, 10, false, 0)
+	defer manager.Events().Unsubscribe(sub)
+	generation := func() uint64 {
+		ns, ok := manager.Snapshot().Namespace("team-b")
+		require.True(t, ok)
+		return ns.Generation
+	}
+	before := generation()
+
+	ghost := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "ghost", Namespace: "team-b"}}
+	late.OnAdd(ghost, false)
+	late.OnUpdate(web, web.DeepCopy())
+	late.OnDelete(web)
+
+	require.Equal(t, []string{"web"}, snapshotNames(t, manager.Snapshot(), "team-b"))
+	require.Equal(t, before, generation())
+	select {
+	case e := <-sub.Events():
+		t.Fatalf("unexpected event of a stopped informer: %s %s", e.Type, e.Name)
+	default:
+	}
+
+	// The handlers of the running informer still apply their events
+	manager.eventHandler(ctx, &namespaceState{}).OnAdd(ghost, false)
+	require.Equal(t, []string{"ghost", "web"}, snapshotNames(t, manager.Snapshot(), "team-b"))
+	require.Equal(t, "ghost", (<-sub.Events()).Name)
+}
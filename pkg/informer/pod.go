@@ -0,0 +1,64 @@
+package informer
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newPodInformer creates an informer for the Pods of a namespace
+func newPodInformer(ctx context.Context, clientset kubernetes.Interface, namespace string) cache.SharedIndexInformer {
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return clientset.CoreV1().Pods(namespace).List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return clientset.CoreV1().Pods(namespace).Watch(ctx, options)
+			},
+		},
+		&corev1.Pod{},
+		0, // resync period
+		cache.Indexers{},
+	)
+	// Pods outnumber Deployments, do not keep their managed fields in memory.
+	// The informer has not been started yet so this cannot fail.
+	_ = informer.SetTransform(stripManagedFields)
+	return informer
+}
+
+// stripManagedFields drops the server-side apply bookkeeping of an object before it is cached
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, ok := obj.(metav1.Object); ok {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// ListPods returns the cached Pods of a namespace matching the selector, sorted by name.
+// The returned objects are shared with the cache and must not be modified.
+func (m *DeploymentInformerManager) ListPods(namespace string, selector labels.Selector) []*corev1.Pod {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	informer, exists := m.podInformers[namespace]
+	if !exists {
+		return []*corev1.Pod{}
+	}
+
+	pods := []*corev1.Pod{}
+	_ = cache.ListAll(informer.GetStore(), selector, func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	})
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods
+}
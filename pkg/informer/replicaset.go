@@ -0,0 +1,87 @@
+package informer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// RevisionAnnotation is set by the Deployment controller on Deployments and their ReplicaSets
+	RevisionAnnotation = "deployment.kubernetes.io/revision"
+	// controllerUIDIndex indexes ReplicaSets by the UID of their controlling owner
+	controllerUIDIndex = "controllerUID"
+)
+
+// newReplicaSetInformer creates an informer for the ReplicaSets of a namespace, indexed by owner
+func newReplicaSetInformer(ctx context.Context, clientset kubernetes.Interface, namespace string) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return clientset.AppsV1().ReplicaSets(namespace).List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return clientset.AppsV1().ReplicaSets(namespace).Watch(ctx, options)
+			},
+		},
+		&appsv1.ReplicaSet{},
+		0, // resync period
+		cache.Indexers{controllerUIDIndex: indexByControllerUID},
+	)
+}
+
+// indexByControllerUID returns the UID of the object's controller, if it has one
+func indexByControllerUID(obj interface{}) ([]string, error) {
+	rs, ok := obj.(*appsv1.ReplicaSet)
+	if !ok {
+		return nil, nil
+	}
+	if ref := metav1.GetControllerOf(rs); ref != nil {
+		return []string{string(ref.UID)}, nil
+	}
+	return nil, nil
+}
+
+// ListReplicaSets returns the cached ReplicaSets controlled by the Deployment, ordered by revision.
+// The returned objects are shared with the cache and must not be modified.
+func (m *DeploymentInformerManager) ListReplicaSets(deployment *appsv1.Deployment) []*appsv1.ReplicaSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	informer, exists := m.replicaSetInformers[deployment.Namespace]
+	if !exists || deployment.UID == "" {
+		return []*appsv1.ReplicaSet{}
+	}
+
+	objs, err := informer.GetIndexer().ByIndex(controllerUIDIndex, string(deployment.UID))
+	if err != nil {
+		return []*appsv1.ReplicaSet{}
+	}
+
+	replicaSets := make([]*appsv1.ReplicaSet, 0, len(objs))
+	for _, obj := range objs {
+		if rs, ok := obj.(*appsv1.ReplicaSet); ok {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	sort.Slice(replicaSets, func(i, j int) bool {
+		return Revision(replicaSets[i]) < Revision(replicaSets[j])
+	})
+	return replicaSets
+}
+
+// Revision returns the revision number recorded on a Deployment or ReplicaSet, or 0 if it has none
+func Revision(obj metav1.Object) int64 {
+	revision, err := strconv.ParseInt(obj.GetAnnotations()[RevisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
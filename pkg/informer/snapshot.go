@@ -0,0 +1,161 @@
+package informer
+
+import (
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Snapshot is an immutable view of the cached Deployments of every watched namespace. The informer
+// event handlers replace it on every change, copying only the namespace that changed, so readers get
+// a consistent view without taking the manager's lock or the informer store locks.
+type Snapshot struct {
+	namespaces map[string]*NamespaceSnapshot
+	// names are the watched namespaces in sorted order
+	names []string
+}
+
+// NamespaceSnapshot holds the cached Deployments of a namespace sorted by name. It must not be modified.
+type NamespaceSnapshot struct {
+	Namespace string
+	// Generation is unique to this version of the namespace. It changes whenever a Deployment of the
+	// namespace is added, updated or deleted, so it can key caches derived from the snapshot.
+	Generation uint64
+	// Deployments are shared with the informer cache and must not be modified
+	Deployments []*appsv1.Deployment
+}
+
+var emptySnapshot = &Snapshot{namespaces: map[string]*NamespaceSnapshot{}}
+
+// Namespaces returns the watched namespaces in sorted order. The slice must not be modified.
+func (s *Snapshot) Namespaces() []string {
+	return s.names
+}
+
+// Namespace returns the snapshot of a watched namespace
+func (s *Snapshot) Namespace(namespace string) (*NamespaceSnapshot, bool) {
+	ns, ok := s.namespaces[namespace]
+	return ns, ok
+}
+
+// Snapshot returns the current snapshot of the cached Deployments
+func (m *DeploymentInformerManager) Snapshot() *Snapshot {
+	return m.snapshot.Load()
+}
+
+// addSnapshotNamespace adds an empty namespace to the snapshot when its informer is registered, so
+// namespaces without Deployments are listed too
+func (m *DeploymentInformerManager) addSnapshotNamespace(namespace string) {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+	m.updateSnapshotLocked(namespace, true, func(deployments []*appsv1.Deployment) []*appsv1.Deployment {
+		return deployments
+	})
+}
+
+// setSnapshotDeploymentLocked adds or replaces a Deployment in the snapshot, the caller holds m.snapshotMu
+func (m *DeploymentInformerManager) setSnapshotDeploymentLocked(deployment *appsv1.Deployment) {
+	m.updateSnapshotLocked(deployment.Namespace, false, func(deployments []*appsv1.Deployment) []*appsv1.Deployment {
+		i, found := searchDeployments(deployments, deployment.Name)
+		if found {
+			updated := make([]*appsv1.Deployment, len(deployments))
+			copy(updated, deployments)
+			updated[i] = deployment
+			return updated
+		}
+		updated := make([]*appsv1.Deployment, 0, len(deployments)+1)
+		updated = append(updated, deployments[:i]...)
+		updated = append(updated, deployment)
+		return append(updated, deployments[i:]...)
+	})
+}
+
+// deleteSnapshotDeploymentLocked removes a Deployment from the snapshot, the caller holds m.snapshotMu
+func (m *DeploymentInformerManager) deleteSnapshotDeploymentLocked(deployment *appsv1.Deployment) {
+	m.updateSnapshotLocked(deployment.Namespace, false, func(deployments []*appsv1.Deployment) []*appsv1.Deployment {
+		i, found := searchDeployments(deployments, deployment.Name)
+		if !found {
+			return deployments
+		}
+		updated := make([]*appsv1.Deployment, 0, len(deployments)-1)
+		updated = append(updated, deployments[:i]...)
+		return append(updated, deployments[i+1:]...)
+	})
+}
+
+// removeSnapshotNamespace removes a namespace from the snapshot when its informer is stopped
+func (m *DeploymentInformerManager) removeSnapshotNamespace(namespace string) {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	current := m.snapshot.Load()
+	if _, exists := current.namespaces[namespace]; !exists {
+		return
+	}
+	next := &Snapshot{
+		namespaces: make(map[string]*NamespaceSnapshot, len(current.namespaces)-1),
+		names:      make([]string, 0, len(current.names)-1),
+	}
+	for ns, snapshot := range current.namespaces {
+		if ns != namespace {
+			next.namespaces[ns] = snapshot
+		}
+	}
+	for _, name := range current.names {
+		if name != namespace {
+			next.names = append(next.names, name)
+		}
+	}
+	m.snapshot.Store(next)
+}
+
+// updateSnapshotLocked publishes a new snapshot in which the Deployments of the namespace are replaced
+// by the result of update. update must not modify its argument, the other namespaces are shared. Only
+// create adds a namespace. The caller holds m.snapshotMu.
+func (m *DeploymentInformerManager) updateSnapshotLocked(namespace string, create bool, update func([]*appsv1.Deployment) []*appsv1.Deployment) {
+	current := m.snapshot.Load()
+	var deployments []*appsv1.Deployment
+	existing, exists := current.namespaces[namespace]
+	if exists {
+		deployments = existing.Deployments
+	} else if !create {
+		return
+	}
+
+	updated := update(deployments)
+	if exists && sameDeployments(deployments, updated) {
+		return
+	}
+
+	m.snapshotGeneration++
+	next := &Snapshot{
+		namespaces: make(map[string]*NamespaceSnapshot, len(current.namespaces)+1),
+		names:      current.names,
+	}
+	for ns, snapshot := range current.namespaces {
+		next.namespaces[ns] = snapshot
+	}
+	next.namespaces[namespace] = &NamespaceSnapshot{
+		Namespace:   namespace,
+		Generation:  m.snapshotGeneration,
+		Deployments: updated,
+	}
+	if !exists {
+		next.names = make([]string, 0, len(current.names)+1)
+		next.names = append(next.names, current.names...)
+		next.names = append(next.names, namespace)
+		sort.Strings(next.names)
+	}
+	m.snapshot.Store(next)
+}
+
+// searchDeployments returns the index of the named Deployment in a sorted slice, or where it would be inserted
+func searchDeployments(deployments []*appsv1.Deployment, name string) (int, bool) {
+	i := sort.Search(len(deployments), func(i int) bool { return deployments[i].Name >= name })
+	return i, i < len(deployments) && deployments[i].Name == name
+}
+
+// sameDeployments reports whether update returned its argument unchanged
+func sameDeployments(a, b []*appsv1.Deployment) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
@@ -0,0 +1,79 @@
+package informer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func snapshotNames(t *testing.T, snapshot *Snapshot, namespace string) []string {
+	t.Helper()
+	ns, ok := snapshot.Namespace(namespace)
+	require.True(t, ok, namespace)
+	names := []string{}
+	for _, d := range ns.Deployments {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestDeploymentInformerManager_Snapshot(t *testing.T) {
+	deployment := func(namespace, name, resourceVersion string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: resourceVersion}}
+	}
+	clientset := fake.NewSimpleClientset(
+		deployment("team-a", "web", "1"),
+		deployment("team-a", "api", "1"),
+		deployment("team-a", "worker", "1"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewDeploymentInformerManager(clientset)
+	assert.Empty(t, manager.Snapshot().Namespaces())
+	manager.StartInformer(ctx, "team-b")
+	manager.StartInformer(ctx, "team-a")
+
+	require.Eventually(t, func() bool {
+		ns, ok := manager.Snapshot().Namespace("team-a")
+		return ok && len(ns.Deployments) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Namespaces are listed even without deployments, deployments are sorted by name
+	before := manager.Snapshot()
+	assert.Equal(t, []string{"team-a", "team-b"}, before.Namespaces())
+	assert.Equal(t, []string{"api", "web", "worker"}, snapshotNames(t, before, "team-a"))
+	assert.Empty(t, snapshotNames(t, before, "team-b"))
+	teamA, _ := before.Namespace("team-a")
+	teamB, _ := before.Namespace("team-b")
+
+	_, err := clientset.AppsV1().Deployments("team-a").Update(ctx, deployment("team-a", "web", "2"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = clientset.AppsV1().Deployments("team-a").Create(ctx, deployment("team-a", "cache", "3"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, clientset.AppsV1().Deployments("team-a").Delete(ctx, "worker", metav1.DeleteOptions{}))
+
+	require.Eventually(t, func() bool {
+		names := snapshotNames(t, manager.Snapshot(), "team-a")
+		return len(names) == 3 && names[1] == "cache"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	after := manager.Snapshot()
+	assert.Equal(t, []string{"api", "cache", "web"}, snapshotNames(t, after, "team-a"))
+	web, _ := after.Namespace("team-a")
+	assert.Equal(t, "2", web.Deployments[2].ResourceVersion)
+	assert.NotEqual(t, teamA.Generation, web.Generation)
+
+	// Earlier snapshots are not modified, unchanged namespaces are shared
+	assert.Equal(t, []string{"api", "web", "worker"}, snapshotNames(t, before, "team-a"))
+	assert.Equal(t, "1", teamA.Deployments[1].ResourceVersion)
+	unchanged, _ := after.Namespace("team-b")
+	assert.Same(t, teamB, unchanged)
+}
@@ -0,0 +1,90 @@
+package informer
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceStatus describes the informers of a watched namespace
+type NamespaceStatus struct {
+	Namespace string
+	// Synced is true once the Deployment, ReplicaSet and Pod informers have synced
+	Synced bool
+	// LastSync is when the Deployments were last listed from the API server, zero before the first list
+	LastSync time.Time
+	// LastEvent is when the last Deployment event was seen, zero before the first one
+	LastEvent time.Time
+	// LastError is the last list or watch error of the informers, such as an RBAC denial, and
+	// LastErrorTime when it happened. It is kept after the informers recover, compare it with LastSync.
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// namespaceState records the activity of the informers of a namespace
+type namespaceState struct {
+	mu            sync.Mutex
+	lastSync      time.Time
+	lastEvent     time.Time
+	lastError     error
+	lastErrorTime time.Time
+}
+
+func (s *namespaceState) listed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSync = time.Now()
+}
+
+func (s *namespaceState) eventSeen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastEvent = time.Now()
+}
+
+// watchErrorHandler records list and watch errors, then logs them like the informer does by default
+func (s *namespaceState) watchErrorHandler(ctx context.Context, r *cache.Reflector, err error) {
+	// Closed and expired watches are part of normal operation, the informer resumes them
+	if err != io.EOF && err != io.ErrUnexpectedEOF && !apierrors.IsResourceExpired(err) && !apierrors.IsGone(err) {
+		s.mu.Lock()
+		s.lastError = err
+		s.lastErrorTime = time.Now()
+		s.mu.Unlock()
+	}
+	cache.DefaultWatchErrorHandler(ctx, r, err)
+}
+
+// status returns the recorded activity as a NamespaceStatus
+func (s *namespaceState) status(namespace string, synced bool) NamespaceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := NamespaceStatus{
+		Namespace:     namespace,
+		Synced:        synced,
+		LastSync:      s.lastSync,
+		LastEvent:     s.lastEvent,
+		LastErrorTime: s.lastErrorTime,
+	}
+	if s.lastError != nil {
+		status.LastError = s.lastError.Error()
+	}
+	return status
+}
+
+// NamespaceStatuses returns the status of the informers of every watched namespace, sorted by namespace
+func (m *DeploymentInformerManager) NamespaceStatuses() []NamespaceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]NamespaceStatus, 0, len(m.informers))
+	for namespace := range m.informers {
+		statuses = append(statuses, m.states[namespace].status(namespace, m.syncedLocked(namespace)))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Namespace < statuses[j].Namespace })
+	return statuses
+}
@@ -0,0 +1,62 @@
+package informer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDeploymentInformerManager_NamespaceStatuses(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"}})
+	clientset.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "locked" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", nil)
+	})
+
+	manager := NewDeploymentInformerManager(clientset)
+	assert.Empty(t, manager.NamespaceStatuses())
+
+	start := time.Now()
+	manager.StartInformer(ctx, "team-a")
+	require.True(t, manager.StartInformerAsync(ctx, "locked"))
+
+	require.Eventually(t, func() bool {
+		statuses := manager.NamespaceStatuses()
+		return len(statuses) == 2 && statuses[0].LastError != ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	statuses := manager.NamespaceStatuses()
+	locked, teamA := statuses[0], statuses[1]
+	assert.Equal(t, "locked", locked.Namespace)
+	assert.False(t, locked.Synced)
+	assert.True(t, locked.LastSync.IsZero())
+	assert.True(t, locked.LastEvent.IsZero())
+	assert.Contains(t, locked.LastError, "forbidden")
+	assert.False(t, locked.LastErrorTime.Before(start))
+
+	assert.Equal(t, "team-a", teamA.Namespace)
+	assert.True(t, teamA.Synced)
+	assert.False(t, teamA.LastSync.Before(start))
+	assert.False(t, teamA.LastEvent.Before(start))
+	assert.Empty(t, teamA.LastError)
+	assert.True(t, teamA.LastErrorTime.IsZero())
+
+	// Stopped namespaces have no status
+	manager.StopInformer("locked")
+	require.Len(t, manager.NamespaceStatuses(), 1)
+}